@@ -1,21 +1,30 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/jwks"
 	"github.com/clerk/clerk-sdk-go/v2/user"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
 	"main.go/cmd"
+	"main.go/internal/config"
+	"main.go/internal/const/roles"
 	"main.go/internal/handlers"
 	"main.go/internal/middlewares"
 	"main.go/internal/services"
 	apikeys "main.go/internal/utils/apikeys"
+	"main.go/internal/utils/cache"
+	"main.go/internal/utils/storage"
+	"main.go/internal/utils/mailer"
 )
 
  func main() {
@@ -45,7 +54,11 @@ import (
 		MaxAge:           12 * time.Hour,
 	}))
 	// router.MaxMultipartMemory = 50 << 20 
-	routes(router)
+	err = routes(router)
+	if err != nil {
+		fmt.Printf("Error registering routes : %v", err)
+		return
+	}
 
 	err = router.Run(os.Getenv("PORT"))
 	if err != nil {
@@ -68,31 +81,75 @@ import (
 	db := cmd.PostgresPool
 	// TODO: separate and strengthen this later on
 	httpClient := &http.Client{}
+	mailClient, err := NewMailer(os.Getenv("AuthProvider") == config.AuthProviderNative)
+	if err != nil {
+		return err
+	}
 
-	clerkClient := NewClerkClient()
-	if clerkClient == nil {
-		return fmt.Errorf("failed to get clerk client")
+	var clerkClient *user.Client
+	var authMiddleware gin.HandlerFunc
+	switch os.Getenv("AuthProvider") {
+	case config.AuthProviderNative:
+		// self hosted, users sign up and log in with an email and password
+		authService := services.NewAuthService(queries, mailClient)
+		authHandler := handlers.NewAuthHandler(authService)
+		authGroup := womid.Group("/auth")
+		authHandler.RegisterRoute(authGroup)
+
+		authMiddleware = middlewares.Authenticate()
+	default:
+		clerkClient = NewClerkClient()
+		if clerkClient == nil {
+			return fmt.Errorf("failed to get clerk client")
+		}
+
+		clerkJWKSClient := NewClerkJWKSClient()
+		if clerkJWKSClient == nil {
+			return fmt.Errorf("failed to get clerk jwks client")
+		}
+
+		authMiddleware = middlewares.ClerkAuth(middlewares.ClerkKeySet(clerkJWKSClient))
+
+		webhookService := services.NewWebhookService(queries, db)
+		webhookHandler := handlers.NewWebhookHandler(webhookService)
+		webhookGroup := womid.Group("/webhooks")
+		webhookHandler.RegisterRoute(webhookGroup)
 	}
 
-	publicService := services.NewPublicService(queries, db, clerkClient)
+	publicService := services.NewPublicService(queries, db, clerkClient, mailClient)
 	publicHandler := handlers.NewPublicHandler(publicService)
 	publicGroup := womid.Group("/public")
-	publicGroup.Use(middlewares.ClerkAuth())
-	publicHandler.RegisterRoute(publicGroup)
+	publicGroup.Use(authMiddleware)
+	// suspended and deleted users are turned away from everything but the signup
+	userGroup := publicGroup.Group("")
+	userGroup.Use(middlewares.Authorize(queries, roles.All...))
+	publicHandler.RegisterRoute(publicGroup, userGroup)
+
+	adminService := services.NewAdminService(queries)
+	adminHandler := handlers.NewAdminHandler(adminService)
+	staffGroup := womid.Group("/admin")
+	staffGroup.Use(authMiddleware, middlewares.Authorize(queries, roles.Staff...))
+	adminGroup := staffGroup.Group("")
+	adminGroup.Use(middlewares.Authorize(queries, roles.Admin))
+	adminHandler.RegisterRoute(staffGroup, adminGroup)
 
 
-	cacheService := services.NewCacheService(queries, httpClient, &services.CacheSourceURL{
-		PutCacheURL: "/api/caching/set",
-		GetCacheURL: "/api/caching/get",
-	})
+	cacheBackend, err := NewCacheBackend(httpClient)
+	if err != nil {
+		return err
+	}
+
+	cacheService := services.NewCacheService(queries, db, cacheBackend)
 	cacheHandler := handlers.NewCacheHandler(cacheService)
 	cacheGroup := wmid.Group("/cache")
 	cacheHandler.RegisterRoute(cacheGroup)
 
-	storageService := services.NewStorageService(queries, httpClient, &services.StorageSourceURL{
-		UploadURL: "/api/storage/upload-file",
-		DownloadURL: "/api/storage/get-file",
-	})
+	storageBackend, err := NewStorageBackend(httpClient)
+	if err != nil {
+		return err
+	}
+
+	storageService := services.NewStorageService(queries, storageBackend)
 	storageHandler := handlers.NewStorageHandler(storageService)
 	storageGroup := wmid.Group("/storage")
 	storageHandler.RegisterRoute(storageGroup)
@@ -114,4 +171,98 @@ import (
 
 	return user.NewClient(&clerkConf)
 
+ }
+
+ // NewCacheBackend returns the cache backend named by the CacheBackend env, the remote source is used when it is not set.
+ func NewCacheBackend(httpClient *http.Client) (cache.CacheBackend, error) {
+
+	switch backend := os.Getenv("CacheBackend"); backend {
+	case cache.BackendMemory:
+		maxBytes := config.CacheMemoryMaxBytes
+		if maxBytesStr, exists := os.LookupEnv("CacheMemoryMaxBytes"); exists {
+			parsed, err := strconv.ParseInt(maxBytesStr, 10, 64)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid CacheMemoryMaxBytes : %s", maxBytesStr)
+			}
+			maxBytes = parsed
+		}
+		return cache.NewMemoryBackend(maxBytes), nil
+	case cache.BackendRedis:
+		opts, err := redis.ParseURL(os.Getenv("RedisURL"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RedisURL : %w", err)
+		}
+		client := redis.NewClient(opts)
+		err = client.Ping(context.Background()).Err()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reach redis : %w", err)
+		}
+		return cache.NewRedisBackend(client, config.CacheRedisKeyPrefix), nil
+	case cache.BackendRemote, "":
+		return cache.NewRemoteBackend(httpClient, config.SourceBaseDomain, &cache.SourceURLs{
+			PutCacheURL: config.CacheSetURL,
+			GetCacheURL: config.CacheGetURL,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend : %s", backend)
+	}
+ }
+
+ // NewStorageBackend returns the storage backend named by the StorageBackend env, the remote source is used when it is not set.
+ func NewStorageBackend(httpClient *http.Client) (storage.StorageBackend, error) {
+
+	switch backend := os.Getenv("StorageBackend"); backend {
+	case storage.BackendLocal:
+		root := config.StorageLocalRoot
+		if rootEnv, exists := os.LookupEnv("StorageLocalRoot"); exists {
+			root = rootEnv
+		}
+		return storage.NewLocalBackend(root)
+	case storage.BackendS3:
+		cfg, err := storage.S3ConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Region == "" {
+			cfg.Region = config.StorageS3Region
+		}
+		cfg.PartSize = config.StorageS3PartSize
+		return storage.NewS3Backend(httpClient, cfg), nil
+	case storage.BackendRemote, "":
+		return storage.NewRemoteBackend(httpClient, config.SourceBaseDomain, &storage.SourceURLs{
+			UploadURL: config.StorageUploadURL,
+			DownloadURL: config.StorageDownloadURL,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend : %s", backend)
+	}
+ }
+
+ // NewMailer returns an smtp mailer if smtp is configured in env.
+ // Native auth cannot work without it, users confirm their email from the mail, so it is required there.
+ // With clerk the emails are verified by clerk, so mails are only logged when smtp is missing.
+ func NewMailer(required bool) (mailer.Mailer, error) {
+	smtpMailer, err := mailer.NewSMTPMailerFromEnv()
+	if err != nil {
+		if required {
+			return nil, fmt.Errorf("failed to get mailer : %w", err)
+		}
+		fmt.Println("Emails will not be delivered : " + err.Error())
+		return mailer.NewLogMailer(), nil
+	}
+
+	return smtpMailer, nil
+ }
+
+ // NewClerkJWKSClient returns the client used to fetch the instance keys that sign clerk session tokens.
+ func NewClerkJWKSClient() *jwks.Client {
+	clerkKey, exists := os.LookupEnv("ClerkSecretKey")
+	if !exists {
+		return nil
+	}
+
+	clerkConf := clerk.ClientConfig{}
+	clerkConf.Key = &clerkKey
+
+	return jwks.NewClient(&clerkConf)
  }
\ No newline at end of file
@@ -4,14 +4,65 @@ package config
 const (
 	JWTAccessExpiration = 3600 // seconds //  
 	JWTRefreshExpiration = 604800 // seconds // 7 days // 604800 seconds
+	ConfirmTokenExpiration = 86400 // seconds // 1 day
+)
+
+const (
+	// appended to the FrontendBaseURL env, the frontend posts the token from the link to /public/confirm
+	ConfirmEmailPath = "/confirm"
+)
+
+const (
+	// values of the AuthProvider env, clerk is used when it is not set
+	AuthProviderClerk = "clerk"
+	AuthProviderNative = "native"
+)
+
+const (
+	WebhookMaxBodySize int64 = 1 << 20 // bytes // 1 MB
+)
+
+const (
+	AdminListDefaultLimit int32 = 50
+	AdminListMaxLimit int32 = 500
 )
 
 const (
 	StorageUploadFileSizeLimit int64 = 75000000 // bytes
+	StorageLocalRoot = "./data/storage" // directory of the local storage backend, overridden by the StorageLocalRoot env
+	StorageListDefaultLimit = 100 // files per page of a listing without a limit
+	StorageListMaxLimit = 1000 // files per page of a listing
+	StorageS3Region = "us-east-1" // of the s3 backend when the S3Region env is not set
+	StorageS3PartSize int64 = 16 << 20 // bytes // 16 MB // larger files are sent to s3 as a multipart upload, in parts of this size
+)
+
+const (
+	CacheMemoryMaxBytes int64 = 256 << 20 // bytes // 256 MB // default bound of the in memory cache backend, overridden by the CacheMemoryMaxBytes env
+	CacheRedisKeyPrefix = "vaultbase" // all keys written by the redis cache backend start with this
+	CacheBatchMaxKeys = 100 // max keys of a single mget, mset or mdel
+	CacheMaxValueSize = 1 << 20 // bytes // 1 MB // after decoding, so bytes values can be sent a third larger as base64
+	CacheMaxBodySize int64 = 2 << 20 // bytes // 2 MB // of a put request
+	CacheMaxBatchBodySize int64 = 16 << 20 // bytes // 16 MB // of a batch request
+	CacheDefaultDatabase = "default" // name of the database of the keys put without one, the namespace of the user_uiid
+	CacheMaxDatabases int64 = 16 // named cache databases per project
+	CacheMaxTags = 16 // per key, and per invalidate request
+	CacheMaxTagLength = 128 // bytes
+	CacheListDefaultLimit = 100 // keys per page of a key listing without a limit
+	CacheListMaxLimit = 1000 // keys per page of a key listing
+	CacheExportPageSize = 500 // keys read at once while exporting
+	CacheImportMaxLineSize = 8 << 20 // bytes // 8 MB // of an import entry, a max size value can take up to 6 times its size once escaped in json
+	CacheImportMaxErrors = 100 // failed lines reported back by an import, the rest are only counted
+	CacheEventBufferSize = 4096 // analytics events waiting to be written, more are dropped rather than slowing the cache
+	CacheWatchBacklog = 4096 // last change events of all projects kept to resume a watch with Last-Event-ID
+	CacheWatchBuffer = 256 // change events waiting to be sent to a watcher, one that falls further behind is disconnected
+	CacheWatchHeartbeat = 15 // seconds // comment sent on an idle watch to keep it open through proxies
 )
 
 const (
 	DefaultAPIKeyTTL int64 = 604800 // seconds // 7 days // 604800 seconds
+	APIKeyRenewalGracePeriod int64 = 3600 // seconds // 1 hour // old key keeps working this long after a renewal
+	MaxAPIKeyTTL int64 = 31536000 // seconds // 365 days
+	DefaultAPIKeyLabel = "default"
 )
 
 
@@ -11,6 +11,9 @@ const (
 	NotFound = "NOT_FOUND"
 	InvalidFormat = "INVALID_FORMAT"
 	IncompleteForm = "INCOMPLETE_FORM"
+	Expired = "EXPIRED"
+	Conflict = "CONFLICT"
+	TooLarge = "TOO_LARGE"
 
 	// Postgres error codes (SQLSTATE)
 	UniqueViolation = "23505"
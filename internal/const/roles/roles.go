@@ -0,0 +1,36 @@
+package roles
+
+import "slices"
+
+// Roles stored in users.role, every user starts as a User.
+const (
+	User int64 = 1
+	Support int64 = 2 // can look into any user or project, but not change them
+	Admin int64 = 3
+)
+
+var (
+	// All are all the known roles
+	All = []int64{User, Support, Admin}
+	// Staff are the roles allowed into the admin routes
+	Staff = []int64{Support, Admin}
+)
+
+// IsValid reports whether role is a known role.
+func IsValid(role int64) bool {
+	return slices.Contains(All, role)
+}
+
+// Name returns the readable name of the role.
+func Name(role int64) string {
+	switch role {
+	case User:
+		return "user"
+	case Support:
+		return "support"
+	case Admin:
+		return "admin"
+	default:
+		return "unknown"
+	}
+}
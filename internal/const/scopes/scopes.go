@@ -0,0 +1,46 @@
+package scopes
+
+import "slices"
+
+// Scopes granted to an api key, each one allows a single operation.
+const (
+	CacheGet = "cache:get"
+	CachePut = "cache:put"
+	CacheDelete = "cache:delete"
+
+	StorageUpload = "storage:upload"
+	StorageDownload = "storage:download"
+	StorageList = "storage:list"
+)
+
+var (
+	// Cache are all the scopes of the cache service
+	Cache = []string{CacheGet, CachePut, CacheDelete}
+	// Storage are all the scopes of the storage service
+	Storage = []string{StorageUpload, StorageDownload, StorageList}
+)
+
+// IsValid reports whether scope is a known scope.
+func IsValid(scope string) bool {
+	return slices.Contains(Cache, scope) || slices.Contains(Storage, scope)
+}
+
+// Has reports whether scope is among the granted scopes.
+func Has(granted []string, scope string) bool {
+	return slices.Contains(granted, scope)
+}
+
+// Normalize validates the requested scopes and drops duplicates.
+// The first unknown scope is returned along with false.
+func Normalize(requested []string) ([]string, string, bool) {
+	normalized := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		if !IsValid(scope) {
+			return nil, scope, false
+		}
+		if !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized, "", true
+}
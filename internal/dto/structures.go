@@ -13,28 +13,34 @@ type Token struct {
 	Role int64
 	ID int64
 	Email string	
+	Type string // access or refresh
+	JTI string // refresh tokens only
+	FamilyID string // refresh tokens only
 
 	Version string
 }
 
 type JWTTokens struct {
-	JWTAccess string
-	JWTRefresh string
-}
-
-type SignupData struct {
-	Email string
-	ClerkID string
+	JWTAccess string `json:"access"`
+	JWTRefresh string `json:"refresh"`
 }
 
 type LoginData struct {
-	Email string
-	Password string
+	Email string `json:"email"`
+	Password string `json:"password"`
 }
 
 type LoginResponse struct {
-	Tokens *JWTTokens
-	Role int64
+	Tokens *JWTTokens `json:"tokens"`
+	Role int64 `json:"role"`
+}
+
+type RefreshData struct {
+	Refresh string `json:"refresh"`
+}
+
+type ConfirmData struct {
+	Token string `json:"token"` // from the confirmation link
 }
 
 
@@ -51,18 +57,107 @@ type NewProjectResp struct {
 	ServiceUUID string `json:"id"`
 	ServiceName string	`json:"name"`
 	ServiceCreatedAt int64	`json:"createdat"`
-	KeyInfo *APIKeyResponse	`json:"keyinfo"`
+	KeyInfo *APIKeyResponse	`json:"keyinfo,omitempty"` // the key created along with the project
+	Keys []*APIKeyResponse `json:"keys,omitempty"` // all active keys, when listing projects
+}
+
+type NewKey struct {
+	Name string `json:"name"` // project name
+	Label string `json:"label"` // to tell keys apart, like ci, backend or mobile
+	TTL int64 `json:"ttl"` // in seconds, 0 uses the default ttl
+	Scopes []string `json:"scopes"` // from const/scopes, like cache:get or storage:upload
+}
+
+type ToggleKey struct {
+	Name string `json:"name"` // project name
+	ID string `json:"id"` // key id
+	Scopes []string `json:"scopes"` // replaces all the scopes of the key
+}
+
+type RevokeKey struct {
+	Name string `json:"name"` // project name
+	ID string `json:"id"` // key id
+}
+
+type RenewKey struct {
+	Key string `json:"key"` // the current key to renew, may already be expired
+	TTL int64 `json:"ttl"` // in seconds, 0 keeps the lifetime the key was issued with
 }
 
 type APIKeyResponse struct {
 	ID string	`json:"id"`
-	Key string	`json:"key"`
+	Key string	`json:"key,omitempty"` // full key, only ever set on creation or renewal as it is not stored
+	Prefix string	`json:"prefix"`
+	Label string `json:"label"`
 	CreatedAt int64 `json:"createdat"`
 	ExpiresAt int64	`json:"expiresat"`
-	Cache bool	`json:"cache"`
-	Storage bool `json:"storage"`
+	Revoked bool `json:"revoked"`
+	Scopes []string `json:"scopes"`
 }		
 
+type AdminUser struct {
+	ID int64 `json:"id"`
+	Email string `json:"email"`
+	Role string `json:"role"` // name of the role, from const/roles
+	UserUIID string `json:"uiid"`
+	CreatedAt int64 `json:"createdat"`
+	Confirmed bool `json:"confirmed"`
+	Deleted bool `json:"deleted"`
+	Suspended bool `json:"suspended"`
+	Projects int64 `json:"projects"` // number of projects owned
+}
+
+type SuspendUser struct {
+	UserID int64 `json:"userid"`
+	Suspended bool `json:"suspended"` // false lifts the suspension
+}
+
+type AdminProject struct {
+	ServiceUUID string `json:"id"`
+	ServiceName string `json:"name"`
+	ServiceCreatedAt int64 `json:"createdat"`
+	OwnerID int64 `json:"ownerid"`
+	OwnerEmail string `json:"owneremail"`
+	Keys []*APIKeyResponse `json:"keys"` // all keys, revoked ones included
+}
+
+
+// ClerkWebhookEvent is the body of a clerk webhook, only the user events are handled.
+type ClerkWebhookEvent struct {
+	Type string `json:"type"` // like user.created, user.updated or user.deleted
+	Data ClerkUser `json:"data"`
+}
+
+type ClerkUser struct {
+	ID string `json:"id"` // clerk user id
+	PrimaryEmailAddressID string `json:"primary_email_address_id"`
+	EmailAddresses []ClerkEmailAddress `json:"email_addresses"`
+	Deleted bool `json:"deleted"` // only set on user.deleted
+}
+
+type ClerkEmailAddress struct {
+	ID string `json:"id"`
+	EmailAddress string `json:"email_address"`
+	Verification *ClerkVerification `json:"verification"` // null until a verification is started
+}
+
+type ClerkVerification struct {
+	Status string `json:"status"` // "verified" once clerk confirmed the address
+}
+
+
+// a stored file, fields the storage backend does not know are left empty
+type StorageObject struct {
+	Key string `json:"key"`
+	Size int64 `json:"size"` // in bytes, -1 if unknown
+	ContentType string `json:"contenttype,omitempty"`
+	ETag string `json:"etag,omitempty"` // changes with the content
+	ModifiedAt int64 `json:"modifiedat,omitempty"` // unix seconds
+}
+type StorageObjectPage struct {
+	Files []*StorageObject `json:"files"`
+	Cursor string `json:"cursor"` // to get the next page with, empty once all files were listed
+}
 
 type StorageData struct {
 	Scope int64
@@ -90,8 +185,11 @@ type SetCacheKeyIncoming struct {
 	APIKey string // personal API key
 
 	CacheKey string // as a string without spaces, see documentation for more details
-	CacheValue string // currently string, can later be upgraded to any
+	CacheValue string // as is for strings and json documents, base64 encoded for bytes
 	CacheTTL int64 // as interger in milliseconds
+	ValueType string // string, json or bytes, defaults to string
+	ContentType string // of bytes values, defaults to application/octet-stream
+	Tags []string // to invalidate the key along with the others carrying a tag, replaces the tags of an existing key
 
 	UpdateIfExists bool // whether to update if the key already exists
 }
@@ -100,7 +198,112 @@ type SetCacheKeyOutgoing struct {
 	Key string `json:"key"`
 	Value string `json:"value"`
 	TTL int64 `json:"ttl"`
+	UpdateIfExists bool `json:"updateIfExists"` // false makes the put create only
+
+}
+
+type ExpireCacheKey struct {
+	TTL int64 `json:"ttl"` // new ttl in milliseconds, 0 removes the expiry
+}
+
+type IncrCacheKey struct {
+	By int64 `json:"by"` // defaults to 1
+	TTL int64 `json:"ttl"` // in milliseconds, only set if the key is created by the increment
+}
+
+// compare-and-swap, the value is only replaced if it still has the ETag returned by the get
+type SwapCacheKey struct {
+	CacheKey string
+	CacheValue string // see SetCacheKeyIncoming
+	CacheTTL int64 // in milliseconds, 0 keeps the current ttl
+	ValueType string
+	ContentType string
+	ETag string
+}
 
+// named cache databases of a project, see config.CacheDefaultDatabase for the default one
+type NewCacheDatabase struct {
+	Name string `json:"name"`
+	DefaultTTL int64 `json:"defaultttl"` // in milliseconds, for keys put without a ttl, 0 for none
+	MaxKeys int64 `json:"maxkeys"` // 0 for no limit
+	EvictionPolicy string `json:"evictionpolicy"` // noeviction, the default, or lru
+}
+type CacheDatabase struct {
+	Name string `json:"name"`
+	DefaultTTL int64 `json:"defaultttl"`
+	MaxKeys int64 `json:"maxkeys"`
+	EvictionPolicy string `json:"evictionpolicy"`
+	CreatedAt int64 `json:"createdat"`
+}
+
+// batch requests, the api key is checked once for the whole batch
+type CacheBatchKeys struct {
+	Keys []string `json:"keys"`
+}
+type CacheBatchPut struct {
+	Entries []SetCacheKeyIncoming `json:"entries"`
+}
+
+// a page of the keys of a cache database, see CacheService.ListKeys
+type CacheKeyInfo struct {
+	Key string `json:"key"`
+	TTL int64 `json:"ttl"` // remaining, in milliseconds, -1 if it never expires
+	Size int64 `json:"size"` // in bytes, as stored
+}
+type CacheKeyPage struct {
+	Keys []CacheKeyInfo `json:"keys"`
+	Cursor string `json:"cursor"` // to get the next page with, empty once all keys were listed
+}
+
+// a line of a cache export, read back as is by an import
+type CacheExportEntry struct {
+	Key string `json:"key"`
+	Value string `json:"value"` // base64 encoded for bytes values
+	Type string `json:"type"`
+	ContentType string `json:"contenttype,omitempty"` // of bytes values
+	TTL int64 `json:"ttl"` // remaining, in milliseconds and at least 1, -1 if it never expires. Imported as no expiry when missing
+}
+type CacheImportError struct {
+	Line int64 `json:"line"`
+	Key string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+type CacheImportResult struct {
+	Imported int64 `json:"imported"`
+	Skipped int64 `json:"skipped"` // already existing, without overwrite
+	Failed int64 `json:"failed"`
+	Errors []CacheImportError `json:"errors,omitempty"` // the first ones only, see config.CacheImportMaxErrors
+}
+
+// data of an event of the cache change feed, the event name is the type
+type CacheWatchEvent struct {
+	Type string `json:"type"` // set, delete, expire or flush, for keys removed in bulk
+	Key string `json:"key"`
+}
+
+// removes the keys carrying any of the tags
+type CacheInvalidate struct {
+	Tags []string `json:"tags"`
+}
+
+// per key results of a batch, a failed key has Error set and does not fail the rest
+type CacheBatchGetResult struct {
+	Key string `json:"key"`
+	Hit bool `json:"hit"`
+	Value string `json:"value,omitempty"` // base64 encoded for bytes values
+	Type string `json:"type,omitempty"`
+	ContentType string `json:"contenttype,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+type CacheBatchPutResult struct {
+	Key string `json:"key"`
+	Created bool `json:"created"`
+	Error string `json:"error,omitempty"`
+}
+type CacheBatchDeleteResult struct {
+	Key string `json:"key"`
+	Deleted bool `json:"deleted"`
+	Error string `json:"error,omitempty"`
 }
 
 // get cache incoming to proxy
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"main.go/internal/const/errs"
+	"main.go/internal/dto"
+	"main.go/internal/services"
+)
+
+type AdminHandler struct {
+	AdminService *services.AdminService
+}
+
+func NewAdminHandler(service *services.AdminService) *AdminHandler {
+	return &AdminHandler{
+		AdminService: service,
+	}
+}
+
+// RegisterRoute registers the read only routes on staffRoute, open to support and admins,
+// and the routes that change users on adminRoute, open to admins only.
+func (h *AdminHandler) RegisterRoute(staffRoute *gin.RouterGroup, adminRoute *gin.RouterGroup) {
+
+	// list all users, paginated with ?limit=&offset=
+	staffRoute.GET("/users", h.ListUsers)
+	// inspect any project by its id
+	staffRoute.GET("/project/:projectid", h.InspectProject)
+
+	// suspend or reinstate a user
+	adminRoute.POST("/suspend", h.SuspendUser)
+}
+
+func (h *AdminHandler) ListUsers(ctx *gin.Context) {
+
+	limit, err := strconv.ParseInt(ctx.DefaultQuery("limit", "0"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Failed to parse given limit to int32.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(ctx.DefaultQuery("offset", "0"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Failed to parse given offset to int32.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	users, errf := h.AdminService.ListUsers(ctx, int32(limit), int32(offset))
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+			ctx.Set("error", errf.Message)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"users": users,
+	})
+}
+
+func (h *AdminHandler) SuspendUser(ctx *gin.Context) {
+
+	// 1) get user details
+	data := new(dto.SuspendUser)
+	err := ctx.Bind(data)
+	if err != nil || data.UserID == 0 {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.IncompleteForm,
+			Message: "Incomplete or invalid suspend user form.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	// set by the Authorize middleware
+	adminID := ctx.GetInt64("userID")
+
+	// 2) delegate to service
+	errf := h.AdminService.SuspendUser(ctx, adminID, data)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+			ctx.Set("error", errf.Message)
+		}
+		return
+	}
+
+	// 3) respond appropriately
+	status := "User suspended successfully"
+	if !data.Suspended {
+		status = "User reinstated successfully"
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"Status": status,
+	})
+}
+
+func (h *AdminHandler) InspectProject(ctx *gin.Context) {
+
+	projectID := ctx.Param("projectid")
+	if projectID == "" {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing project id in param (projectid).",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	project, errf := h.AdminService.InspectProject(ctx, projectID)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+			ctx.Set("error", errf.Message)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"project": project,
+	})
+}
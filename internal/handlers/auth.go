@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"main.go/internal/const/errs"
+	"main.go/internal/dto"
+	"main.go/internal/services"
+)
+
+type AuthHandler struct {
+	AuthService *services.AuthService
+}
+
+func NewAuthHandler(service *services.AuthService) *AuthHandler {
+	return &AuthHandler{
+		AuthService: service,
+	}
+}
+
+func (h *AuthHandler) RegisterRoute(authRoute *gin.RouterGroup) {
+
+	// register with email and password
+	authRoute.POST("/signup", h.Signup)
+	// get a new access and refresh token pair
+	authRoute.POST("/login", h.Login)
+	// exchange a refresh token for a new pair, the old one is rotated out
+	authRoute.POST("/refresh", h.Refresh)
+	// revoke the refresh token and every token rotated from the same login
+	authRoute.POST("/logout", h.Logout)
+}
+
+// respondWithError responds with the error if it is meant for the user, auth failures get a 401.
+func (h *AuthHandler) respondWithError(ctx *gin.Context, errf *errs.Error) {
+
+	if !errf.ToRespondWith {
+		fmt.Println(errf.Message)
+		ctx.Set("error", errf.Message)
+		return
+	}
+
+	switch errf.Type {
+	case errs.Unauthorized, errs.Expired:
+		ctx.JSON(http.StatusUnauthorized, errf)
+	case errs.ObjectExists:
+		ctx.JSON(http.StatusConflict, errf)
+	default:
+		ctx.JSON(http.StatusBadRequest, errf)
+	}
+}
+
+func (h *AuthHandler) Signup(ctx *gin.Context) {
+
+	// 1) get user details
+	data := new(dto.LoginData)
+	err := ctx.Bind(data)
+	if err != nil || data.Email == "" || data.Password == "" {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.IncompleteForm,
+			Message: "Signup form is incomplete or invalid.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	// 2) delegate to service
+	resp, errf := h.AuthService.Signup(ctx, data)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	// 3) respond appropriately
+	ctx.JSON(http.StatusCreated, resp)
+}
+
+func (h *AuthHandler) Login(ctx *gin.Context) {
+
+	// 1) get user details
+	data := new(dto.LoginData)
+	err := ctx.Bind(data)
+	if err != nil || data.Email == "" || data.Password == "" {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.IncompleteForm,
+			Message: "Login form is incomplete or invalid.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	// 2) delegate to service
+	resp, errf := h.AuthService.Login(ctx, data)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	// 3) respond appropriately
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *AuthHandler) Refresh(ctx *gin.Context) {
+
+	// 1) get user details
+	data := new(dto.RefreshData)
+	err := ctx.Bind(data)
+	if err != nil || data.Refresh == "" {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing refresh token in request body (refresh).",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	// 2) delegate to service
+	resp, errf := h.AuthService.Refresh(ctx, data.Refresh)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	// 3) respond appropriately
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *AuthHandler) Logout(ctx *gin.Context) {
+
+	// 1) get user details
+	data := new(dto.RefreshData)
+	err := ctx.Bind(data)
+	if err != nil || data.Refresh == "" {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing refresh token in request body (refresh).",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	// 2) delegate to service
+	errf := h.AuthService.Logout(ctx, data.Refresh)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	// 3) respond appropriately
+	ctx.JSON(http.StatusOK, gin.H{
+		"Status": "Logged out successfully",
+	})
+}
@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"main.go/internal/config"
 	"main.go/internal/const/errs"
 	"main.go/internal/dto"
 	"main.go/internal/services"
+	"main.go/internal/utils/cache"
 )
 
 type CacheHandler struct {
@@ -22,16 +28,121 @@ func NewCacheHandler(service *services.CacheService) *CacheHandler {
 
 func (h *CacheHandler) RegisterRoute(cacheRoute *gin.RouterGroup) {
 
-	// TODO: add a route to create a cache db first, a single user can have multiple dbs'
+	// named cache databases of the project, the other routes target one with the Cache-Database header
+	cacheRoute.POST("/databases", h.CreateDatabase)
+	cacheRoute.GET("/databases", h.ListDatabases)
+	cacheRoute.DELETE("/databases/:database", h.DropDatabase)
 
 	cacheRoute.POST("/put", h.PutNewCache)
 	cacheRoute.GET("/get/:cacheKey", h.GetCache)
+
+	// remove a key before its ttl runs out
+	cacheRoute.DELETE("/:cacheKey", h.DeleteCache)
+	// check if a key exists, no body is returned
+	cacheRoute.HEAD("/:cacheKey", h.CacheExists)
+	// remaining ttl of a key
+	cacheRoute.GET("/ttl/:cacheKey", h.CacheTTL)
+	// change the ttl of a key
+	cacheRoute.POST("/expire/:cacheKey", h.ExpireCache)
+
+	// batches of keys in a single request, results are per key
+	cacheRoute.POST("/mget", h.GetCacheBatch)
+	cacheRoute.POST("/mset", h.PutCacheBatch)
+	cacheRoute.POST("/mdel", h.DeleteCacheBatch)
+
+	// atomic counters and compare-and-swap on the ETag returned by a get
+	cacheRoute.POST("/incr/:cacheKey", h.IncrCache)
+	cacheRoute.POST("/decr/:cacheKey", h.DecrCache)
+	cacheRoute.POST("/cas", h.SwapCache)
+
+	// remove all the keys put with any of the tags
+	cacheRoute.POST("/invalidate", h.InvalidateCache)
+
+	// list the keys by prefix, a page at a time, or remove them all
+	// a key named 'keys' can still be removed with /mdel
+	cacheRoute.GET("/keys", h.ListKeys)
+	cacheRoute.DELETE("/keys", h.DeleteKeys)
+
+	// server-sent events of the changes of keys matching ?pattern=
+	cacheRoute.GET("/watch", h.WatchCache)
+
+	// snapshot of all the keys as newline delimited json, and restoring one, ?overwrite=true replaces existing keys
+	cacheRoute.GET("/export", h.ExportCache)
+	cacheRoute.POST("/import", h.ImportCache)
+}
+
+// extractKeys gets the cache key from the params and the api key from the headers.
+// any returned error is directly included in the response as returned, with a 400 or 401 respectively
+func (h *CacheHandler) extractKeys(ctx *gin.Context) (string, string, int, *errs.Error) {
+
+	cacheKey := ctx.Param("cacheKey")
+	if cacheKey == "" {
+		return "", "", http.StatusBadRequest, &errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing cache key in param (cacheKey).",
+			ToRespondWith: true,
+		}
+	}
+
+	apiKey := ctx.GetHeader("API-Key")
+	if apiKey == "" {
+		return "", "", http.StatusUnauthorized, &errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing API key in request headers.",
+			ToRespondWith: true,
+		}
+	}
+
+	return cacheKey, apiKey, 0, nil
+}
+
+// respondWithError responds with the error if it is meant for the user, a missing key gets a 404,
+// a key that already exists or a compare-and-swap on a stale ETag a 409 and a value too large a 413.
+func (h *CacheHandler) respondWithError(ctx *gin.Context, errf *errs.Error) {
+
+	if !errf.ToRespondWith {
+		fmt.Println(errf.Message)
+		ctx.Set("error", errf.Message)
+		return
+	}
+
+	switch errf.Type {
+	case errs.NotFound:
+		ctx.JSON(http.StatusNotFound, errf)
+	case errs.ObjectExists, errs.Conflict:
+		ctx.JSON(http.StatusConflict, errf)
+	case errs.TooLarge:
+		ctx.JSON(http.StatusRequestEntityTooLarge, errf)
+	default:
+		ctx.JSON(http.StatusBadRequest, errf)
+	}
+}
+
+// bodyTooLarge responds with a 413 if err comes from reading a body over the limit set with http.MaxBytesReader.
+func (h *CacheHandler) bodyTooLarge(ctx *gin.Context, err error) bool {
+
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return false
+	}
+
+	ctx.JSON(http.StatusRequestEntityTooLarge, errs.Error{
+		Type: errs.TooLarge,
+		Message: fmt.Sprintf("Request body is larger than the max of %d bytes.", maxBytesErr.Limit),
+		ToRespondWith: true,
+	})
+	return true
 }
 
 func (h *CacheHandler) PutNewCache(ctx *gin.Context) {
 
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, config.CacheMaxBodySize)
+
 	data := new(dto.SetCacheKeyIncoming)
 	err := ctx.Bind(data)
+	if h.bodyTooLarge(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, errs.Error{
 			Type: errs.MissingRequiredField,
@@ -52,35 +163,167 @@ func (h *CacheHandler) PutNewCache(ctx *gin.Context) {
 		return
 	}
 
-	errf := h.CacheService.PutNewCache(ctx, data, apiKey)
+	created, errf := h.CacheService.PutNewCache(ctx, data, apiKey, ctx.GetHeader("Cache-Database"))
 	if errf != nil {
-		if errf.ToRespondWith {
-			ctx.JSON(http.StatusBadRequest, errf)
-		} else {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	if created {
+		ctx.JSON(http.StatusCreated, gin.H{
+			"status": "Cache key-value has been created.",
+			"created": true,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "Cache key-value has been replaced.",
+		"created": false,
+	})
+}
+
+func (h *CacheHandler) GetCache(ctx *gin.Context) {
+
+	cacheKey, apiKey, status, errf := h.extractKeys(ctx)
+	if errf != nil {
+		ctx.JSON(status, errf)
+		return
+	}
+
+	value, etag, errf := h.CacheService.GetCache(ctx, apiKey, ctx.GetHeader("Cache-Database"), cacheKey)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.Header("ETag", "\"" + etag + "\"")
+	ctx.Header("Cache-Value-Type", value.Type)
+	ctx.Data(http.StatusOK, value.ContentType, value.Data)
+}
+
+func (h *CacheHandler) DeleteCache(ctx *gin.Context) {
+
+	cacheKey, apiKey, status, errf := h.extractKeys(ctx)
+	if errf != nil {
+		ctx.JSON(status, errf)
+		return
+	}
+
+	deleted, errf := h.CacheService.DeleteCache(ctx, apiKey, ctx.GetHeader("Cache-Database"), cacheKey)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"deleted": deleted,
+	})
+}
+
+func (h *CacheHandler) CacheExists(ctx *gin.Context) {
+
+	cacheKey, apiKey, status, errf := h.extractKeys(ctx)
+	if errf != nil {
+		ctx.Status(status)
+		return
+	}
+
+	exists, errf := h.CacheService.CacheExists(ctx, apiKey, ctx.GetHeader("Cache-Database"), cacheKey)
+	if errf != nil {
+		// a HEAD response has no body, only the status is left
+		switch {
+		case !errf.ToRespondWith:
 			fmt.Println(errf.Message)
 			ctx.Set("error", errf.Message)
+			ctx.Status(http.StatusInternalServerError)
+		case errf.Type == errs.Unauthorized || errf.Type == errs.Expired:
+			ctx.Status(http.StatusUnauthorized)
+		default:
+			ctx.Status(http.StatusBadRequest)
 		}
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{
-		"status": "Cache key-value has been created or updated.",
+	if !exists {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+func (h *CacheHandler) CacheTTL(ctx *gin.Context) {
+
+	cacheKey, apiKey, status, errf := h.extractKeys(ctx)
+	if errf != nil {
+		ctx.JSON(status, errf)
+		return
+	}
+
+	ttl, errf := h.CacheService.CacheTTL(ctx, apiKey, ctx.GetHeader("Cache-Database"), cacheKey)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	// in milliseconds, -1 if the key never expires
+	ctx.JSON(http.StatusOK, gin.H{
+		"ttl": ttl,
 	})
 }
 
-func (h *CacheHandler) GetCache(ctx *gin.Context) {
+func (h *CacheHandler) ExpireCache(ctx *gin.Context) {
 
-	cacheKey := ctx.Param("cacheKey")
-	if cacheKey == "" {
+	data := new(dto.ExpireCacheKey)
+	err := ctx.Bind(data)
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, errs.Error{
 			Type: errs.MissingRequiredField,
-			Message: "Missing cache key in param (cacheKey).",
+			Message: "Invalid expire cache form, missing or invalid ttl.",
 			ToRespondWith: true,
 		})
 		return
 	}
 
-	// get api key
+	cacheKey, apiKey, status, errf := h.extractKeys(ctx)
+	if errf != nil {
+		ctx.JSON(status, errf)
+		return
+	}
+
+	errf = h.CacheService.ExpireCache(ctx, apiKey, ctx.GetHeader("Cache-Database"), cacheKey, data.TTL)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "Cache key ttl has been updated.",
+	})
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// BATCH OPERATIONS
+
+// bindBody binds the json body into data and gets the api key from the headers.
+// when false is returned the response has already been written
+func (h *CacheHandler) bindBody(ctx *gin.Context, data any) (string, bool) {
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, config.CacheMaxBatchBodySize)
+
+	err := ctx.ShouldBindJSON(data)
+	if h.bodyTooLarge(ctx, err) {
+		return "", false
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Invalid cache request body, missing or invalid fields.",
+			ToRespondWith: true,
+		})
+		return "", false
+	}
+
 	apiKey := ctx.GetHeader("API-Key")
 	if apiKey == "" {
 		ctx.JSON(http.StatusUnauthorized, errs.Error{
@@ -88,19 +331,477 @@ func (h *CacheHandler) GetCache(ctx *gin.Context) {
 			Message: "Missing API key in request headers.",
 			ToRespondWith: true,
 		})
+		return "", false
+	}
+
+	return apiKey, true
+}
+
+func (h *CacheHandler) GetCacheBatch(ctx *gin.Context) {
+
+	data := new(dto.CacheBatchKeys)
+	apiKey, ok := h.bindBody(ctx, data)
+	if !ok {
 		return
-	}	
+	}
 
-	errf := h.CacheService.GetCache(ctx, apiKey, cacheKey)
+	results, errf := h.CacheService.GetCacheBatch(ctx, apiKey, ctx.GetHeader("Cache-Database"), data.Keys)
 	if errf != nil {
-		if errf.ToRespondWith {
-			ctx.JSON(http.StatusBadRequest, errf)
-		} else {
-			fmt.Println(errf.Message)
-			ctx.Set("error", errf.Message)
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	hits := 0
+	for _, result := range results {
+		if result.Hit {
+			hits++
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"hits": hits,
+		"misses": len(results) - hits,
+	})
+}
+
+func (h *CacheHandler) PutCacheBatch(ctx *gin.Context) {
+
+	data := new(dto.CacheBatchPut)
+	apiKey, ok := h.bindBody(ctx, data)
+	if !ok {
+		return
+	}
+
+	results, errf := h.CacheService.PutCacheBatch(ctx, apiKey, ctx.GetHeader("Cache-Database"), data.Entries)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"results": results,
+	})
+}
+
+func (h *CacheHandler) DeleteCacheBatch(ctx *gin.Context) {
+
+	data := new(dto.CacheBatchKeys)
+	apiKey, ok := h.bindBody(ctx, data)
+	if !ok {
+		return
+	}
+
+	results, errf := h.CacheService.DeleteCacheBatch(ctx, apiKey, ctx.GetHeader("Cache-Database"), data.Keys)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"results": results,
+	})
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// ATOMIC OPERATIONS
+
+func (h *CacheHandler) IncrCache(ctx *gin.Context) {
+	h.incrBy(ctx, 1)
+}
+
+func (h *CacheHandler) DecrCache(ctx *gin.Context) {
+	h.incrBy(ctx, -1)
+}
+
+// incrBy adds the 'by' of the body, 1 if not set, in the direction of sign.
+func (h *CacheHandler) incrBy(ctx *gin.Context, sign int64) {
+
+	data := new(dto.IncrCacheKey)
+	if ctx.Request.ContentLength != 0 {
+		err := ctx.ShouldBindJSON(data)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errs.Error{
+				Type: errs.InvalidFormat,
+				Message: "Invalid increment body, 'by' and 'ttl' must be integers.",
+				ToRespondWith: true,
+			})
+			return
+		}
+	}
+
+	if data.By < 0 {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Increment cannot be negative, use decr instead.",
+			ToRespondWith: true,
+		})
+		return
+	}
+	if data.By == 0 {
+		data.By = 1
+	}
+
+	cacheKey, apiKey, status, errf := h.extractKeys(ctx)
+	if errf != nil {
+		ctx.JSON(status, errf)
+		return
+	}
+
+	value, errf := h.CacheService.IncrCache(ctx, apiKey, ctx.GetHeader("Cache-Database"), cacheKey, sign * data.By, data.TTL)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"value": value,
+	})
+}
+
+func (h *CacheHandler) SwapCache(ctx *gin.Context) {
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, config.CacheMaxBodySize)
+
+	data := new(dto.SwapCacheKey)
+	err := ctx.ShouldBindJSON(data)
+	if h.bodyTooLarge(ctx, err) {
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Invalid compare-and-swap body, missing or invalid fields.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	apiKey := ctx.GetHeader("API-Key")
+	if apiKey == "" {
+		ctx.JSON(http.StatusUnauthorized, errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing API key in request headers.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	errf := h.CacheService.SwapCache(ctx, data, apiKey, ctx.GetHeader("Cache-Database"))
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "Cache value has been swapped.",
+	})
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// CACHE DATABASES
+
+// extractAPIKey gets the api key from the headers, any returned error is meant to be sent with a 401.
+func (h *CacheHandler) extractAPIKey(ctx *gin.Context) (string, *errs.Error) {
+
+	apiKey := ctx.GetHeader("API-Key")
+	if apiKey == "" {
+		return "", &errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing API key in request headers.",
+			ToRespondWith: true,
 		}
+	}
+	return apiKey, nil
+}
+
+func (h *CacheHandler) CreateDatabase(ctx *gin.Context) {
+
+	data := new(dto.NewCacheDatabase)
+	apiKey, ok := h.bindBody(ctx, data)
+	if !ok {
+		return
+	}
+
+	database, errf := h.CacheService.CreateDatabase(ctx, apiKey, data)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
 		return
 	}
 
+	ctx.JSON(http.StatusCreated, gin.H{
+		"database": database,
+	})
+}
+
+func (h *CacheHandler) ListDatabases(ctx *gin.Context) {
+
+	apiKey, errf := h.extractAPIKey(ctx)
+	if errf != nil {
+		ctx.JSON(http.StatusUnauthorized, errf)
+		return
+	}
+
+	databases, errf := h.CacheService.ListDatabases(ctx, apiKey)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"databases": databases,
+	})
+}
+
+func (h *CacheHandler) DropDatabase(ctx *gin.Context) {
+
+	apiKey, errf := h.extractAPIKey(ctx)
+	if errf != nil {
+		ctx.JSON(http.StatusUnauthorized, errf)
+		return
+	}
+
+	database := ctx.Param("database")
+	removed, errf := h.CacheService.DropDatabase(ctx, apiKey, database)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"dropped": database,
+		"removed": removed,
+	})
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// TAGS
+
+func (h *CacheHandler) InvalidateCache(ctx *gin.Context) {
+
+	data := new(dto.CacheInvalidate)
+	apiKey, ok := h.bindBody(ctx, data)
+	if !ok {
+		return
+	}
+
+	removed, errf := h.CacheService.InvalidateCache(ctx, apiKey, ctx.GetHeader("Cache-Database"), data.Tags)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"removed": removed,
+	})
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// KEY LISTING
+
+func (h *CacheHandler) ListKeys(ctx *gin.Context) {
+
+	limit, err := strconv.ParseInt(ctx.DefaultQuery("limit", "0"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Failed to parse given limit to int32.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	apiKey, errf := h.extractAPIKey(ctx)
+	if errf != nil {
+		ctx.JSON(http.StatusUnauthorized, errf)
+		return
+	}
+
+	page, errf := h.CacheService.ListKeys(ctx, apiKey, ctx.GetHeader("Cache-Database"), ctx.Query("prefix"), ctx.Query("cursor"), int(limit))
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// DeleteKeys needs the prefix query even if empty, '?prefix=' removes every key of the database.
+func (h *CacheHandler) DeleteKeys(ctx *gin.Context) {
+
+	prefix, given := ctx.GetQuery("prefix")
+	if !given {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing prefix in query, give an empty one to remove every key.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	apiKey, errf := h.extractAPIKey(ctx)
+	if errf != nil {
+		ctx.JSON(http.StatusUnauthorized, errf)
+		return
+	}
+
+	removed, errf := h.CacheService.DeleteKeys(ctx, apiKey, ctx.GetHeader("Cache-Database"), prefix)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"removed": removed,
+	})
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// CHANGE FEED
+
+// WatchCache streams the changes of keys as server-sent events, with ids to resume from with the Last-Event-ID header.
+// A 'reset' event comes first when events were missed since that id, the watched keys should then be read again.
+// Keys removed in bulk come as a single 'flush' event, with the prefix of the removed keys or empty when any watched key may be gone.
+// The stream ends if the client falls too far behind, it can then reconnect to resume.
+func (h *CacheHandler) WatchCache(ctx *gin.Context) {
+
+	apiKey, errf := h.extractAPIKey(ctx)
+	if errf != nil {
+		ctx.JSON(http.StatusUnauthorized, errf)
+		return
+	}
+
+	watcher, missed, complete, errf := h.CacheService.WatchCache(ctx, apiKey, ctx.GetHeader("Cache-Database"), ctx.Query("pattern"), ctx.GetHeader("Last-Event-ID"))
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+	defer watcher.Close()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
 	ctx.Status(http.StatusOK)
-}
\ No newline at end of file
+
+	if !complete {
+		fmt.Fprint(ctx.Writer, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range missed {
+		if !h.writeEvent(ctx, event) {
+			return
+		}
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(config.CacheWatchHeartbeat * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, open := <-watcher.Events:
+			if !open {
+				return
+			}
+			if !h.writeEvent(ctx, event) {
+				return
+			}
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(ctx.Writer, ": ping\n\n")
+			if err != nil {
+				return
+			}
+		case <-ctx.Request.Context().Done():
+			return
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+// writeEvent writes the event in the server-sent events format, false if the client is gone.
+func (h *CacheHandler) writeEvent(ctx *gin.Context, event cache.Event) bool {
+
+	data, err := json.Marshal(dto.CacheWatchEvent{
+		Type: event.Type,
+		Key: event.Key,
+	})
+	if err != nil {
+		return false
+	}
+
+	_, err = fmt.Fprintf(ctx.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err == nil
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// EXPORT AND IMPORT
+
+func (h *CacheHandler) ExportCache(ctx *gin.Context) {
+
+	apiKey, errf := h.extractAPIKey(ctx)
+	if errf != nil {
+		ctx.JSON(http.StatusUnauthorized, errf)
+		return
+	}
+
+	started := false
+	start := func() {
+		ctx.Header("Content-Type", "application/x-ndjson")
+		ctx.Header("Content-Disposition", `attachment; filename="cache-export.ndjson"`)
+		ctx.Status(http.StatusOK)
+		started = true
+	}
+
+	encoder := json.NewEncoder(ctx.Writer)
+	encoder.SetEscapeHTML(false)
+
+	errf = h.CacheService.ExportCache(ctx, apiKey, ctx.GetHeader("Cache-Database"), func(entry *dto.CacheExportEntry) error {
+		if !started {
+			start()
+		}
+		return encoder.Encode(entry)
+	})
+	if errf != nil {
+		if !started {
+			h.respondWithError(ctx, errf)
+			return
+		}
+		// too late for an error status, a last line without a key tells that the export is incomplete
+		fmt.Println(errf.Message)
+		encoder.Encode(gin.H{
+			"error": "Export failed before all keys were written.",
+		})
+		return
+	}
+
+	if !started {
+		start()
+	}
+}
+
+func (h *CacheHandler) ImportCache(ctx *gin.Context) {
+
+	overwrite, err := strconv.ParseBool(ctx.DefaultQuery("overwrite", "false"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Failed to parse given overwrite to bool.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	apiKey, errf := h.extractAPIKey(ctx)
+	if errf != nil {
+		ctx.JSON(http.StatusUnauthorized, errf)
+		return
+	}
+
+	result, errf := h.CacheService.ImportCache(ctx, apiKey, ctx.GetHeader("Cache-Database"), ctx.Request.Body, overwrite)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"main.go/internal/const/errs"
 	"main.go/internal/dto"
 	"main.go/internal/services"
@@ -20,26 +22,41 @@ func NewPublicHandler(service *services.PublicService) *PublicHandler {
 	}
 }
 
-func (h *PublicHandler) RegisterRoute(publicRoute *gin.RouterGroup) {
+// RegisterRoute registers the signup on publicRoute, open to any authenticated caller,
+// and the other routes on userRoute, open to registered users that are not suspended.
+func (h *PublicHandler) RegisterRoute(publicRoute *gin.RouterGroup, userRoute *gin.RouterGroup) {
 
 	publicRoute.POST("/newuser", h.NewUser)
+	// confirm the email with the token from the confirmation link
+	userRoute.POST("/confirm", h.ConfirmEmail)
+	// mail a new confirmation link
+	userRoute.POST("/resendconfirm", h.ResendConfirmation)
 
 	// get all projects for a user
-	publicRoute.GET("/allprojects/:clerkID", h.AllProjects)
+	userRoute.GET("/allprojects/:clerkID", h.AllProjects)
 	// to create a new project for an existing user
-	publicRoute.POST("/newproject", h.NewProject)
-	// toggle confirmed services for a project
-	publicRoute.POST("/toggleservice", h.ToggleService)
+	userRoute.POST("/newproject", h.NewProject)
+	// toggle confirmed services for a single key of a project
+	userRoute.POST("/toggleservice", h.ToggleService)
 	// delete a project completely
-	publicRoute.POST("/deleteproject", h.DeleteService)
+	userRoute.POST("/deleteproject", h.DeleteService)
 
+	// create an additional key for a project
+	userRoute.POST("/newkey", h.NewKey)
+	// get all keys of a project
+	userRoute.GET("/allkeys/:projectname", h.AllKeys)
+	// revoke a single key of a project
+	userRoute.POST("/revokekey", h.RevokeKey)
+	// reissue an api key from the old one
+	userRoute.POST("/renewkey", h.RenewKey)
 
-	publicRoute.GET("/analytics/storage/:stream/:projectname/:scope/:interval", h.StorageAnalytics)
-	publicRoute.GET("/analytics/cache/:stream/:projectname/:scope/:interval", h.CacheAnalytics)
+
+	userRoute.GET("/analytics/storage/:stream/:projectname/:scope/:interval", h.StorageAnalytics)
+	userRoute.GET("/analytics/cache/:stream/:projectname/:scope/:interval", h.CacheAnalytics)
 }
 
 
-// extractUserID extracts the clerk ID and other required parameters from the context with explicit type assertion.
+// extractClerkID extracts the clerk ID and other required parameters from the context with explicit type assertion.
 // any returned error is directly included in the response as returned
 func (h *PublicHandler) extractClerkID(ctx *gin.Context) (string, *errs.Error) {
 
@@ -57,21 +74,50 @@ func (h *PublicHandler) extractClerkID(ctx *gin.Context) (string, *errs.Error) {
 	return clerkIDstr, nil 
 }
 
+// extractUserID returns the ID of the authenticated user, set by Authorize on the user routes.
+// Outside of them, natively authenticated requests carry the user ID itself, clerk authenticated ones carry the clerk ID which is looked up.
+func (h *PublicHandler) extractUserID(ctx *gin.Context) (int64, *errs.Error) {
 
-func (h *PublicHandler) NewUser(ctx *gin.Context) {
+	if userID, exists := ctx.Get("userID"); exists {
+		if id, ok := userID.(int64); ok {
+			return id, nil
+		}
+	}
 
-	signupData := new(dto.SignupData)
-	err := ctx.Bind(signupData)
+	clerkID, errf := h.extractClerkID(ctx)
+	if errf != nil {
+		return 0, errf
+	}
+
+	userID, err := h.PublicService.GetUserIDFromClerkID(ctx, clerkID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errs.Error{
-			Type: errs.IncompleteForm,
-			Message: "Signup form is incomplete or invalid.",
-			ToRespondWith: true,
-		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, &errs.Error{
+				Type: errs.NotFound,
+				Message: "No such user found. Please register first.",
+				ToRespondWith: true,
+			}
+		}
+		return 0, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to get user id from clerk id : " + err.Error(),
+		}
+	}
+
+	return userID, nil
+}
+
+
+// NewUser registers the clerk user of the token, nothing is taken from the body.
+func (h *PublicHandler) NewUser(ctx *gin.Context) {
+
+	clerkID, errf := h.extractClerkID(ctx)
+	if errf != nil {
+		ctx.JSON(http.StatusUnauthorized, errf)
 		return
 	}
 
-	exists, errf := h.PublicService.NewUser(ctx, signupData)
+	exists, errf := h.PublicService.NewUser(ctx, clerkID)
 	if errf != nil {
 		if errf.ToRespondWith {
 			ctx.JSON(http.StatusBadRequest, errf)
@@ -90,6 +136,74 @@ func (h *PublicHandler) NewUser(ctx *gin.Context) {
 	ctx.Status(http.StatusCreated)
 }
 
+func (h *PublicHandler) ConfirmEmail(ctx *gin.Context) {
+
+	// 1) get user details
+	data := new(dto.ConfirmData)
+	err := ctx.Bind(data)
+	if err != nil || data.Token == "" {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing confirmation token in request body (token).",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	userID, errf := h.extractUserID(ctx)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
+
+	// 2) delegate to service
+	errf = h.PublicService.ConfirmEmail(ctx, userID, data.Token)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
+
+	// 3) respond appropriately
+	ctx.JSON(http.StatusOK, gin.H{
+		"Status": "Email confirmed successfully",
+	})
+}
+
+func (h *PublicHandler) ResendConfirmation(ctx *gin.Context) {
+
+	userID, errf := h.extractUserID(ctx)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
+
+	errf = h.PublicService.ResendConfirmation(ctx, userID)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"Status": "Confirmation email sent",
+	})
+}
+
 func (h *PublicHandler) NewProject(ctx *gin.Context) {
 
 	// 1) get user details
@@ -104,14 +218,13 @@ func (h *PublicHandler) NewProject(ctx *gin.Context) {
 		return
 	}
 
-	clerkID, errf := h.extractClerkID(ctx)
+	userID, errf := h.extractUserID(ctx)
 	if errf != nil {
-		ctx.JSON(http.StatusBadRequest, errf)
-		return
-	}
-
-	userID, err := h.PublicService.GetUserIDFromClerkID(ctx, clerkID)
-	if err != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
 		return
 	}
 
@@ -135,37 +248,68 @@ func (h *PublicHandler) NewProject(ctx *gin.Context) {
 func (h *PublicHandler) ToggleService(ctx *gin.Context) {
 	
 	// 1) get user details
-	data := new(dto.NewProject)
+	data := new(dto.ToggleKey)
 	err := ctx.Bind(data)
-	if err != nil {
+	if err != nil || data.ID == "" {
 		ctx.JSON(http.StatusBadRequest, errs.Error{
 			Type: errs.IncompleteForm,
-			Message: "Incomplete or invalid new service form.",
+			Message: "Incomplete or invalid toggle service form.",
 			ToRespondWith: true,
 		})
 		return
 	}
 
-	// TODO: extract userid
+	userID, errf := h.extractUserID(ctx)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
 
-	clerkID, errf := h.extractClerkID(ctx)
+	// 2) delegate to service
+	keyResp, errf := h.PublicService.ToggleService(ctx, userID, data)
 	if errf != nil {
-		ctx.JSON(http.StatusBadRequest, errf)
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
 		return
 	}
 
-	userID, err := h.PublicService.GetUserIDFromClerkID(ctx, clerkID)
-	if err != nil {
+	// 3) respond appropriately
+	ctx.JSON(http.StatusCreated, keyResp)
+}
+
+func (h *PublicHandler) NewKey(ctx *gin.Context) {
+
+	// 1) get user details
+	data := new(dto.NewKey)
+	err := ctx.Bind(data)
+	if err != nil || data.Name == "" {
 		ctx.JSON(http.StatusBadRequest, errs.Error{
-			Type: errs.MissingRequiredField,
-			Message: "Missing headers (clerkID or secret_key).",
+			Type: errs.IncompleteForm,
+			Message: "Incomplete or invalid new key form.",
 			ToRespondWith: true,
 		})
 		return
 	}
 
+	userID, errf := h.extractUserID(ctx)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
+
 	// 2) delegate to service
-	keyResp, errf := h.PublicService.ToggleService(ctx, userID, data)
+	keyResp, errf := h.PublicService.NewKey(ctx, userID, data)
 	if errf != nil {
 		if errf.ToRespondWith {
 			ctx.JSON(http.StatusBadRequest, errf)
@@ -179,30 +323,144 @@ func (h *PublicHandler) ToggleService(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, keyResp)
 }
 
-func (h *PublicHandler) DeleteService(ctx *gin.Context) {
+func (h *PublicHandler) AllKeys(ctx *gin.Context) {
+
+	projectName := ctx.Param("projectname")
+	if projectName == "" {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing project name in param (projectname).",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	userID, errf := h.extractUserID(ctx)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
+
+	allKeys, errf := h.PublicService.AllKeys(ctx, userID, projectName)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"allkeys": allKeys,
+	})
+}
+
+func (h *PublicHandler) RevokeKey(ctx *gin.Context) {
 
 	// 1) get user details
-	data := new(dto.NewProject)
+	data := new(dto.RevokeKey)
 	err := ctx.Bind(data)
-	if err != nil {
+	if err != nil || data.Name == "" || data.ID == "" {
 		ctx.JSON(http.StatusBadRequest, errs.Error{
 			Type: errs.IncompleteForm,
-			Message: "Incomplete or invalid new service form.",
+			Message: "Incomplete or invalid revoke key form.",
 			ToRespondWith: true,
 		})
 		return
 	}
 
-	// TODO: extract userid
+	userID, errf := h.extractUserID(ctx)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
 
-	clerkID, errf := h.extractClerkID(ctx)
+	// 2) delegate to service
+	errf = h.PublicService.RevokeKey(ctx, userID, data)
 	if errf != nil {
-		ctx.JSON(http.StatusBadRequest, errf)
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
 		return
 	}
 
-	userID, err := h.PublicService.GetUserIDFromClerkID(ctx, clerkID)
+	// 3) respond appropriately
+	ctx.JSON(http.StatusOK, gin.H{
+		"Status": "Key revoked successfully",
+	})
+}
+
+func (h *PublicHandler) RenewKey(ctx *gin.Context) {
+
+	// 1) get user details
+	data := new(dto.RenewKey)
+	err := ctx.Bind(data)
+	if err != nil || data.Key == "" {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.IncompleteForm,
+			Message: "Incomplete or invalid renew key form.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	userID, errf := h.extractUserID(ctx)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
+
+	// 2) delegate to service
+	keyResp, errf := h.PublicService.RenewKey(ctx, userID, data)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
+		return
+	}
+
+	// 3) respond appropriately
+	ctx.JSON(http.StatusCreated, keyResp)
+}
+
+func (h *PublicHandler) DeleteService(ctx *gin.Context) {
+
+	// 1) get user details
+	data := new(dto.NewProject)
+	err := ctx.Bind(data)
 	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.IncompleteForm,
+			Message: "Incomplete or invalid new service form.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	userID, errf := h.extractUserID(ctx)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
 		return
 	}
 
@@ -225,19 +483,15 @@ func (h *PublicHandler) DeleteService(ctx *gin.Context) {
 
 func (h *PublicHandler) AllProjects(ctx *gin.Context) {
 
-	clerkID, errf := h.extractClerkID(ctx)
+	userID, errf := h.extractUserID(ctx)
 	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
 		return
 	}
-
-	fmt.Println(clerkID)
-
-	userID, err := h.PublicService.GetUserIDFromClerkID(ctx, clerkID)
-	if err != nil {
-		return
-	}
-
-	fmt.Println(userID)
 	
 	allProjs, errf := h.PublicService.AllProjects(ctx, userID)
 	if errf != nil {
@@ -273,13 +527,13 @@ func (h *PublicHandler) StorageAnalytics(ctx *gin.Context) {
 		return
 	}
 
-	clerkID, errf := h.extractClerkID(ctx)
+	userID, errf := h.extractUserID(ctx)
 	if errf != nil {
-		return
-	}
-
-	userID, err := h.PublicService.GetUserIDFromClerkID(ctx, clerkID)
-	if err != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
 		return
 	}
 
@@ -315,13 +569,13 @@ func (h *PublicHandler) CacheAnalytics(ctx *gin.Context) {
 		return
 	}
 
-	clerkID, errf := h.extractClerkID(ctx)
+	userID, errf := h.extractUserID(ctx)
 	if errf != nil {
-		return
-	}
-
-	userID, err := h.PublicService.GetUserIDFromClerkID(ctx, clerkID)
-	if err != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			fmt.Println(errf.Message)
+		}
 		return
 	}
 
@@ -3,6 +3,8 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"main.go/internal/const/errs"
@@ -21,10 +23,59 @@ func NewStorageHandler(service *services.StorageService) *StorageHandler {
 
 func (h *StorageHandler) RegisterRoute(storageRoute *gin.RouterGroup) {
 	storageRoute.POST("/upload", h.UploadNewFile)
-	storageRoute.GET("/download/:filekey", h.DownloadFile)
+	// file keys can hold '/', so they are matched up to the end of the path
+	storageRoute.GET("/download/*filekey", h.DownloadFile)
+
+	// size, content type, ETag and modification time of a file as the headers of its download, no body is returned
+	storageRoute.HEAD("/download/*filekey", h.StatFile)
+	storageRoute.DELETE("/*filekey", h.DeleteFile)
+	// files by prefix, a page at a time
+	storageRoute.GET("/list", h.ListFiles)
 }
 
+// extractKeys gets the file key from the params and the api key from the headers.
+// any returned error is directly included in the response as returned, with a 400 or 401 respectively
+func (h *StorageHandler) extractKeys(ctx *gin.Context) (string, string, int, *errs.Error) {
+
+	fileKey := strings.TrimPrefix(ctx.Param("filekey"), "/")
+	if fileKey == "" {
+		return "", "", http.StatusBadRequest, &errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "File key is invalid or missing.",
+			ToRespondWith: true,
+		}
+	}
+
+	apiKey := ctx.GetHeader("API-Key")
+	if apiKey == "" {
+		return "", "", http.StatusUnauthorized, &errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing API key in request headers.",
+			ToRespondWith: true,
+		}
+	}
+
+	return fileKey, apiKey, 0, nil
+}
+
+// respondWithError responds with the error if it is meant for the user, a missing file gets a 404.
+func (h *StorageHandler) respondWithError(ctx *gin.Context, errf *errs.Error) {
+
+	if !errf.ToRespondWith {
+		fmt.Println(errf.Message)
+		ctx.Set("error", errf.Message)
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
 
+	if errf.Type == errs.NotFound {
+		ctx.JSON(http.StatusNotFound, errf)
+	} else {
+		ctx.JSON(http.StatusBadRequest, errf)
+	}
+}
+
+// UploadNewFile stores the file under the 'key' form field, or under its file name if not given.
 func (h *StorageHandler) UploadNewFile(ctx *gin.Context) {
 
 	// get api key
@@ -48,52 +99,100 @@ func (h *StorageHandler) UploadNewFile(ctx *gin.Context) {
 		return
 	}
 
-	errf := h.StorageService.UploadNewFile(ctx, apiKey, file)
+	object, errf := h.StorageService.UploadNewFile(ctx, apiKey, file, ctx.PostForm("key"))
 	if errf != nil {
-		if errf.ToRespondWith {
-			ctx.JSON(http.StatusBadRequest, errf)
-		} else {
-			ctx.Set("error", errf.Message)
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, object)
+}
+
+func (h *StorageHandler) DownloadFile(ctx *gin.Context) {
+
+	fileKey, apiKey, status, errf := h.extractKeys(ctx)
+	if errf != nil {
+		ctx.JSON(status, errf)
+		return
+	}
+
+	errf = h.StorageService.DownloadFile(ctx, apiKey, fileKey)
+	if errf != nil {
+		// once streaming started the status is already sent, only the error is kept
+		h.respondWithError(ctx, errf)
+		return
+	}
+}
+
+func (h *StorageHandler) StatFile(ctx *gin.Context) {
+
+	fileKey, apiKey, status, errf := h.extractKeys(ctx)
+	if errf != nil {
+		ctx.Status(status)
+		return
+	}
+
+	errf = h.StorageService.StatFile(ctx, apiKey, fileKey)
+	if errf != nil {
+		if !errf.ToRespondWith {
 			fmt.Println(errf.Message)
+			ctx.Set("error", errf.Message)
 			ctx.Status(http.StatusInternalServerError)
-		} 
+		} else if errf.Type == errs.NotFound {
+			ctx.Status(http.StatusNotFound)
+		} else {
+			ctx.Status(http.StatusBadRequest)
+		}
 		return
 	}
 }
 
-func (h *StorageHandler) DownloadFile(ctx *gin.Context) {
+func (h *StorageHandler) DeleteFile(ctx *gin.Context) {
 
-	// get api key
-	apiKey := ctx.GetHeader("API-Key")
-	if apiKey == "" {
-		ctx.JSON(http.StatusUnauthorized, errs.Error{
-			Type: errs.MissingRequiredField,
-			Message: "Missing API key in request headers.",
+	fileKey, apiKey, status, errf := h.extractKeys(ctx)
+	if errf != nil {
+		ctx.JSON(status, errf)
+		return
+	}
+
+	errf = h.StorageService.DeleteFile(ctx, apiKey, fileKey)
+	if errf != nil {
+		h.respondWithError(ctx, errf)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"deleted": true,
+	})
+}
+
+func (h *StorageHandler) ListFiles(ctx *gin.Context) {
+
+	limit, err := strconv.ParseInt(ctx.DefaultQuery("limit", "0"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Failed to parse given limit to int32.",
 			ToRespondWith: true,
 		})
 		return
 	}
 
-	fileKey := ctx.Param("filekey")
-	if fileKey == "" {
-		ctx.JSON(http.StatusBadRequest, errs.Error{
+	apiKey := ctx.GetHeader("API-Key")
+	if apiKey == "" {
+		ctx.JSON(http.StatusUnauthorized, errs.Error{
 			Type: errs.MissingRequiredField,
-			Message: "File key is invalid or missing.",
+			Message: "Missing API key in request headers.",
 			ToRespondWith: true,
 		})
 		return
 	}
 
-	errf := h.StorageService.DownloadFile(ctx, apiKey, fileKey)
+	page, errf := h.StorageService.ListFiles(ctx, apiKey, ctx.Query("prefix"), ctx.Query("cursor"), int(limit))
 	if errf != nil {
-		fmt.Println(errf.Message)
-		if errf.ToRespondWith {
-			ctx.JSON(http.StatusBadRequest, errf)
-		} else {
-			ctx.Set("error", errf.Message)
-			ctx.Status(http.StatusInternalServerError)
-		} 
+		h.respondWithError(ctx, errf)
 		return
 	}
 
-}
\ No newline at end of file
+	ctx.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStorageRoutesMatchNestedKeys(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// requests without an api key are turned away before reaching the service
+	NewStorageHandler(nil).RegisterRoute(router.Group("/storage"))
+
+	tests := []struct {
+		method string
+		path string
+		status int
+	}{
+		{method: http.MethodGet, path: "/storage/download/docs/2024/report.pdf", status: http.StatusUnauthorized},
+		{method: http.MethodHead, path: "/storage/download/docs/report.pdf", status: http.StatusUnauthorized},
+		{method: http.MethodDelete, path: "/storage/docs/2024/report.pdf", status: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/storage/download/", status: http.StatusBadRequest},
+		{method: http.MethodDelete, path: "/storage/", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method + " " + tt.path, func(t *testing.T) {
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
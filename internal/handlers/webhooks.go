@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"main.go/internal/config"
+	"main.go/internal/const/errs"
+	"main.go/internal/dto"
+	"main.go/internal/services"
+	"main.go/internal/utils/webhooks"
+)
+
+type WebhookHandler struct {
+	WebhookService *services.WebhookService
+}
+
+func NewWebhookHandler(service *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		WebhookService: service,
+	}
+}
+
+func (h *WebhookHandler) RegisterRoute(webhookRoute *gin.RouterGroup) {
+
+	// user lifecycle events from clerk, signed by svix
+	webhookRoute.POST("/clerk", h.ClerkEvent)
+}
+
+func (h *WebhookHandler) ClerkEvent(ctx *gin.Context) {
+
+	// 1) verify the signature over the raw body
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, config.WebhookMaxBodySize))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.IncompleteForm,
+			Message: "Failed to read webhook body.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	secret, exists := os.LookupEnv("ClerkWebhookSecret")
+	if !exists {
+		fmt.Println("Clerk webhook secret not found in env.")
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
+
+	err = webhooks.Verify(secret, ctx.Request.Header, body)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errs.Error{
+			Type: errs.Unauthorized,
+			Message: "Unauthorized webhook : " + err.Error(),
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	event := new(dto.ClerkWebhookEvent)
+	err = json.Unmarshal(body, event)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Invalid webhook body.",
+			ToRespondWith: true,
+		})
+		return
+	}
+
+	// 2) delegate to service
+	errf := h.WebhookService.HandleClerkEvent(ctx, event)
+	if errf != nil {
+		if errf.ToRespondWith {
+			ctx.JSON(http.StatusBadRequest, errf)
+		} else {
+			// anything other than a 2xx gets the event redelivered later
+			fmt.Println(errf.Message)
+			ctx.Set("error", errf.Message)
+			ctx.Status(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 3) respond appropriately
+	ctx.Status(http.StatusNoContent)
+}
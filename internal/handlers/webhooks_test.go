@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"main.go/internal/services"
+	"main.go/internal/utils/webhooks"
+)
+
+func TestClerkEventSignature(t *testing.T) {
+
+	secret := "whsec_" + base64.StdEncoding.EncodeToString([]byte("secret of the endpoint"))
+	t.Setenv("ClerkWebhookSecret", secret)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	// events the service does not handle never reach the db
+	NewWebhookHandler(services.NewWebhookService(nil, nil)).RegisterRoute(router.Group("/webhooks"))
+
+	body := []byte(`{"type":"session.created","data":{"id":"user_1"}}`)
+
+	send := func(sentBody []byte, sentAt time.Time) int {
+
+		signature, err := webhooks.Sign(secret, "msg_1", sentAt, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/clerk", bytes.NewReader(sentBody))
+		req.Header.Set("svix-id", "msg_1")
+		req.Header.Set("svix-timestamp", strconv.FormatInt(sentAt.Unix(), 10))
+		req.Header.Set("svix-signature", signature)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send(body, time.Now()); code != http.StatusNoContent {
+		t.Fatalf("signed event : status = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := send([]byte(`{"type":"session.created","data":{"id":"user_2"}}`), time.Now()); code != http.StatusUnauthorized {
+		t.Fatalf("tampered event : status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := send(body, time.Now().Add(-time.Hour)); code != http.StatusUnauthorized {
+		t.Fatalf("replayed event : status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"main.go/internal/const/errs"
+	"main.go/internal/utils/tokens"
+)
+
+// Authenticate verifies the native access token sent as a bearer token in the Authorization header,
+// and sets the "userID", "role" and "email" of its user in the context.
+func Authenticate() gin.HandlerFunc {
+
+	return func(ctx *gin.Context) {
+
+		authHeader := ctx.GetHeader("Authorization")
+		accessToken, found := strings.CutPrefix(authHeader, "Bearer ")
+		if !found || accessToken == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.Error{
+				Type: errs.MissingRequiredField,
+				Message: "Missing access token in headers (Authorization: Bearer <token>).",
+				ToRespondWith: true,
+			})
+			return
+		}
+
+		token, err := tokens.Parse(accessToken, tokens.Access)
+		if err != nil {
+			switch {
+			case errors.Is(err, tokens.ErrExpiredToken):
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.Error{
+					Type: errs.Expired,
+					Message: "Access token has expired, refresh it.",
+					ToRespondWith: true,
+				})
+			case errors.Is(err, tokens.ErrMissingSecret):
+				fmt.Println("Failed to parse access token : " + err.Error())
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+			default:
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.Error{
+					Type: errs.Unauthorized,
+					Message: "Unauthorized to access, invalid access token.",
+					ToRespondWith: true,
+				})
+			}
+			return
+		}
+
+		ctx.Set("userID", token.ID)
+		ctx.Set("role", token.Role)
+		ctx.Set("email", token.Email)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"main.go/internal/const/errs"
+	sqlc "main.go/internal/sqlc/generate"
+)
+
+// lookupAccess gets the role and state of the user authenticated by Authenticate ("userID") or ClerkAuth ("clerkID").
+func lookupAccess(ctx *gin.Context, queries *sqlc.Queries) (*sqlc.GetUserAccessRow, error) {
+
+	if userID, exists := ctx.Get("userID"); exists {
+		if id, ok := userID.(int64); ok {
+			access, err := queries.GetUserAccess(ctx, id)
+			return &access, err
+		}
+	}
+
+	clerkID := ctx.GetString("clerkID")
+	if clerkID == "" {
+		return nil, pgx.ErrNoRows
+	}
+
+	access, err := queries.GetUserAccessFromClerkID(ctx, clerkID)
+	return &sqlc.GetUserAccessRow{
+		UserID: access.UserID,
+		Role: access.Role,
+		Deleted: access.Deleted,
+		Suspended: access.Suspended,
+	}, err
+}
+
+// Authorize lets the request through only if the authenticated user holds one of the allowed roles.
+// It must run after Authenticate or ClerkAuth. The role is read from the db rather than the token,
+// so role changes and suspensions apply right away. The "userID" and "role" are then set in the context.
+func Authorize(queries *sqlc.Queries, allowed ...int64) gin.HandlerFunc {
+
+	return func(ctx *gin.Context) {
+
+		access, err := lookupAccess(ctx, queries)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.Error{
+					Type: errs.NotFound,
+					Message: "No such user found. Please register first.",
+					ToRespondWith: true,
+				})
+				return
+			}
+			fmt.Println("Failed to get user access : " + err.Error())
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if access.Deleted || access.Suspended {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errs.Error{
+				Type: errs.Unauthorized,
+				Message: "This account has been suspended.",
+				ToRespondWith: true,
+			})
+			return
+		}
+
+		if !slices.Contains(allowed, access.Role) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errs.Error{
+				Type: errs.Unauthorized,
+				Message: "Unauthorized to access, the role of this account does not allow it.",
+				ToRespondWith: true,
+			})
+			return
+		}
+
+		ctx.Set("userID", access.UserID)
+		ctx.Set("role", access.Role)
+		ctx.Next()
+	}
+}
@@ -1,58 +1,207 @@
 package middlewares
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
-	"os"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/jwks"
+	"github.com/clerk/clerk-sdk-go/v2/jwt"
 	"github.com/gin-gonic/gin"
 	"main.go/internal/const/errs"
 )
 
+const (
+	clerkJWKSTTL = time.Hour // how long a fetched key set is trusted
+	clerkJWKSMinRefetch = 30 * time.Second // unknown key ids cannot trigger fetches more often than this
+	clerkSessionAlgorithm = "RS256"
+)
+
+var errUnknownKey = errors.New("unknown json web key id")
+
+// KeySetSource fetches the key set of the clerk instance, the session tokens are signed by one of its keys.
+type KeySetSource func(ctx context.Context) (*clerk.JSONWebKeySet, error)
+
+// ClerkKeySet fetches the key set through the clerk jwks api.
+func ClerkKeySet(client *jwks.Client) KeySetSource {
+	return func(ctx context.Context) (*clerk.JSONWebKeySet, error) {
+		return client.Get(ctx, &jwks.GetParams{})
+	}
+}
+
+// jwkCache holds the clerk instance key set, fetched through the source.
+type jwkCache struct {
+	source KeySetSource
+
+	mu sync.Mutex
+	keys map[string]*clerk.JSONWebKey
+	fetchedAt time.Time
+	failedAt time.Time // of the last failed fetch, a stale key is used without refetching for a while after it
+	fetching *keySetFetch // the fetch in flight, nil when there is none
+}
+
+// keySetFetch is a fetch of the key set, the requests that need it while it runs wait for it instead of fetching again.
+type keySetFetch struct {
+	done chan struct{} // closed once the fetch is over
+	err error
+}
+
+// get returns the key for the key ID, refetching the key set when it is stale or the key ID is unknown.
+// The lock is not held during the fetch. When it fails, a cached key of the ID is still returned.
+func (c *jwkCache) get(ctx context.Context, keyID string) (*clerk.JSONWebKey, error) {
+
+	c.mu.Lock()
+	key, found := c.keys[keyID]
+	age := time.Since(c.fetchedAt)
+	if found && (age < clerkJWKSTTL || time.Since(c.failedAt) < clerkJWKSMinRefetch) {
+		c.mu.Unlock()
+		return key, nil
+	}
+	if !found && age < clerkJWKSMinRefetch {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%w %s", errUnknownKey, keyID)
+	}
+
+	fetch := c.fetching
+	if fetch == nil {
+		fetch = &keySetFetch{done: make(chan struct{})}
+		c.fetching = fetch
+		c.mu.Unlock()
+		c.fetch(ctx, fetch)
+	} else {
+		c.mu.Unlock()
+		select {
+		case <-fetch.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, found = c.keys[keyID]
+	if found {
+		return key, nil
+	}
+	if fetch.err != nil {
+		return nil, fetch.err
+	}
+	return nil, fmt.Errorf("%w %s", errUnknownKey, keyID)
+}
+
+// fetch fetches the key set into the cache, keeping the cached one when it fails.
+func (c *jwkCache) fetch(ctx context.Context, fetch *keySetFetch) {
+
+	set, err := c.source(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer close(fetch.done)
+	c.fetching = nil
+
+	if err != nil {
+		fetch.err = err
+		c.failedAt = time.Now()
+		return
+	}
+
+	c.keys = make(map[string]*clerk.JSONWebKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k != nil {
+			c.keys[k.KeyID] = k
+		}
+	}
+	c.fetchedAt = time.Now()
+}
+
+// ClerkAuth verifies the clerk session token sent as a bearer token in the Authorization header.
+// The token must be RS256 signed by a key of the instance key set fetched from keySet,
+// the clerk user ID is then taken from its 'sub' claim and set as "clerkID" in the context.
+func ClerkAuth(keySet KeySetSource) gin.HandlerFunc {
+
+	keys := &jwkCache{
+		source: keySet,
+	}
 
-func ClerkAuth() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 
-		// get clerk id from request
-		// TODO: check for validity
-		
-		pkey, exists := os.LookupEnv("PublicSecretKey")
-		if !exists {
-			ctx.JSON(http.StatusInternalServerError, errs.Error{
-				Type: errs.NotFound,
-				Message: "Internal failure, resources not found.",
+		authHeader := ctx.GetHeader("Authorization")
+		token, found := strings.CutPrefix(authHeader, "Bearer ")
+		if !found || token == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.Error{
+				Type: errs.MissingRequiredField,
+				Message: "Missing session token in headers (Authorization: Bearer <token>).",
+				ToRespondWith: true,
 			})
-			return 
+			return
 		}
 
-		clerkID := ctx.GetHeader("ClerkID")
-		if clerkID == "" {
-			ctx.JSON(http.StatusBadRequest, errs.Error{
-				Type: errs.MissingRequiredField,
-				Message: "Missing clerk ID in headers (ClerkID).",
+		unverified, err := jwt.Decode(ctx, &jwt.DecodeParams{
+			Token: token,
+		})
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.Error{
+				Type: errs.InvalidFormat,
+				Message: "Session token is malformed.",
 				ToRespondWith: true,
 			})
 			return
 		}
 
-		skey := ctx.GetHeader("SecretKey")
-		if skey == "" {
-			ctx.JSON(http.StatusBadRequest, errs.Error{
-				Type: errs.MissingRequiredField,
-				Message: "Missing secret key in headers (SecretKey).",
+		jwk, err := keys.get(ctx, unverified.KeyID)
+		if err != nil {
+			if !errors.Is(err, errUnknownKey) {
+				fmt.Println("failed to fetch clerk key set : " + err.Error())
+				ctx.Set("error", err.Error())
+				ctx.AbortWithStatus(http.StatusServiceUnavailable)
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.Error{
+				Type: errs.Unauthorized,
+				Message: "Unauthorized to access, session token is signed by an unknown key.",
+				ToRespondWith: true,
+			})
+			return
+		}
+
+		if jwk.Algorithm != clerkSessionAlgorithm {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.Error{
+				Type: errs.Unauthorized,
+				Message: "Unauthorized to access, session token is not " + clerkSessionAlgorithm + " signed.",
 				ToRespondWith: true,
 			})
 			return
 		}
 
-		if skey != pkey {
-			ctx.JSON(http.StatusUnauthorized, errs.Error{
+		claims, err := jwt.Verify(ctx, &jwt.VerifyParams{
+			Token: token,
+			JWK: jwk,
+		})
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.Error{
 				Type: errs.Unauthorized,
-				Message: "Unauthorized to access, Failed to match public access key.",
+				Message: "Unauthorized to access, invalid session token : " + err.Error(),
+				ToRespondWith: true,
+			})
+			return
+		}
+
+		if claims.Subject == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errs.Error{
+				Type: errs.MissingRequiredField,
+				Message: "Session token is missing the subject claim.",
+				ToRespondWith: true,
 			})
 			return
 		}
 
-		ctx.Set("clerkID", clerkID)
+		ctx.Set("clerkID", claims.Subject)
 		ctx.Next()
 	}
-}
\ No newline at end of file
+}
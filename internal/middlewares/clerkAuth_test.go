@@ -0,0 +1,307 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-jose/go-jose/v3"
+	josejwt "github.com/go-jose/go-jose/v3/jwt"
+	"main.go/internal/const/errs"
+)
+
+const testIssuer = "https://clerk.example.com"
+
+// testKeys is a local key set, the tests never reach clerk.
+type testKeys struct {
+	rsaKey *rsa.PrivateKey
+	otherRSAKey *rsa.PrivateKey // not in the set
+	ecKey *ecdsa.PrivateKey
+	fetches int
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testKeys{
+		rsaKey: rsaKey,
+		otherRSAKey: otherRSAKey,
+		ecKey: ecKey,
+	}
+}
+
+func (k *testKeys) source(ctx context.Context) (*clerk.JSONWebKeySet, error) {
+	k.fetches++
+	return &clerk.JSONWebKeySet{
+		Keys: []*clerk.JSONWebKey{
+			{Key: &k.rsaKey.PublicKey, KeyID: "rsa", Algorithm: "RS256", Use: "sig"},
+			{Key: &k.ecKey.PublicKey, KeyID: "ec", Algorithm: "ES256", Use: "sig"},
+		},
+	}, nil
+}
+
+// sign issues a session token for user_1 with the key, under the key id.
+func sign(t *testing.T, alg jose.SignatureAlgorithm, key any, keyID string, expiry time.Time) string {
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", keyID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := josejwt.Signed(signer).Claims(josejwt.Claims{
+		Issuer: testIssuer,
+		Subject: "user_1",
+		IssuedAt: josejwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		Expiry: josejwt.NewNumericDate(expiry),
+	}).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newTestRouter(source KeySetSource) *gin.Engine {
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", ClerkAuth(source), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, ctx.GetString("clerkID"))
+	})
+	return router
+}
+
+func request(router *gin.Engine, token string) *httptest.ResponseRecorder {
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer " + token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestClerkAuth(t *testing.T) {
+
+	keys := newTestKeys(t)
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		token string
+		status int
+		message string // part of the message of the error, which must be meant for the user
+	}{
+		{
+			name: "valid RS256 token",
+			token: sign(t, jose.RS256, keys.rsaKey, "rsa", later),
+			status: http.StatusOK,
+		},
+		{
+			name: "missing token",
+			token: "",
+			status: http.StatusUnauthorized,
+			message: "Missing session token",
+		},
+		{
+			name: "unknown key id",
+			token: sign(t, jose.RS256, keys.rsaKey, "unknown", later),
+			status: http.StatusUnauthorized,
+			message: "unknown key",
+		},
+		{
+			name: "ES256 key of the set",
+			token: sign(t, jose.ES256, keys.ecKey, "ec", later),
+			status: http.StatusUnauthorized,
+			message: "not RS256 signed",
+		},
+		{
+			name: "HS256 token under the RS256 key id",
+			token: sign(t, jose.HS256, []byte("shared secret of at least 32 bytes"), "rsa", later),
+			status: http.StatusUnauthorized,
+			message: "invalid session token",
+		},
+		{
+			name: "expired token",
+			token: sign(t, jose.RS256, keys.rsaKey, "rsa", time.Now().Add(-time.Minute)),
+			status: http.StatusUnauthorized,
+			message: "invalid session token",
+		},
+		{
+			name: "signed by another key",
+			token: sign(t, jose.RS256, keys.otherRSAKey, "rsa", later),
+			status: http.StatusUnauthorized,
+			message: "invalid session token",
+		},
+	}
+
+	router := newTestRouter(keys.source)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			rec := request(router, tt.token)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", rec.Code, tt.status, rec.Body.String())
+			}
+
+			if tt.status == http.StatusOK {
+				if rec.Body.String() != "user_1" {
+					t.Fatalf("clerkID = %q, want user_1", rec.Body.String())
+				}
+				return
+			}
+
+			var errf errs.Error
+			err := json.Unmarshal(rec.Body.Bytes(), &errf)
+			if err != nil {
+				t.Fatalf("body is not an error : %s", rec.Body.String())
+			}
+			if !errf.ToRespondWith || !strings.Contains(errf.Message, tt.message) {
+				t.Fatalf("error = %+v, want one for the user containing %q", errf, tt.message)
+			}
+		})
+	}
+}
+
+func TestClerkAuthRefetch(t *testing.T) {
+
+	keys := newTestKeys(t)
+	router := newTestRouter(keys.source)
+	later := time.Now().Add(time.Hour)
+
+	request(router, sign(t, jose.RS256, keys.rsaKey, "rsa", later))
+	request(router, sign(t, jose.RS256, keys.rsaKey, "rsa", later))
+	if keys.fetches != 1 {
+		t.Fatalf("key set fetched %d times for a known key id, want 1", keys.fetches)
+	}
+
+	// unknown key ids must not make every request refetch the key set
+	for i := 0; i < 3; i++ {
+		request(router, sign(t, jose.RS256, keys.rsaKey, "unknown", later))
+	}
+	if keys.fetches != 1 {
+		t.Fatalf("key set fetched %d times after unknown key ids, want 1", keys.fetches)
+	}
+}
+
+func TestClerkAuthSourceFailure(t *testing.T) {
+
+	router := newTestRouter(func(ctx context.Context) (*clerk.JSONWebKeySet, error) {
+		return nil, errors.New("clerk is down")
+	})
+	keys := newTestKeys(t)
+
+	rec := request(router, sign(t, jose.RS256, keys.rsaKey, "rsa", time.Now().Add(time.Hour)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestJWKCacheFallsBackOnFailedRefetch(t *testing.T) {
+
+	ctx := context.Background()
+	keys := newTestKeys(t)
+	down := false
+	c := &jwkCache{source: func(ctx context.Context) (*clerk.JSONWebKeySet, error) {
+		if down {
+			keys.fetches++
+			return nil, errors.New("clerk is down")
+		}
+		return keys.source(ctx)
+	}}
+
+	_, err := c.get(ctx, "rsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key set went stale while clerk is down
+	down = true
+	c.fetchedAt = time.Now().Add(-2 * clerkJWKSTTL)
+
+	key, err := c.get(ctx, "rsa")
+	if err != nil || key.KeyID != "rsa" {
+		t.Fatalf("stale key after a failed refetch = %v, %v, want the cached key", key, err)
+	}
+	_, err = c.get(ctx, "rsa")
+	if err != nil || keys.fetches != 2 {
+		t.Fatalf("after a failed refetch : %v, fetched %d times, want the cached key without a new fetch", err, keys.fetches)
+	}
+
+	// a key that is not cached cannot be trusted
+	_, err = c.get(ctx, "unknown")
+	if err == nil || errors.Is(err, errUnknownKey) {
+		t.Fatalf("uncached key after a failed refetch : %v, want the fetch error", err)
+	}
+}
+
+func TestJWKCacheSingleFetch(t *testing.T) {
+
+	keys := newTestKeys(t)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	c := &jwkCache{source: func(ctx context.Context) (*clerk.JSONWebKeySet, error) {
+		fetches.Add(1)
+		<-release
+		return keys.source(ctx)
+	}}
+
+	var wg sync.WaitGroup
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.get(context.Background(), "rsa")
+			results <- err
+		}()
+	}
+
+	// the cache is not locked while a fetch is in flight, a request that gives up returns at once
+	for fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.get(cancelled, "rsa")
+	if err != context.Canceled {
+		t.Fatalf("get during a fetch with a cancelled context : %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	close(results)
+	for err := range results {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Fatalf("key set fetched %d times by concurrent requests, want 1", got)
+	}
+}
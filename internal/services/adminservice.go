@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"main.go/internal/config"
+	"main.go/internal/const/errs"
+	"main.go/internal/const/roles"
+	"main.go/internal/dto"
+	sqlc "main.go/internal/sqlc/generate"
+)
+
+type AdminService struct {
+	queries *sqlc.Queries
+}
+
+func NewAdminService(queries *sqlc.Queries) *AdminService {
+	return &AdminService{
+		queries: queries,
+	}
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+
+// ListUsers returns a page of all users, oldest first.
+func (s *AdminService) ListUsers(ctx *gin.Context, limit int32, offset int32) ([]*dto.AdminUser, *errs.Error) {
+
+	if limit <= 0 {
+		limit = config.AdminListDefaultLimit
+	}
+	if limit > config.AdminListMaxLimit {
+		limit = config.AdminListMaxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	usersData, err := s.queries.ListUsers(ctx, sqlc.ListUsersParams{
+		Limit: limit,
+		Offset: offset,
+	})
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to list users : " + err.Error(),
+		}
+	}
+
+	resp := make([]*dto.AdminUser, 0, len(usersData))
+	for _, user := range usersData {
+		resp = append(resp, &dto.AdminUser{
+			ID: user.UserID,
+			Email: user.Email,
+			Role: roles.Name(user.Role),
+			UserUIID: user.UserUiid.String(),
+			CreatedAt: user.CreatedAt.Time.Unix(),
+			Confirmed: user.Confirmed,
+			Deleted: user.Deleted,
+			Suspended: user.Suspended,
+			Projects: user.ProjectCount,
+		})
+	}
+
+	return resp, nil
+}
+
+// SuspendUser suspends or reinstates a user. A suspended user is logged out everywhere,
+// cannot log in, and their api keys stop working until the suspension is lifted.
+func (s *AdminService) SuspendUser(ctx *gin.Context, adminID int64, data *dto.SuspendUser) (*errs.Error) {
+
+	if data.UserID == adminID {
+		return &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: "Cannot change the suspension of your own account.",
+			ToRespondWith: true,
+		}
+	}
+
+	affected, err := s.queries.SetUserSuspended(ctx, sqlc.SetUserSuspendedParams{
+		UserID: data.UserID,
+		Suspended: data.Suspended,
+	})
+	if err != nil {
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to set user suspension : " + err.Error(),
+		}
+	}
+	if affected == 0 {
+		return &errs.Error{
+			Type: errs.NotFound,
+			Message: "No such user found.",
+			ToRespondWith: true,
+		}
+	}
+
+	if data.Suspended {
+		err = s.queries.RevokeUserRefreshTokens(ctx, data.UserID)
+		if err != nil {
+			return &errs.Error{
+				Type: errs.Internal,
+				Message: "Failed to revoke refresh tokens of suspended user : " + err.Error(),
+			}
+		}
+	}
+
+	return nil
+}
+
+// InspectProject returns any project along with its owner and all of its keys.
+func (s *AdminService) InspectProject(ctx *gin.Context, projectID string) (*dto.AdminProject, *errs.Error) {
+
+	var serviceUUID pgtype.UUID
+	err := serviceUUID.Scan(projectID)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Invalid project id, should be a uuid.",
+			ToRespondWith: true,
+		}
+	}
+
+	serviceData, err := s.queries.GetServiceByUUID(ctx, serviceUUID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &errs.Error{
+				Type: errs.NotFound,
+				Message: "No such project found.",
+				ToRespondWith: true,
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to get service data : " + err.Error(),
+		}
+	}
+
+	keysData, err := s.queries.GetServiceKeys(ctx, serviceData.Sid)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to get service keys : " + err.Error(),
+		}
+	}
+
+	keys := make([]*dto.APIKeyResponse, 0, len(keysData))
+	for _, key := range keysData {
+		keys = append(keys, &dto.APIKeyResponse{
+			ID: key.ID,
+			Prefix: key.KeyPrefix,
+			Label: key.Label,
+			CreatedAt: key.CreatedAt.Time.Unix(),
+			ExpiresAt: key.ExpiresAt,
+			Revoked: key.Revoked,
+			Scopes: key.Scopes,
+		})
+	}
+
+	return &dto.AdminProject{
+		ServiceUUID: serviceData.ServiceUuid.String(),
+		ServiceName: serviceData.Name,
+		ServiceCreatedAt: serviceData.CreatedAt.Time.Unix(),
+		OwnerID: serviceData.UserID,
+		OwnerEmail: serviceData.Email,
+		Keys: keys,
+	}, nil
+}
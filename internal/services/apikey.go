@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"main.go/internal/const/errs"
+	sqlc "main.go/internal/sqlc/generate"
+	apikeys "main.go/internal/utils/apikeys"
+)
+
+// decodeAPIKey verifies the structure and signature of an api key before it is looked up in the db,
+// so forged or tampered keys never reach postgres.
+func decodeAPIKey(apiKey string) (*apikeys.DecodedKey, *errs.Error) {
+
+	decoded, err := apikeys.DecodeKey(apiKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, apikeys.ErrMalformedKey):
+			return nil, &errs.Error{
+				Type: errs.InvalidFormat,
+				Message: "API key is malformed.",
+				ToRespondWith: true,
+			}
+		case errors.Is(err, apikeys.ErrUnknownVersion):
+			return nil, &errs.Error{
+				Type: errs.InvalidFormat,
+				Message: "API key version is not supported. Please regenerate your key.",
+				ToRespondWith: true,
+			}
+		case errors.Is(err, apikeys.ErrInvalidSignature):
+			return nil, &errs.Error{
+				Type: errs.Unauthorized,
+				Message: "API key signature is invalid.",
+				ToRespondWith: true,
+			}
+		default:
+			return nil, &errs.Error{
+				Type: errs.Internal,
+				Message: "Failed to decode api key : " + err.Error(),
+			}
+		}
+	}
+
+	return decoded, nil
+}
+
+// lookupAPIKey decodes the api key, fetches the owning user data and enforces the key expiry.
+// A key replaced by a renewal is still accepted until its grace window runs out.
+func lookupAPIKey(ctx *gin.Context, queries *sqlc.Queries, apiKey string) (*sqlc.GetUserDataFromAPIKeyRow, *errs.Error) {
+
+	_, errf := decodeAPIKey(apiKey)
+	if errf != nil {
+		return nil, errf
+	}
+
+	keyHash := apikeys.HashKey(apiKey)
+
+	userData, err := queries.GetUserDataFromAPIKey(ctx, keyHash)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.NotFound,
+			Message: "API key not found.",
+			ToRespondWith: true,
+		}
+	}
+
+	if userData.Revoked {
+		return nil, &errs.Error{
+			Type: errs.Unauthorized,
+			Message: "API key has been revoked.",
+			ToRespondWith: true,
+		}
+	}
+
+	if userData.Suspended {
+		return nil, &errs.Error{
+			Type: errs.Unauthorized,
+			Message: "API key belongs to a suspended account.",
+			ToRespondWith: true,
+		}
+	}
+
+	now := time.Now().Unix()
+
+	// matched on the previous key of a renewed row
+	if userData.KeyHash != keyHash {
+		if !userData.PrevKeyExpiresAt.Valid || userData.PrevKeyExpiresAt.Int64 <= now {
+			return nil, &errs.Error{
+				Type: errs.Expired,
+				Message: "API key has been renewed and its grace period is over. Use the renewed key.",
+				ToRespondWith: true,
+			}
+		}
+		return &userData, nil
+	}
+
+	if userData.ExpiresAt <= now {
+		return nil, &errs.Error{
+			Type: errs.Expired,
+			Message: "API key has expired. Renew it from the dashboard.",
+			ToRespondWith: true,
+		}
+	}
+
+	return &userData, nil
+}
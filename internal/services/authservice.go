@@ -0,0 +1,324 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+	"main.go/internal/const/errs"
+	"main.go/internal/const/roles"
+	"main.go/internal/dto"
+	sqlc "main.go/internal/sqlc/generate"
+	"main.go/internal/utils/mailer"
+	"main.go/internal/utils/tokens"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything past 72 bytes
+)
+
+// dummyPasswordHash is compared against when the email is unknown,
+// so that a login takes the same time whether or not the user exists.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("vaultbase-dummy-password"), bcrypt.DefaultCost)
+
+type AuthService struct {
+	queries *sqlc.Queries
+	Mailer mailer.Mailer
+}
+
+func NewAuthService(queries *sqlc.Queries, mailer mailer.Mailer) *AuthService {
+	return &AuthService{
+		queries: queries,
+		Mailer: mailer,
+	}
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+
+var errInvalidCredentials = &errs.Error{
+	Type: errs.Unauthorized,
+	Message: "Invalid email or password.",
+	ToRespondWith: true,
+}
+
+var errSuspended = &errs.Error{
+	Type: errs.Unauthorized,
+	Message: "This account has been suspended.",
+	ToRespondWith: true,
+}
+
+// issueTokens creates an access token and a refresh token of the given family, the refresh token is recorded so it can be used once.
+func (s *AuthService) issueTokens(ctx *gin.Context, userID int64, role int64, email string, familyID string) (*dto.LoginResponse, *errs.Error) {
+
+	access, err := tokens.NewAccessToken(userID, role, email)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to create access token : " + err.Error(),
+		}
+	}
+
+	refresh, jti, expiresAt, err := tokens.NewRefreshToken(userID, role, familyID)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to create refresh token : " + err.Error(),
+		}
+	}
+
+	err = s.queries.InsertRefreshToken(ctx, sqlc.InsertRefreshTokenParams{
+		UserID: userID,
+		Jti: jti,
+		FamilyID: familyID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to insert refresh token : " + err.Error(),
+		}
+	}
+
+	return &dto.LoginResponse{
+		Tokens: &dto.JWTTokens{
+			JWTAccess: access,
+			JWTRefresh: refresh,
+		},
+		Role: role,
+	}, nil
+}
+
+// newFamily starts a new refresh token family, one per login.
+func (s *AuthService) newFamily() (string, *errs.Error) {
+
+	familyID, err := tokens.NewID()
+	if err != nil {
+		return "", &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to create refresh token family : " + err.Error(),
+		}
+	}
+	return familyID, nil
+}
+
+func (s *AuthService) parseRefreshToken(refreshToken string) (*dto.Token, *errs.Error) {
+
+	token, err := tokens.Parse(refreshToken, tokens.Refresh)
+	if err != nil {
+		if errors.Is(err, tokens.ErrExpiredToken) {
+			return nil, &errs.Error{
+				Type: errs.Expired,
+				Message: "Refresh token has expired, login again.",
+				ToRespondWith: true,
+			}
+		}
+		if errors.Is(err, tokens.ErrMissingSecret) {
+			return nil, &errs.Error{
+				Type: errs.Internal,
+				Message: "Failed to parse refresh token : " + err.Error(),
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Unauthorized,
+			Message: "Invalid refresh token.",
+			ToRespondWith: true,
+		}
+	}
+
+	return token, nil
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+
+// Signup registers a user with an email and password and logs them in.
+func (s *AuthService) Signup(ctx *gin.Context, data *dto.LoginData) (*dto.LoginResponse, *errs.Error) {
+
+	// 0) validate user data
+	email := strings.ToLower(strings.TrimSpace(data.Email))
+	if _, err := mail.ParseAddress(email); err != nil {
+		return nil, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Invalid email address.",
+			ToRespondWith: true,
+		}
+	}
+
+	if len(data.Password) < minPasswordLength || len(data.Password) > maxPasswordLength {
+		return nil, &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: "The password should be between 8 and 72 characters long.",
+			ToRespondWith: true,
+		}
+	}
+
+	// 1) hash the password
+	hash, err := bcrypt.GenerateFromPassword([]byte(data.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to hash password : " + err.Error(),
+		}
+	}
+
+	// 2) insert the user
+	role := roles.User
+	userID, err := s.queries.SignupUserWithPassword(ctx, sqlc.SignupUserWithPasswordParams{
+		Email: email,
+		Password: string(hash),
+		Role: role,
+	})
+	if err != nil {
+		var pgerr *pgconn.PgError
+		if errors.As(err, &pgerr) && pgerr.Code == errs.UniqueViolation {
+			return nil, &errs.Error{
+				Type: errs.ObjectExists,
+				Message: "User with Email-Id already exists. Try with different Id.",
+				ToRespondWith: true,
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to insert new user : " + err.Error(),
+		}
+	}
+
+	// 3) the user can log in right away, but needs to confirm the email before creating projects
+	errf := sendConfirmation(ctx, s.Mailer, userID, email)
+	if errf != nil {
+		// not fatal, a new link can be requested from /public/resendconfirm
+		fmt.Println(errf.Message)
+	}
+
+	// 4) log the user in
+	familyID, errf := s.newFamily()
+	if errf != nil {
+		return nil, errf
+	}
+
+	return s.issueTokens(ctx, userID, role, email, familyID)
+}
+
+// Login checks the email and password and returns a new pair of tokens, starting a new refresh token family.
+func (s *AuthService) Login(ctx *gin.Context, data *dto.LoginData) (*dto.LoginResponse, *errs.Error) {
+
+	email := strings.ToLower(strings.TrimSpace(data.Email))
+
+	userData, err := s.queries.GetUserAuthByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(data.Password))
+			return nil, errInvalidCredentials
+		}
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to get user auth data : " + err.Error(),
+		}
+	}
+
+	// users signed up through clerk have no password and cannot log in here
+	err = bcrypt.CompareHashAndPassword([]byte(userData.Password), []byte(data.Password))
+	if err != nil || userData.Deleted {
+		return nil, errInvalidCredentials
+	}
+	if userData.Suspended {
+		return nil, errSuspended
+	}
+
+	familyID, errf := s.newFamily()
+	if errf != nil {
+		return nil, errf
+	}
+
+	return s.issueTokens(ctx, userData.UserID, userData.Role, userData.Email, familyID)
+}
+
+// Refresh exchanges a refresh token for a new pair of tokens, the presented token cannot be used again.
+// A refresh token that was already used means it leaked, so its whole family is revoked
+// and both the attacker and the user have to log in again.
+func (s *AuthService) Refresh(ctx *gin.Context, refreshToken string) (*dto.LoginResponse, *errs.Error) {
+
+	token, errf := s.parseRefreshToken(refreshToken)
+	if errf != nil {
+		return nil, errf
+	}
+
+	// 1) mark the token as used, only succeeds once
+	used, err := s.queries.UseRefreshToken(ctx, token.JTI)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, &errs.Error{
+				Type: errs.Internal,
+				Message: "Failed to use refresh token : " + err.Error(),
+			}
+		}
+
+		// 2) reuse detection, the token exists but was already used or revoked
+		familyID, err := s.queries.GetRefreshTokenFamily(ctx, token.JTI)
+		if err == nil {
+			err = s.queries.RevokeRefreshTokenFamily(ctx, familyID)
+			if err != nil {
+				return nil, &errs.Error{
+					Type: errs.Internal,
+					Message: "Failed to revoke refresh token family : " + err.Error(),
+				}
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Unauthorized,
+			Message: "Refresh token is no longer valid, login again.",
+			ToRespondWith: true,
+		}
+	}
+
+	if used.ExpiresAt <= time.Now().Unix() {
+		return nil, &errs.Error{
+			Type: errs.Expired,
+			Message: "Refresh token has expired, login again.",
+			ToRespondWith: true,
+		}
+	}
+
+	// 3) role and email might have changed since the last login
+	userData, err := s.queries.GetUserData(ctx, used.UserID)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to retrieve user info : " + err.Error(),
+		}
+	}
+	if userData.Deleted {
+		return nil, errInvalidCredentials
+	}
+	if userData.Suspended {
+		return nil, errSuspended
+	}
+
+	// 4) rotate within the same family
+	return s.issueTokens(ctx, used.UserID, userData.Role, userData.Email, used.FamilyID)
+}
+
+// Logout revokes the refresh token family of the given token, access tokens run out on their own.
+func (s *AuthService) Logout(ctx *gin.Context, refreshToken string) *errs.Error {
+
+	token, errf := s.parseRefreshToken(refreshToken)
+	if errf != nil {
+		return errf
+	}
+
+	err := s.queries.RevokeRefreshTokenFamily(ctx, token.FamilyID)
+	if err != nil {
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to revoke refresh token family : " + err.Error(),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+	"main.go/internal/const/errs"
+	"main.go/internal/utils/mailer"
+	"main.go/internal/utils/tokens"
+)
+
+func TestRefreshRotatesWithinFamily(t *testing.T) {
+
+	t.Setenv("JWTSecretKey", "test-secret")
+	queries, mock := newMockQueries(t)
+	s := NewAuthService(queries, mailer.NewMemoryMailer())
+
+	refresh, jti, expiresAt, err := tokens.NewRefreshToken(1, 0, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(named("UseRefreshToken")).WithArgs(jti).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id", "family_id", "expires_at"}).AddRow(int64(1), "family", expiresAt))
+	mock.ExpectQuery(named("GetUserData")).WithArgs(int64(1)).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id", "email", "role", "user_uiid", "created_at", "confirmed", "deleted", "suspended"}).
+			AddRow(int64(1), "a@example.com", int64(0), pgtype.UUID{}, pgtype.Timestamptz{}, true, false, false))
+	mock.ExpectExec(named("InsertRefreshToken")).WithArgs(int64(1), pgxmock.AnyArg(), "family", pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	resp, errf := s.Refresh(newTestContext(), refresh)
+	if errf != nil {
+		t.Fatalf("refresh failed : %+v", errf)
+	}
+
+	rotated, err := tokens.Parse(resp.Tokens.JWTRefresh, tokens.Refresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.FamilyID != "family" || rotated.JTI == jti {
+		t.Fatalf("rotated token = %+v, want a new ID in the same family", rotated)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+
+	t.Setenv("JWTSecretKey", "test-secret")
+	queries, mock := newMockQueries(t)
+	s := NewAuthService(queries, mailer.NewMemoryMailer())
+
+	refresh, jti, _, err := tokens.NewRefreshToken(1, 0, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// already used once, so the update matches no row
+	mock.ExpectQuery(named("UseRefreshToken")).WithArgs(jti).WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery(named("GetRefreshTokenFamily")).WithArgs(jti).
+		WillReturnRows(pgxmock.NewRows([]string{"family_id"}).AddRow("family"))
+	mock.ExpectExec(named("RevokeRefreshTokenFamily")).WithArgs("family").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 2))
+
+	_, errf := s.Refresh(newTestContext(), refresh)
+	if errf == nil || errf.Type != errs.Unauthorized || !errf.ToRespondWith {
+		t.Fatalf("reused refresh token : %+v, want an unauthorized error", errf)
+	}
+}
+
+func TestRefreshExpiredToken(t *testing.T) {
+
+	t.Setenv("JWTSecretKey", "test-secret")
+	queries, mock := newMockQueries(t)
+	s := NewAuthService(queries, mailer.NewMemoryMailer())
+
+	refresh, jti, _, err := tokens.NewRefreshToken(1, 0, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the row expired before the token claims, the row is what counts
+	mock.ExpectQuery(named("UseRefreshToken")).WithArgs(jti).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id", "family_id", "expires_at"}).AddRow(int64(1), "family", time.Now().Unix() - 1))
+
+	_, errf := s.Refresh(newTestContext(), refresh)
+	if errf == nil || errf.Type != errs.Expired {
+		t.Fatalf("expired refresh token : %+v, want an expired error", errf)
+	}
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"main.go/internal/config"
+	"main.go/internal/const/errs"
+	"main.go/internal/const/scopes"
+	"main.go/internal/dto"
+	sqlc "main.go/internal/sqlc/generate"
+	"main.go/internal/utils/cache"
+)
+
+var databaseNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// cacheDatabase is the cache database targeted by a request.
+type cacheDatabase struct {
+	serviceID int64 // project of the api key, for analytics
+	namespace cache.Namespace
+	defaultTTL time.Duration // 0 for none
+}
+
+// ttl converts the ttl of a request, in milliseconds, falling back to the default ttl of the database when not set.
+func (d *cacheDatabase) ttl(millis int64) time.Duration {
+
+	if millis == 0 {
+		return d.defaultTTL
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
+// authorize validates the api key for the scope and resolves the database, by name, in the project of the key.
+// Without a name, or with config.CacheDefaultDatabase, it is the default database, the namespace of the user_uiid.
+func (s *CacheService) authorize(ctx *gin.Context, apiKey string, database string, scope string) (*cacheDatabase, *errs.Error) {
+
+	userData, errf := s.validateAPIKey(ctx, apiKey, scope)
+	if errf != nil {
+		return nil, errf
+	}
+
+	if database == "" || database == config.CacheDefaultDatabase {
+		return &cacheDatabase{
+			serviceID: userData.ServiceID,
+			namespace: cache.Namespace{ID: userData.UserUiid.String()},
+		}, nil
+	}
+
+	row, err := s.queries.GetCacheDatabase(ctx, sqlc.GetCacheDatabaseParams{
+		ServiceID: userData.ServiceID,
+		Name: database,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &errs.Error{
+				Type: errs.NotFound,
+				Message: "Cache database '" + database + "' not found in this project.",
+				ToRespondWith: true,
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to get cache database : " + err.Error(),
+		}
+	}
+
+	return &cacheDatabase{
+		serviceID: userData.ServiceID,
+		namespace: cache.Namespace{
+			ID: row.Namespace.String(),
+			MaxKeys: row.MaxKeys,
+			Eviction: cache.EvictionPolicy(row.EvictionPolicy),
+		},
+		defaultTTL: time.Duration(row.DefaultTtl) * time.Millisecond,
+	}, nil
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// CACHE DATABASES
+
+// CreateDatabase creates a named cache database in the project of the api key.
+func (s *CacheService) CreateDatabase(ctx *gin.Context, apiKey string, data *dto.NewCacheDatabase) (*dto.CacheDatabase, *errs.Error) {
+
+	// 1) validate the settings
+	if !databaseNamePattern.MatchString(data.Name) || data.Name == config.CacheDefaultDatabase {
+		return nil, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Cache database name must be 1 to 64 letters, digits, '-' or '_', and cannot be '" + config.CacheDefaultDatabase + "'.",
+			ToRespondWith: true,
+		}
+	}
+
+	if data.DefaultTTL < 0 || data.MaxKeys < 0 {
+		return nil, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Cache database default ttl and max keys cannot be negative.",
+			ToRespondWith: true,
+		}
+	}
+
+	policy := cache.EvictionPolicy(data.EvictionPolicy)
+	if policy == "" {
+		policy = cache.EvictNone
+	}
+	if policy != cache.EvictNone && policy != cache.EvictLRU {
+		return nil, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: fmt.Sprintf("Cache database eviction policy must be '%s' or '%s'.", cache.EvictNone, cache.EvictLRU),
+			ToRespondWith: true,
+		}
+	}
+
+	userData, errf := s.validateAPIKey(ctx, apiKey, scopes.CachePut)
+	if errf != nil {
+		return nil, errf
+	}
+
+	// 2) check the project limit, under the lock of the project so concurrent creations cannot both pass it
+	tx, err := s.DB.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to acquire a transaction : " + err.Error(),
+		}
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Println(err)
+		}
+	}()
+	txQueries := s.queries.WithTx(tx)
+
+	_, err = txQueries.LockService(ctx, userData.ServiceID)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to lock project : " + err.Error(),
+		}
+	}
+	count, err := txQueries.CountCacheDatabases(ctx, userData.ServiceID)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to count cache databases : " + err.Error(),
+		}
+	}
+	if count >= config.CacheMaxDatabases {
+		return nil, &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: fmt.Sprintf("A project can have at most %d cache databases.", config.CacheMaxDatabases),
+			ToRespondWith: true,
+		}
+	}
+
+	// 3) create it
+	row, err := txQueries.InsertCacheDatabase(ctx, sqlc.InsertCacheDatabaseParams{
+		ServiceID: userData.ServiceID,
+		Name: data.Name,
+		DefaultTtl: data.DefaultTTL,
+		MaxKeys: data.MaxKeys,
+		EvictionPolicy: string(policy),
+	})
+	if err != nil {
+		var pgerr *pgconn.PgError
+		if errors.As(err, &pgerr) && pgerr.Code == errs.UniqueViolation {
+			return nil, &errs.Error{
+				Type: errs.ObjectExists,
+				Message: "Cache database '" + data.Name + "' already exists in this project.",
+				ToRespondWith: true,
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to insert cache database : " + err.Error(),
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to commit cache database transaction : " + err.Error(),
+		}
+	}
+
+	return &dto.CacheDatabase{
+		Name: data.Name,
+		DefaultTTL: data.DefaultTTL,
+		MaxKeys: data.MaxKeys,
+		EvictionPolicy: string(policy),
+		CreatedAt: row.CreatedAt.Time.Unix(),
+	}, nil
+}
+
+// ListDatabases lists the named cache databases of the project of the api key.
+func (s *CacheService) ListDatabases(ctx *gin.Context, apiKey string) ([]*dto.CacheDatabase, *errs.Error) {
+
+	userData, errf := s.validateAPIKey(ctx, apiKey, scopes.CacheGet)
+	if errf != nil {
+		return nil, errf
+	}
+
+	rows, err := s.queries.ListCacheDatabases(ctx, userData.ServiceID)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to list cache databases : " + err.Error(),
+		}
+	}
+
+	databases := make([]*dto.CacheDatabase, 0, len(rows))
+	for _, row := range rows {
+		databases = append(databases, &dto.CacheDatabase{
+			Name: row.Name,
+			DefaultTTL: row.DefaultTtl,
+			MaxKeys: row.MaxKeys,
+			EvictionPolicy: row.EvictionPolicy,
+			CreatedAt: row.CreatedAt.Time.Unix(),
+		})
+	}
+
+	return databases, nil
+}
+
+// DropDatabase deletes the named cache database and all of its keys, returning how many keys were removed.
+// Its namespace is never used again, so keys the backend fails to remove can no longer be reached anyway.
+func (s *CacheService) DropDatabase(ctx *gin.Context, apiKey string, database string) (int64, *errs.Error) {
+
+	userData, errf := s.validateAPIKey(ctx, apiKey, scopes.CacheDelete)
+	if errf != nil {
+		return 0, errf
+	}
+
+	row, err := s.queries.DeleteCacheDatabase(ctx, sqlc.DeleteCacheDatabaseParams{
+		ServiceID: userData.ServiceID,
+		Name: database,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, &errs.Error{
+				Type: errs.NotFound,
+				Message: "Cache database '" + database + "' not found in this project.",
+				ToRespondWith: true,
+			}
+		}
+		return 0, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to delete cache database : " + err.Error(),
+		}
+	}
+
+	removed, err := s.backend.Flush(ctx, cache.Namespace{
+		ID: row.Namespace.String(),
+		MaxKeys: row.MaxKeys,
+		Eviction: cache.EvictionPolicy(row.EvictionPolicy),
+	})
+	if err != nil && !errors.Is(err, cache.ErrNotSupported) {
+		fmt.Println("Failed to flush dropped cache database : " + err.Error())
+	}
+	s.feed.Publish(row.Namespace.String(), cache.EventFlush, "")
+
+	return removed, nil
+}
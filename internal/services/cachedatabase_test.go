@@ -0,0 +1,75 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+	"main.go/internal/config"
+	"main.go/internal/const/errs"
+	"main.go/internal/const/roles"
+	"main.go/internal/const/scopes"
+	"main.go/internal/dto"
+	"main.go/internal/utils/apikeys"
+)
+
+func TestCreateDatabaseLimit(t *testing.T) {
+
+	t.Setenv("APIKeyGenerationVersion", "v1")
+	t.Setenv("APIKeySecretPassword", "test-password")
+
+	tests := []struct {
+		name string
+		count int64 // databases the project already has
+		create bool
+	}{
+		{name: "under the limit", count: config.CacheMaxDatabases - 1, create: true},
+		{name: "at the limit", count: config.CacheMaxDatabases},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			queries, mock := newMockQueries(t)
+			s := &CacheService{queries: queries, DB: mock}
+
+			key, err := apikeys.CreateWithOutSeed()
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyHash := apikeys.HashKey(key.Key)
+
+			mock.ExpectQuery(named("GetUserDataFromAPIKey")).WithArgs(keyHash).
+				WillReturnRows(pgxmock.NewRows([]string{"key_id", "service_id", "created_at", "updated_at", "scopes", "revoked", "key_hash", "expires_at", "prev_key_expires_at", "user_id", "role", "user_uiid", "confirmed", "suspended"}).
+					AddRow(int64(3), int64(7), pgtype.Timestamptz{}, pgtype.Timestamptz{}, scopes.Cache, false, keyHash, time.Now().Unix() + 3600, pgtype.Int8{}, int64(1), roles.User, pgtype.UUID{}, true, false))
+
+			// the count and the insert run under the lock of the project row
+			mock.ExpectBegin()
+			mock.ExpectQuery(named("LockService")).WithArgs(int64(7)).
+				WillReturnRows(pgxmock.NewRows([]string{"sid"}).AddRow(int64(7)))
+			mock.ExpectQuery(named("CountCacheDatabases")).WithArgs(int64(7)).
+				WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(tt.count))
+			if tt.create {
+				mock.ExpectQuery(named("InsertCacheDatabase")).WithArgs(int64(7), "sessions", int64(0), int64(0), "noeviction").
+					WillReturnRows(pgxmock.NewRows([]string{"namespace", "created_at"}).AddRow(pgtype.UUID{}, pgtype.Timestamptz{}))
+				mock.ExpectCommit()
+				mock.ExpectRollback().WillReturnError(pgx.ErrTxClosed)
+			} else {
+				mock.ExpectRollback()
+			}
+
+			_, errf := s.CreateDatabase(newTestContext(), key.Key, &dto.NewCacheDatabase{Name: "sessions"})
+			if !tt.create {
+				if errf == nil || errf.Type != errs.PreconditionFailed {
+					t.Fatalf("database over the limit : %+v, want a precondition error", errf)
+				}
+				return
+			}
+			if errf != nil {
+				t.Fatalf("create failed : %+v", errf)
+			}
+		})
+	}
+}
@@ -1,187 +1,1014 @@
 package services
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"os"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"main.go/internal/config"
 	"main.go/internal/const/errs"
+	"main.go/internal/const/scopes"
 	"main.go/internal/dto"
 	sqlc "main.go/internal/sqlc/generate"
+	"main.go/internal/utils/cache"
 )
 
-type CacheSourceURL struct {
-	PutCacheURL string
-	GetCacheURL string
-}
-
 type CacheService struct {
 	queries *sqlc.Queries
-	httpClient *http.Client
-
-	urls *CacheSourceURL
+	DB TxBeginner
+	backend cache.CacheBackend
+	events chan sqlc.InsertCacheDataParams // analytics events, written by recordEvents
+	feed *cache.Feed // changes of keys, for WatchCache
 }
 
-func NewCacheService(queries *sqlc.Queries, client *http.Client, sourceURLs *CacheSourceURL) *CacheService {
-	return &CacheService{
+func NewCacheService(queries *sqlc.Queries, db TxBeginner, backend cache.CacheBackend) *CacheService {
+
+	s := &CacheService{
 		queries: queries,
-		httpClient: client,
-		urls: sourceURLs,
+		DB: db,
+		backend: backend,
+		events: make(chan sqlc.InsertCacheDataParams, config.CacheEventBufferSize),
+		feed: cache.NewFeed(config.CacheWatchBacklog, config.CacheWatchBuffer),
 	}
+	go s.recordEvents()
+	return s
 }
 
 // >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
 
-func (s *CacheService) validateAPIKey(ctx *gin.Context, apiKey string) (*sqlc.GetUserDataFromAPIKeyRow,*errs.Error) {
+// validateAPIKey checks the key and that it was granted the scope of the operation being performed.
+func (s *CacheService) validateAPIKey(ctx *gin.Context, apiKey string, scope string) (*sqlc.GetUserDataFromAPIKeyRow,*errs.Error) {
 
-	// TODO: need better api key validation like ttl and all
-
-	userData, err := s.queries.GetUserDataFromAPIKey(ctx, apiKey)
-	if err != nil {
-		return nil, &errs.Error{
-			Type: errs.NotFound,
-			Message: "API key not found.",
-			ToRespondWith: true,
-		}
+	userData, errf := lookupAPIKey(ctx, s.queries, apiKey)
+	if errf != nil {
+		return nil, errf
 	}
 
-	if !userData.Cache {
+	if !scopes.Has(userData.Scopes, scope) {
 		return nil, &errs.Error{
 			Type: errs.Unauthorized,
-			Message: "API key is found but user is not authorized to use the Cache service.",
+			Message: "API key is found but is not authorized for the '" + scope + "' scope of the Cache service.",
 			ToRespondWith: true,
 		}
 	}
-	
-	return &userData, nil
+
+	return userData, nil
 }
 
-func (s *CacheService) hitSourceURL(ctx *gin.Context, method string, url string, body io.Reader) (*http.Response, *errs.Error) {
+// backendError maps the errors of the cache backend to the ones returned by the service.
+func (s *CacheService) backendError(err error) (*errs.Error) {
 
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
-	if err != nil {
-		return nil, &errs.Error{
+	switch {
+	case errors.Is(err, cache.ErrNotFound):
+		return &errs.Error{
+			Type: errs.NotFound,
+			Message: "Cache key not found.",
+			ToRespondWith: true,
+		}
+	case errors.Is(err, cache.ErrExists):
+		return &errs.Error{
+			Type: errs.ObjectExists,
+			Message: "Cache key already exists, set UpdateIfExists to replace it.",
+			ToRespondWith: true,
+		}
+	case errors.Is(err, cache.ErrTooLarge):
+		return &errs.Error{
+			Type: errs.TooLarge,
+			Message: "Cache value is larger than the cache backend can hold.",
+			ToRespondWith: true,
+		}
+	case errors.Is(err, cache.ErrNotInteger):
+		return &errs.Error{
+			Type: errs.InvalidState,
+			Message: "Cache value is not an integer or the result would overflow.",
+			ToRespondWith: true,
+		}
+	case errors.Is(err, cache.ErrConflict):
+		return &errs.Error{
+			Type: errs.Conflict,
+			Message: "Cache value has changed since it was read, get it again for the new ETag.",
+			ToRespondWith: true,
+		}
+	case errors.Is(err, cache.ErrFull):
+		return &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: "Cache database has reached its max key count and does not evict keys.",
+			ToRespondWith: true,
+		}
+	case errors.Is(err, cache.ErrBadCursor):
+		return &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Invalid cursor, use the one returned by the previous page.",
+			ToRespondWith: true,
+		}
+	case errors.Is(err, cache.ErrNotSupported):
+		return &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: "This operation is not supported by the configured cache backend.",
+			ToRespondWith: true,
+		}
+	default:
+		return &errs.Error{
 			Type: errs.Internal,
-			Message: "Failed to create a new request for cache : " + err.Error(),
+			Message: "Cache backend failed : " + err.Error(),
 		}
 	}
+}
 
-	req.Header.Set("Content-Type", "application/json")
+// encodeValue validates the value against its declared type and returns the bytes to store.
+func (s *CacheService) encodeValue(valueType string, contentType string, value string) ([]byte, *errs.Error) {
 
-	if secretKey, exists := os.LookupEnv("ServiceSecretKey"); exists {
-		req.Header.Set("authorization", secretKey)
-	} else {
-		return nil, &errs.Error{
-			Type: errs.NotFound,
-			Message: "Service secret key not found in env.",
-		}
+	typed := &cache.Value{
+		Type: valueType,
+		Data: []byte(value),
 	}
 
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to get response from cache source : " + err.Error(),
-		}
-	}	
-
-	if resp.StatusCode != http.StatusOK {
-		switch resp.StatusCode {
-		case http.StatusNotFound:
+	switch valueType {
+	case "", cache.TypeString:
+		if !utf8.ValidString(value) || cache.HasHeader(typed.Data) {
 			return nil, &errs.Error{
-				Type: errs.NotFound,
-				Message: "Cache key not found.",
+				Type: errs.InvalidFormat,
+				Message: "String cache values must be valid UTF-8 and cannot start with a NUL byte followed by 'vb1'.",
 				ToRespondWith: true,
 			}
-		case http.StatusPreconditionFailed:
+		}
+
+	case cache.TypeJSON:
+		if !json.Valid(typed.Data) {
 			return nil, &errs.Error{
-				Type: errs.PreconditionFailed,
-				Message: "Cache key already exists.",
+				Type: errs.InvalidFormat,
+				Message: "Cache value is not a valid JSON document.",
 				ToRespondWith: true,
 			}
-		case http.StatusConflict:
+		}
+		typed.ContentType = cache.ContentTypeJSON
+
+	case cache.TypeBytes:
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
 			return nil, &errs.Error{
-				Type: errs.PreconditionFailed,
-				Message: "Cache key already exists.",
+				Type: errs.InvalidFormat,
+				Message: "Bytes cache values must be base64 encoded.",
 				ToRespondWith: true,
 			}
-		default:
+		}
+		typed.Data = data
+
+		typed.ContentType = cache.ContentTypeBytes
+		if contentType != "" {
+			_, _, err = mime.ParseMediaType(contentType)
+			if err != nil || len(contentType) > 255 {
+				return nil, &errs.Error{
+					Type: errs.InvalidFormat,
+					Message: "Cache value content type is not a valid media type.",
+					ToRespondWith: true,
+				}
+			}
+			typed.ContentType = contentType
+		}
+
+	default:
+		return nil, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: fmt.Sprintf("Cache value type must be '%s', '%s' or '%s'.", cache.TypeString, cache.TypeJSON, cache.TypeBytes),
+			ToRespondWith: true,
+		}
+	}
+
+	if len(typed.Data) > config.CacheMaxValueSize {
+		return nil, &errs.Error{
+			Type: errs.TooLarge,
+			Message: fmt.Sprintf("Cache value is %d bytes, larger than the max of %d bytes.", len(typed.Data), config.CacheMaxValueSize),
+			ToRespondWith: true,
+		}
+	}
+
+	return typed.Encode(), nil
+}
+
+// decodeValue reads a value as stored by encodeValue.
+func (s *CacheService) decodeValue(raw []byte) (*cache.Value, *errs.Error) {
+
+	value, err := cache.DecodeValue(raw)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to decode cache value : " + err.Error(),
+		}
+	}
+	return value, nil
+}
+
+// checkTags validates the tags of a key or of an invalidation and returns them without duplicates.
+func (s *CacheService) checkTags(tags []string) ([]string, *errs.Error) {
+
+	if len(tags) > config.CacheMaxTags {
+		return nil, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: fmt.Sprintf("At most %d cache tags can be given at once.", config.CacheMaxTags),
+			ToRespondWith: true,
+		}
+	}
+
+	unique := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if tag == "" || len(tag) > config.CacheMaxTagLength || !utf8.ValidString(tag) {
 			return nil, &errs.Error{
-				Type: errs.Internal,
-				Message: "Failed to get response from cache source : Source responded with code other than 200-OK : " + resp.Status,
+				Type: errs.InvalidFormat,
+				Message: fmt.Sprintf("Cache tags must be UTF-8 strings of 1 to %d bytes.", config.CacheMaxTagLength),
+				ToRespondWith: true,
 			}
 		}
+		if !seen[tag] {
+			seen[tag] = true
+			unique = append(unique, tag)
+		}
 	}
+	return unique, nil
+}
 
-	return resp, nil
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// ANALYTICS
+
+// recordEvent queues a get, with whether the key was found, or else a put for the cache analytics,
+// along with how long the cache backend took. The event is dropped if the queue is full.
+func (s *CacheService) recordEvent(db *cacheDatabase, get bool, hit bool, latency time.Duration) {
+
+	select {
+	case s.events <- sqlc.InsertCacheDataParams{
+		ServiceID: db.serviceID,
+		Get: get,
+		Put: !get,
+		Hit: hit,
+		LatencyUs: latency.Microseconds(),
+	}:
+	default:
+	}
 }
 
+// recordEvents writes the queued events to the analytics table, one after the other, for the lifetime of the service.
+func (s *CacheService) recordEvents() {
+
+	for event := range s.events {
+		err := s.queries.InsertCacheData(context.Background(), event)
+		if err != nil {
+			fmt.Println(errs.Error{
+				Type: errs.IncompleteAction,
+				Message: "Failed to update the cache data to analytics table : " + err.Error(),
+			})
+		}
+	}
+}
 
 // >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
 
-func (s *CacheService) PutNewCache(ctx *gin.Context, data *dto.SetCacheKeyIncoming, apiKey string) (*errs.Error) {
+// PutNewCache writes the key, replacing an existing one only if UpdateIfExists is set.
+// Keys put without a ttl get the default ttl of the database, the tags replace the ones of an existing key.
+// Reports whether the key was created rather than replaced.
+func (s *CacheService) PutNewCache(ctx *gin.Context, data *dto.SetCacheKeyIncoming, apiKey string, database string) (bool, *errs.Error) {
 
-	userData, errf := s.validateAPIKey(ctx, apiKey)
+	value, errf := s.encodeValue(data.ValueType, data.ContentType, data.CacheValue)
 	if errf != nil {
-		return errf
+		return false, errf
+	}
+
+	tags, errf := s.checkTags(data.Tags)
+	if errf != nil {
+		return false, errf
 	}
 
-	outGoing := dto.SetCacheKeyOutgoing{
-		UID: userData.UserUiid.String(),
-		Key: data.CacheKey,
-		Value: data.CacheValue,
-		TTL: data.CacheTTL,
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CachePut)
+	if errf != nil {
+		return false, errf
+	}
+
+	ttl := db.ttl(data.CacheTTL)
+
+	mode := cache.SetIfNotExists
+	if data.UpdateIfExists {
+		mode = cache.SetAlways
 	}
 
-	outGoingBytes, err := json.Marshal(outGoing)
+	start := time.Now()
+	created, err := s.backend.Set(ctx, db.namespace, data.CacheKey, value, ttl, mode, tags)
 	if err != nil {
-		return &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to marshal outgoing put cache json struct : " + err.Error(),
+		return false, s.backendError(err)
+	}
+	s.recordEvent(db, false, false, time.Since(start))
+	s.feed.Publish(db.namespace.ID, cache.EventSet, data.CacheKey)
+
+	return created, nil
+}	
+
+// GetCache returns the value with its type, along with its ETag to be sent back with SwapCache.
+func (s *CacheService) GetCache(ctx *gin.Context, apiKey string, database string, cacheKey string) (*cache.Value, string, *errs.Error) {
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheGet)
+	if errf != nil {
+		return nil, "", errf
+	}
+
+	start := time.Now()
+	raw, err := s.backend.Get(ctx, db.namespace, cacheKey)
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			s.recordEvent(db, true, false, time.Since(start))
 		}
+		return nil, "", s.backendError(err)
+	}
+	s.recordEvent(db, true, true, time.Since(start))
+
+	value, errf := s.decodeValue(raw)
+	if errf != nil {
+		return nil, "", errf
+	}
+
+	return value, cache.ETag(raw), nil
+}
+
+// DeleteCache removes the key, reporting whether it existed.
+func (s *CacheService) DeleteCache(ctx *gin.Context, apiKey string, database string, cacheKey string) (bool, *errs.Error) {
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheDelete)
+	if errf != nil {
+		return false, errf
 	}
 
-	url := fmt.Sprintf("%s%s", config.SourceBaseDomain, config.CacheSetURL)
+	deleted, err := s.backend.Delete(ctx, db.namespace, cacheKey)
+	if err != nil {
+		return false, s.backendError(err)
+	}
+	if deleted {
+		s.feed.Publish(db.namespace.ID, cache.EventDelete, cacheKey)
+	}
+
+	return deleted, nil
+}
 
-	resp, errf := s.hitSourceURL(ctx, "POST", url, bytes.NewBuffer(outGoingBytes))
+func (s *CacheService) CacheExists(ctx *gin.Context, apiKey string, database string, cacheKey string) (bool, *errs.Error) {
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheGet)
+	if errf != nil {
+		return false, errf
+	}
+
+	exists, err := s.backend.Exists(ctx, db.namespace, cacheKey)
+	if err != nil {
+		return false, s.backendError(err)
+	}
+
+	return exists, nil
+}
+
+// CacheTTL returns the remaining ttl of the key in milliseconds, -1 if it never expires.
+func (s *CacheService) CacheTTL(ctx *gin.Context, apiKey string, database string, cacheKey string) (int64, *errs.Error) {
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheGet)
+	if errf != nil {
+		return 0, errf
+	}
+
+	ttl, err := s.backend.TTL(ctx, db.namespace, cacheKey)
+	if err != nil {
+		return 0, s.backendError(err)
+	}
+
+	if ttl == cache.NoExpiry {
+		return -1, nil
+	}
+	return ttl.Milliseconds(), nil
+}
+
+// ExpireCache sets a new ttl, in milliseconds, on an existing key. A ttl of 0 makes the key never expire.
+func (s *CacheService) ExpireCache(ctx *gin.Context, apiKey string, database string, cacheKey string, ttlMillis int64) (*errs.Error) {
+
+	if ttlMillis < 0 {
+		return &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Cache ttl cannot be negative.",
+			ToRespondWith: true,
+		}
+	}
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CachePut)
 	if errf != nil {
-		fmt.Println(errf.Message)
 		return errf
 	}
-	defer resp.Body.Close()
+
+	err := s.backend.Expire(ctx, db.namespace, cacheKey, time.Duration(ttlMillis) * time.Millisecond)
+	if err != nil {
+		return s.backendError(err)
+	}
+	s.feed.Publish(db.namespace.ID, cache.EventExpire, cacheKey)
 
 	return nil
-}	
+}
 
-func (s *CacheService) GetCache(ctx *gin.Context, apiKey string, cacheKey string) (*errs.Error) {
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// ATOMIC OPERATIONS
 
-	userData, errf := s.validateAPIKey(ctx, apiKey)
+// IncrCache atomically adds delta to the integer value of the key and returns the result.
+// A missing key starts from 0 and gets the ttl, in milliseconds, or else the default ttl of the database.
+// An existing key keeps its ttl.
+func (s *CacheService) IncrCache(ctx *gin.Context, apiKey string, database string, cacheKey string, delta int64, ttlMillis int64) (int64, *errs.Error) {
+
+	if ttlMillis < 0 {
+		return 0, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Cache ttl cannot be negative.",
+			ToRespondWith: true,
+		}
+	}
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CachePut)
 	if errf != nil {
-		return errf
+		return 0, errf
+	}
+
+	value, err := s.backend.Incr(ctx, db.namespace, cacheKey, delta, db.ttl(ttlMillis))
+	if err != nil {
+		return 0, s.backendError(err)
 	}
+	s.feed.Publish(db.namespace.ID, cache.EventSet, cacheKey)
 
-	getCacheURL := fmt.Sprintf("%s%s/%s/%s", config.SourceBaseDomain, config.CacheGetURL, userData.UserUiid.String(), cacheKey)
+	return value, nil
+}
+
+// SwapCache replaces the value only if it has not changed since it was read with GetCache.
+func (s *CacheService) SwapCache(ctx *gin.Context, data *dto.SwapCacheKey, apiKey string, database string) (*errs.Error) {
 
-	resp, errf := s.hitSourceURL(ctx, "GET", getCacheURL, nil)
+	if data.CacheKey == "" || data.ETag == "" {
+		return &errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Missing cache key or ETag.",
+			ToRespondWith: true,
+		}
+	}
+	if data.CacheTTL < 0 {
+		return &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Cache ttl cannot be negative.",
+			ToRespondWith: true,
+		}
+	}
+
+	value, errf := s.encodeValue(data.ValueType, data.ContentType, data.CacheValue)
 	if errf != nil {
 		return errf
 	}
-	defer resp.Body.Close()
 
-	_, err := io.Copy(ctx.Writer, resp.Body)
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CachePut)
+	if errf != nil {
+		return errf
+	}
+
+	// the ETag header value is quoted, accept it either way
+	etag := strings.Trim(data.ETag, "\"")
+	ttl := time.Duration(data.CacheTTL) * time.Millisecond
+
+	err := s.backend.CompareAndSwap(ctx, db.namespace, data.CacheKey, etag, value, ttl)
 	if err != nil {
+		return s.backendError(err)
+	}
+	s.feed.Publish(db.namespace.ID, cache.EventSet, data.CacheKey)
+
+	return nil
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// BATCH OPERATIONS
+
+// checkBatchSize rejects empty batches and the ones over config.CacheBatchMaxKeys.
+func (s *CacheService) checkBatchSize(size int) (*errs.Error) {
+
+	if size == 0 {
 		return &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to copy source response body : " + err.Error(),
+			Type: errs.MissingRequiredField,
+			Message: "Batch has no keys.",
+			ToRespondWith: true,
+		}
+	}
+	if size > config.CacheBatchMaxKeys {
+		return &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: "Batch has more than " + strconv.Itoa(config.CacheBatchMaxKeys) + " keys.",
+			ToRespondWith: true,
 		}
 	}
-
 	return nil
 }
 
+// keyError is the error reported for a single key of a batch, internal errors are logged and not sent back.
+func (s *CacheService) keyError(err error) string {
+
+	errf := s.backendError(err)
+	if !errf.ToRespondWith {
+		fmt.Println(errf.Message)
+		return "Cache backend failed."
+	}
+	return errf.Message
+}
+
+// GetCacheBatch gets all the keys at once, misses are reported per key and do not fail the batch.
+func (s *CacheService) GetCacheBatch(ctx *gin.Context, apiKey string, database string, keys []string) ([]dto.CacheBatchGetResult, *errs.Error) {
 
+	errf := s.checkBatchSize(len(keys))
+	if errf != nil {
+		return nil, errf
+	}
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheGet)
+	if errf != nil {
+		return nil, errf
+	}
+
+	results := make([]dto.CacheBatchGetResult, len(keys))
+
+	// only the keys that are set are sent to the backend, indexes keeps their position in the results
+	toGet := make([]string, 0, len(keys))
+	indexes := make([]int, 0, len(keys))
+	for i, key := range keys {
+		results[i].Key = key
+		if key == "" {
+			results[i].Error = "Missing cache key."
+			continue
+		}
+		toGet = append(toGet, key)
+		indexes = append(indexes, i)
+	}
+
+	if len(toGet) == 0 {
+		return results, nil
+	}
+
+	values, err := s.backend.GetMany(ctx, db.namespace, toGet)
+	if err != nil {
+		return nil, s.backendError(err)
+	}
+
+	for j, raw := range values {
+		if raw == nil {
+			continue
+		}
+		result := &results[indexes[j]]
+		result.Hit = true
+
+		value, errf := s.decodeValue(raw)
+		if errf != nil {
+			fmt.Println(errf.Message)
+			result.Error = "Cache value could not be read."
+			continue
+		}
+
+		result.Type = value.Type
+		result.ContentType = value.ContentType
+		if value.Type == cache.TypeBytes {
+			result.Value = base64.StdEncoding.EncodeToString(value.Data)
+		} else {
+			result.Value = string(value.Data)
+		}
+	}
+
+	return results, nil
+}
+
+// PutCacheBatch writes the entries one after the other, each one follows the same rules as PutNewCache.
+func (s *CacheService) PutCacheBatch(ctx *gin.Context, apiKey string, database string, entries []dto.SetCacheKeyIncoming) ([]dto.CacheBatchPutResult, *errs.Error) {
+
+	errf := s.checkBatchSize(len(entries))
+	if errf != nil {
+		return nil, errf
+	}
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CachePut)
+	if errf != nil {
+		return nil, errf
+	}
+
+	namespace := db.namespace
+	results := make([]dto.CacheBatchPutResult, len(entries))
+	for i, entry := range entries {
+		results[i].Key = entry.CacheKey
+		if entry.CacheKey == "" {
+			results[i].Error = "Missing cache key."
+			continue
+		}
+		if entry.CacheTTL < 0 {
+			results[i].Error = "Cache ttl cannot be negative."
+			continue
+		}
+
+		value, errf := s.encodeValue(entry.ValueType, entry.ContentType, entry.CacheValue)
+		if errf != nil {
+			results[i].Error = errf.Message
+			continue
+		}
+
+		tags, errf := s.checkTags(entry.Tags)
+		if errf != nil {
+			results[i].Error = errf.Message
+			continue
+		}
+
+		mode := cache.SetIfNotExists
+		if entry.UpdateIfExists {
+			mode = cache.SetAlways
+		}
+
+		ttl := db.ttl(entry.CacheTTL)
+		created, err := s.backend.Set(ctx, namespace, entry.CacheKey, value, ttl, mode, tags)
+		if err != nil {
+			results[i].Error = s.keyError(err)
+			continue
+		}
+		results[i].Created = created
+		s.feed.Publish(namespace.ID, cache.EventSet, entry.CacheKey)
+	}
+
+	return results, nil
+}
+
+// DeleteCacheBatch removes all the keys at once, reporting per key whether it existed.
+func (s *CacheService) DeleteCacheBatch(ctx *gin.Context, apiKey string, database string, keys []string) ([]dto.CacheBatchDeleteResult, *errs.Error) {
+
+	errf := s.checkBatchSize(len(keys))
+	if errf != nil {
+		return nil, errf
+	}
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheDelete)
+	if errf != nil {
+		return nil, errf
+	}
+
+	results := make([]dto.CacheBatchDeleteResult, len(keys))
+
+	toDelete := make([]string, 0, len(keys))
+	indexes := make([]int, 0, len(keys))
+	for i, key := range keys {
+		results[i].Key = key
+		if key == "" {
+			results[i].Error = "Missing cache key."
+			continue
+		}
+		toDelete = append(toDelete, key)
+		indexes = append(indexes, i)
+	}
+
+	if len(toDelete) == 0 {
+		return results, nil
+	}
+
+	deleted, err := s.backend.DeleteMany(ctx, db.namespace, toDelete)
+	if err != nil {
+		return nil, s.backendError(err)
+	}
+
+	for j, ok := range deleted {
+		results[indexes[j]].Deleted = ok
+		if ok {
+			s.feed.Publish(db.namespace.ID, cache.EventDelete, toDelete[j])
+		}
+	}
+
+	return results, nil
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// TAGS
+
+// InvalidateCache removes every key of the database carrying any of the tags and returns how many were removed.
+func (s *CacheService) InvalidateCache(ctx *gin.Context, apiKey string, database string, tags []string) (int64, *errs.Error) {
+
+	if len(tags) == 0 {
+		return 0, &errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "No cache tags to invalidate.",
+			ToRespondWith: true,
+		}
+	}
+
+	tags, errf := s.checkTags(tags)
+	if errf != nil {
+		return 0, errf
+	}
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheDelete)
+	if errf != nil {
+		return 0, errf
+	}
+
+	removed, err := s.backend.Invalidate(ctx, db.namespace, tags)
+	if err != nil {
+		return 0, s.backendError(err)
+	}
+	if removed > 0 {
+		s.feed.Publish(db.namespace.ID, cache.EventFlush, "")
+	}
+
+	return removed, nil
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// KEY LISTING
+
+// ListKeys returns a page of the keys of the database starting with the prefix, from the cursor of the previous page.
+// A limit of 0 gets config.CacheListDefaultLimit keys.
+func (s *CacheService) ListKeys(ctx *gin.Context, apiKey string, database string, prefix string, cursor string, limit int) (*dto.CacheKeyPage, *errs.Error) {
+
+	if limit == 0 {
+		limit = config.CacheListDefaultLimit
+	}
+	if limit < 0 || limit > config.CacheListMaxLimit {
+		return nil, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: fmt.Sprintf("Limit must be between 1 and %d keys.", config.CacheListMaxLimit),
+			ToRespondWith: true,
+		}
+	}
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheGet)
+	if errf != nil {
+		return nil, errf
+	}
+
+	keys, next, err := s.backend.Scan(ctx, db.namespace, prefix, cursor, limit)
+	if err != nil {
+		return nil, s.backendError(err)
+	}
+
+	page := &dto.CacheKeyPage{
+		Keys: make([]dto.CacheKeyInfo, len(keys)),
+		Cursor: next,
+	}
+	for i, key := range keys {
+		page.Keys[i] = dto.CacheKeyInfo{
+			Key: key.Key,
+			TTL: key.TTL.Milliseconds(),
+			Size: key.Size,
+		}
+		if key.TTL == cache.NoExpiry {
+			page.Keys[i].TTL = -1
+		}
+	}
+
+	return page, nil
+}
+
+// DeleteKeys removes every key of the database starting with the prefix, all of them for an empty prefix,
+// and returns how many were removed.
+func (s *CacheService) DeleteKeys(ctx *gin.Context, apiKey string, database string, prefix string) (int64, *errs.Error) {
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheDelete)
+	if errf != nil {
+		return 0, errf
+	}
+
+	removed, err := s.backend.DeletePrefix(ctx, db.namespace, prefix)
+	if err != nil {
+		return 0, s.backendError(err)
+	}
+	if removed > 0 {
+		s.feed.Publish(db.namespace.ID, cache.EventFlush, prefix)
+	}
+
+	return removed, nil
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// CHANGE FEED
+
+// WatchCache starts watching the changes of the keys of the database matching the pattern, all keys if empty.
+// The pattern is matched as with path.Match, '*' for any run of characters and '?' for any single one.
+// With a lastEventID the events after it are returned to be sent first, along with whether none were missed.
+// Keys removed by invalidation, by prefix or by dropping the database are reported by a single flush event.
+// The caller must close the watcher.
+func (s *CacheService) WatchCache(ctx *gin.Context, apiKey string, database string, pattern string, lastEventID string) (*cache.Watcher, []cache.Event, bool, *errs.Error) {
+
+	if pattern == "" {
+		pattern = "*"
+	}
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, nil, false, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Invalid watch pattern : " + err.Error(),
+			ToRespondWith: true,
+		}
+	}
+
+	var lastID uint64
+	resume := lastEventID != ""
+	if resume {
+		lastID, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return nil, nil, false, &errs.Error{
+				Type: errs.InvalidFormat,
+				Message: "Invalid Last-Event-ID, use the id of the last event received.",
+				ToRespondWith: true,
+			}
+		}
+	}
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheGet)
+	if errf != nil {
+		return nil, nil, false, errf
+	}
+
+	match := func(key string) bool {
+		matched, _ := path.Match(pattern, key)
+		return matched
+	}
+	watcher, missed, complete := s.feed.Watch(db.namespace.ID, match, resume, lastID)
+
+	return watcher, missed, complete, nil
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// EXPORT AND IMPORT
+
+// exportTTL returns the remaining ttl in milliseconds, rounded up so a key about to expire is not exported
+// with the 0 that imports read as no expiry, and -1 for keys that never expire. It is false for expired keys.
+func exportTTL(ttl time.Duration) (int64, bool) {
+
+	if ttl == cache.NoExpiry {
+		return -1, true
+	}
+	if ttl <= 0 {
+		return 0, false
+	}
+	return int64((ttl + time.Millisecond - 1) / time.Millisecond), true
+}
+
+// ExportCache passes every key of the database, with its value and remaining ttl, to write, reading a page of keys at a time.
+// Keys written while it runs may or may not be exported, and tags are not exported.
+// Once write has been called the response has started, so a returned error can no longer be sent as is.
+func (s *CacheService) ExportCache(ctx *gin.Context, apiKey string, database string, write func(entry *dto.CacheExportEntry) error) (*errs.Error) {
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CacheGet)
+	if errf != nil {
+		return errf
+	}
+
+	cursor := ""
+	for {
+		keys, next, err := s.backend.Scan(ctx, db.namespace, "", cursor, config.CacheExportPageSize)
+		if err != nil {
+			return s.backendError(err)
+		}
+
+		names := make([]string, len(keys))
+		for i, key := range keys {
+			names[i] = key.Key
+		}
+
+		values := [][]byte{}
+		if len(names) > 0 {
+			values, err = s.backend.GetMany(ctx, db.namespace, names)
+			if err != nil {
+				return s.backendError(err)
+			}
+		}
+
+		for i, raw := range values {
+			// removed since it was listed
+			if raw == nil {
+				continue
+			}
+
+			ttl, live := exportTTL(keys[i].TTL)
+			if !live {
+				continue
+			}
+
+			value, errf := s.decodeValue(raw)
+			if errf != nil {
+				return errf
+			}
+
+			entry := &dto.CacheExportEntry{
+				Key: keys[i].Key,
+				Value: string(value.Data),
+				Type: value.Type,
+				TTL: ttl,
+			}
+			if value.Type == cache.TypeBytes {
+				entry.Value = base64.StdEncoding.EncodeToString(value.Data)
+				entry.ContentType = value.ContentType
+			}
+
+			err = write(entry)
+			if err != nil {
+				return &errs.Error{
+					Type: errs.Internal,
+					Message: "Failed to write cache export entry : " + err.Error(),
+				}
+			}
+		}
+
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// ImportCache puts the entries of an export, one json entry per line, as they are read from body.
+// Existing keys are kept unless overwrite is set, and the default ttl of the database is not applied.
+// A failed line does not stop the import, reading the body does.
+func (s *CacheService) ImportCache(ctx *gin.Context, apiKey string, database string, body io.Reader, overwrite bool) (*dto.CacheImportResult, *errs.Error) {
+
+	db, errf := s.authorize(ctx, apiKey, database, scopes.CachePut)
+	if errf != nil {
+		return nil, errf
+	}
+
+	mode := cache.SetIfNotExists
+	if overwrite {
+		mode = cache.SetAlways
+	}
+
+	result := &dto.CacheImportResult{}
+	fail := func(line int64, key string, message string) {
+		result.Failed++
+		if len(result.Errors) < config.CacheImportMaxErrors {
+			result.Errors = append(result.Errors, dto.CacheImportError{
+				Line: line,
+				Key: key,
+				Error: message,
+			})
+		}
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64 << 10), config.CacheImportMaxLineSize)
+
+	var line int64
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		entry := new(dto.CacheExportEntry)
+		err := json.Unmarshal(scanner.Bytes(), entry)
+		if err != nil || entry.Key == "" {
+			fail(line, entry.Key, "Invalid entry, expected a json object with at least a key and a value.")
+			continue
+		}
+
+		value, errf := s.encodeValue(entry.Type, entry.ContentType, entry.Value)
+		if errf != nil {
+			fail(line, entry.Key, errf.Message)
+			continue
+		}
+
+		var ttl time.Duration
+		if entry.TTL > 0 {
+			ttl = time.Duration(entry.TTL) * time.Millisecond
+		}
+
+		_, err = s.backend.Set(ctx, db.namespace, entry.Key, value, ttl, mode, nil)
+		if err != nil {
+			if errors.Is(err, cache.ErrExists) {
+				result.Skipped++
+				continue
+			}
+			fail(line, entry.Key, s.keyError(err))
+			continue
+		}
+		result.Imported++
+		s.feed.Publish(db.namespace.ID, cache.EventSet, entry.Key)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, &errs.Error{
+				Type: errs.TooLarge,
+				Message: fmt.Sprintf("Line %d is longer than the max of %d bytes, the %d keys before it were imported.", line + 1, config.CacheImportMaxLineSize, result.Imported),
+				ToRespondWith: true,
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.IncompleteAction,
+			Message: fmt.Sprintf("Failed to read the import after line %d, the %d keys before it were imported : %s", line, result.Imported, err.Error()),
+			ToRespondWith: true,
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"main.go/internal/utils/cache"
+)
+
+func TestExportTTL(t *testing.T) {
+
+	tests := []struct {
+		name string
+		ttl time.Duration
+		want int64
+		live bool
+	}{
+		{name: "no expiry", ttl: cache.NoExpiry, want: -1, live: true},
+		{name: "whole milliseconds", ttl: 1500 * time.Millisecond, want: 1500, live: true},
+		{name: "rounded up", ttl: 1500 * time.Millisecond + time.Microsecond, want: 1501, live: true},
+		{name: "under a millisecond", ttl: time.Microsecond, want: 1, live: true},
+		{name: "expired", ttl: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, live := exportTTL(tt.ttl)
+			if live != tt.live || (live && got != tt.want) {
+				t.Fatalf("exportTTL(%v) = %d, %v, want %d, %v", tt.ttl, got, live, tt.want, tt.live)
+			}
+		})
+	}
+}
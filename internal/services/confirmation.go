@@ -0,0 +1,41 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"main.go/internal/config"
+	"main.go/internal/const/errs"
+	"main.go/internal/utils/mailer"
+	"main.go/internal/utils/tokens"
+)
+
+// sendConfirmation mails the user a link, valid for a day, that confirms their email.
+func sendConfirmation(ctx *gin.Context, m mailer.Mailer, userID int64, email string) (*errs.Error) {
+
+	token, err := tokens.NewConfirmToken(userID, email)
+	if err != nil {
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to create confirmation token : " + err.Error(),
+		}
+	}
+
+	link := os.Getenv("FrontendBaseURL") + config.ConfirmEmailPath + "?token=" + url.QueryEscape(token)
+
+	err = m.Send(ctx, &mailer.Message{
+		To: email,
+		Subject: "Confirm your VaultBase email",
+		Body: fmt.Sprintf("Welcome to VaultBase!\n\nConfirm your email to start creating projects:\n%s\n\nThe link expires in 24 hours.\n", link),
+	})
+	if err != nil {
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to send confirmation email : " + err.Error(),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+	"main.go/internal/const/errs"
+	"main.go/internal/const/roles"
+	"main.go/internal/dto"
+	"main.go/internal/utils/mailer"
+)
+
+// userDataRow is the GetUserData row of a user that is neither deleted nor suspended.
+func userDataRow(confirmed bool) *pgxmock.Rows {
+	return pgxmock.NewRows([]string{"user_id", "email", "role", "user_uiid", "created_at", "confirmed", "deleted", "suspended"}).
+		AddRow(int64(1), "a@example.com", roles.User, pgtype.UUID{}, pgtype.Timestamptz{}, confirmed, false, false)
+}
+
+// confirmToken takes the token out of the link in the confirmation mail.
+func confirmToken(t *testing.T, msg mailer.Message) string {
+
+	_, query, found := strings.Cut(msg.Body, "?token=")
+	if !found {
+		t.Fatalf("no confirmation link in the mail : %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(strings.Fields(query)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestSignupConfirmNewProject(t *testing.T) {
+
+	t.Setenv("JWTSecretKey", "test-secret")
+	t.Setenv("APIKeyGenerationVersion", "v1")
+	t.Setenv("APIKeySecretPassword", "test-password")
+	t.Setenv("FrontendBaseURL", "https://app.example.com")
+
+	queries, mock := newMockQueries(t)
+	mails := mailer.NewMemoryMailer()
+	authService := NewAuthService(queries, mails)
+	publicService := NewPublicService(queries, mock, nil, mails)
+	project := &dto.NewProject{Name: "my-first-project", Cache: true}
+
+	// 1) signup mails a confirmation link
+	mock.ExpectQuery(named("SignupUserWithPassword")).WithArgs("a@example.com", pgxmock.AnyArg(), roles.User).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(int64(1)))
+	mock.ExpectExec(named("InsertRefreshToken")).WithArgs(int64(1), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	_, errf := authService.Signup(newTestContext(), &dto.LoginData{Email: "A@example.com", Password: "password123"})
+	if errf != nil {
+		t.Fatalf("signup failed : %+v", errf)
+	}
+
+	sent := mails.Sent()
+	if len(sent) != 1 || sent[0].To != "a@example.com" {
+		t.Fatalf("sent = %+v, want one mail to a@example.com", sent)
+	}
+
+	// 2) projects are refused until the email is confirmed
+	mock.ExpectQuery(named("GetUserData")).WithArgs(int64(1)).WillReturnRows(userDataRow(false))
+
+	_, errf = publicService.NewProject(newTestContext(), 1, project)
+	if errf == nil || errf.Type != errs.PreconditionFailed {
+		t.Fatalf("project of an unconfirmed user : %+v, want a precondition error", errf)
+	}
+
+	// 3) the link confirms the email it was sent to
+	mock.ExpectExec(named("ConfirmUser")).WithArgs(int64(1), "a@example.com").
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	errf = publicService.ConfirmEmail(newTestContext(), 1, confirmToken(t, sent[0]))
+	if errf != nil {
+		t.Fatalf("confirm failed : %+v", errf)
+	}
+
+	// 4) and the project is created
+	mock.ExpectQuery(named("GetUserData")).WithArgs(int64(1)).WillReturnRows(userDataRow(true))
+	mock.ExpectQuery(named("GetServiceCountForUserID")).WithArgs(int64(1), project.Name).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	mock.ExpectBegin()
+	mock.ExpectQuery(named("InsertNewService")).WithArgs(int64(1), project.Name).
+		WillReturnRows(pgxmock.NewRows([]string{"sid", "service_uuid", "created_at"}).AddRow(int64(7), pgtype.UUID{Valid: true}, pgtype.Timestamptz{}))
+	mock.ExpectQuery(named("InsertKey")).WithArgs(int64(7), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(pgxmock.NewRows([]string{"key_id", "created_at"}).AddRow(int64(1), pgtype.Timestamptz{}))
+	mock.ExpectCommit()
+	// the deferred rollback, pgx reports the committed transaction as closed
+	mock.ExpectRollback().WillReturnError(pgx.ErrTxClosed)
+
+	resp, errf := publicService.NewProject(newTestContext(), 1, project)
+	if errf != nil {
+		t.Fatalf("project of a confirmed user : %+v", errf)
+	}
+	if resp.KeyInfo == nil || resp.KeyInfo.Key == "" {
+		t.Fatalf("project created without a key : %+v", resp)
+	}
+}
+
+func TestConfirmEmailOfAnotherUser(t *testing.T) {
+
+	t.Setenv("JWTSecretKey", "test-secret")
+
+	queries, _ := newMockQueries(t)
+	mails := mailer.NewMemoryMailer()
+	publicService := NewPublicService(queries, nil, nil, mails)
+
+	errf := sendConfirmation(newTestContext(), mails, 2, "b@example.com")
+	if errf != nil {
+		t.Fatal(errf.Message)
+	}
+
+	errf = publicService.ConfirmEmail(newTestContext(), 1, confirmToken(t, mails.Sent()[0]))
+	if errf == nil || errf.Type != errs.Unauthorized {
+		t.Fatalf("token of another user : %+v, want an unauthorized error", errf)
+	}
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -10,26 +11,37 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/pgtype"
 	"main.go/internal/config"
 	"main.go/internal/const/errs"
+	"main.go/internal/const/roles"
+	"main.go/internal/const/scopes"
 	"main.go/internal/dto"
 	sqlc "main.go/internal/sqlc/generate"
 	apikeys "main.go/internal/utils/apikeys"
+	"main.go/internal/utils/mailer"
+	"main.go/internal/utils/tokens"
 )
 
+// TxBeginner starts the transactions of the services, a *pgxpool.Pool in production.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
 type PublicService struct {
 	queries *sqlc.Queries
-	DB *pgxpool.Pool
+	DB TxBeginner
 	ClerkUserClient *user.Client
+	Mailer mailer.Mailer
 
 }
 
-func NewPublicService(queries *sqlc.Queries, db *pgxpool.Pool, clerkUserClient *user.Client) *PublicService {
+func NewPublicService(queries *sqlc.Queries, db TxBeginner, clerkUserClient *user.Client, mailer mailer.Mailer) *PublicService {
 	return &PublicService{
 		queries: queries,
 		DB: db,
 		ClerkUserClient: clerkUserClient,
+		Mailer: mailer,
 	}
 }
 
@@ -51,17 +63,104 @@ func (s *PublicService) userIsServiceOwner(ctx *gin.Context, userID int64, servi
 	
 	serviceData, err := s.queries.GetServiceData(ctx, servicename)
 	if err != nil {
-		// TODO: process pgerr
-		return nil, &errs.Error{}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &errs.Error{
+				Type: errs.NotFound,
+				Message: "No such project found.",
+				ToRespondWith: true,
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to get service data : " + err.Error(),
+		}
 	}	
 
 	// 2) check if user is owner of service
 	if serviceData.UserID != userID {
-		return nil, &errs.Error{}	
+		return nil, &errs.Error{
+			Type: errs.NotFound,
+			Message: "No such project found.",
+			ToRespondWith: true,
+		}	
 	}
 	return &serviceData, nil
 }
 
+// normalizeScopes rejects unknown scopes and drops duplicates.
+func (s *PublicService) normalizeScopes(requested []string) ([]string, *errs.Error) {
+
+	normalized, unknown, ok := scopes.Normalize(requested)
+	if !ok {
+		return nil, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: fmt.Sprintf("Unknown key scope '%s'.", unknown),
+			ToRespondWith: true,
+		}
+	}
+	return normalized, nil
+}
+
+// insertKey generates a new api key for the service and stores its hash.
+// queries can be bound to a transaction, the full key is only ever present in the returned response.
+func (s *PublicService) insertKey(ctx *gin.Context, queries *sqlc.Queries, serviceID int64, label string, ttl int64, requestedScopes []string) (*dto.APIKeyResponse, *errs.Error) {
+
+	keyScopes, errf := s.normalizeScopes(requestedScopes)
+	if errf != nil {
+		return nil, errf
+	}
+
+	if ttl <= 0 {
+		ttl = config.DefaultAPIKeyTTL
+	}
+	if ttl > config.MaxAPIKeyTTL {
+		return nil, &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: fmt.Sprintf("Key ttl exceeds the maximum allowed. Max ttl: %d seconds.", config.MaxAPIKeyTTL),
+			ToRespondWith: true,
+		}
+	}
+	if label == "" {
+		label = config.DefaultAPIKeyLabel
+	}
+
+	apiCreds, err := apikeys.CreateWithOutSeed()
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to generate api key : " + err.Error(),
+		}
+	}
+	expiresAt := apiCreds.CreatedAt + ttl
+
+	// only a hash and a short prefix are stored, the full key is shown once in the response
+	keyData, err := queries.InsertKey(ctx, sqlc.InsertKeyParams{
+		ServiceID: serviceID,
+		KeyHash: apikeys.HashKey(apiCreds.Key),
+		KeyPrefix: apikeys.KeyPrefix(apiCreds.Key),
+		Label: label,
+		Scopes: keyScopes,
+		ExpiresAt: expiresAt,
+		ID: apiCreds.ID,
+	})
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to insert a new api key : " + err.Error(),
+		}
+	}
+
+	return &dto.APIKeyResponse{
+		ID: apiCreds.ID,
+		Key: apiCreds.Key,
+		Prefix: apikeys.KeyPrefix(apiCreds.Key),
+		Label: label,
+		CreatedAt: keyData.CreatedAt.Time.Unix(),
+		ExpiresAt: expiresAt,
+		Scopes: keyScopes,
+	}, nil
+}
+
 func (s *PublicService) parseScopeInterval(scopeStr, intervalStr string) (int64, int64, *errs.Error) {
 
 	scope, err := strconv.ParseInt(scopeStr, 10, 64)
@@ -89,9 +188,12 @@ func (s *PublicService) parseScopeInterval(scopeStr, intervalStr string) (int64,
 // >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
 
 
-func (s *PublicService) NewUser(ctx *gin.Context, signupData *dto.SignupData) (bool, *errs.Error) {
+// NewUser registers the clerk user, with the email of its clerk record.
+// The clerk ID comes from the verified token and the email from clerk, never from the client.
+// An email clerk verified is confirmed as is, the others are mailed a confirmation link.
+func (s *PublicService) NewUser(ctx *gin.Context, clerkID string) (bool, *errs.Error) {
 
-	cnt, err := s.queries.CheckUserExistence(ctx, signupData.ClerkID)
+	cnt, err := s.queries.CheckUserExistence(ctx, clerkID)
 	if err != nil {
 		return false, nil // err
 	}
@@ -100,10 +202,31 @@ func (s *PublicService) NewUser(ctx *gin.Context, signupData *dto.SignupData) (b
 		return false, nil
 	}
 
-	err = s.queries.SignupUser(ctx, sqlc.SignupUserParams{
-		Email: signupData.Email,
-		Role: 1,
-		ClerkID: signupData.ClerkID,
+	if s.ClerkUserClient == nil {
+		return false, &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: "Clerk signup is not enabled.",
+			ToRespondWith: true,
+		}
+	}
+	clerkUser, err := s.ClerkUserClient.Get(ctx, clerkID)
+	if err != nil {
+		return false, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to get clerk user : " + err.Error(),
+		}
+	}
+	email, confirmed, errf := primaryEmail(clerkUserData(clerkUser))
+	if errf != nil {
+		return false, errf
+	}
+
+	// upserted, the webhook of the clerk user can register it meanwhile
+	userID, err := s.queries.UpsertClerkUser(ctx, sqlc.UpsertClerkUserParams{
+		Email: email,
+		Role: roles.User,
+		ClerkID: clerkID,
+		Confirmed: confirmed,
 	})
 	if err != nil {
 		var pgerr *pgconn.PgError
@@ -121,11 +244,97 @@ func (s *PublicService) NewUser(ctx *gin.Context, signupData *dto.SignupData) (b
 			Message: "Failed to insert new user : " + err.Error(),
 		}
 	}
-	fmt.Println("error")
+
+	if confirmed {
+		return false, nil
+	}
+
+	errf = sendConfirmation(ctx, s.Mailer, userID, email)
+	if errf != nil {
+		// not fatal, a new link can be requested from /public/resendconfirm
+		fmt.Println(errf.Message)
+	}
 
 	return false, nil
 }
 
+// ConfirmEmail confirms the email of the user with the token from the confirmation link.
+// The token has to belong to the same user and carry their current email.
+func (s *PublicService) ConfirmEmail(ctx *gin.Context, userID int64, confirmToken string) (*errs.Error) {
+
+	token, err := tokens.Parse(confirmToken, tokens.Confirm)
+	if err != nil {
+		if errors.Is(err, tokens.ErrExpiredToken) {
+			return &errs.Error{
+				Type: errs.Expired,
+				Message: "Confirmation link has expired, request a new one.",
+				ToRespondWith: true,
+			}
+		}
+		if errors.Is(err, tokens.ErrMissingSecret) {
+			return &errs.Error{
+				Type: errs.Internal,
+				Message: "Failed to parse confirmation token : " + err.Error(),
+			}
+		}
+		return &errs.Error{
+			Type: errs.Unauthorized,
+			Message: "Invalid confirmation token.",
+			ToRespondWith: true,
+		}
+	}
+
+	if token.ID != userID {
+		return &errs.Error{
+			Type: errs.Unauthorized,
+			Message: "Confirmation token belongs to another account.",
+			ToRespondWith: true,
+		}
+	}
+
+	affected, err := s.queries.ConfirmUser(ctx, sqlc.ConfirmUserParams{
+		UserID: userID,
+		Email: token.Email,
+	})
+	if err != nil {
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to confirm user : " + err.Error(),
+		}
+	}
+	if affected == 0 {
+		return &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: "The email has changed since this link was sent, request a new one.",
+			ToRespondWith: true,
+		}
+	}
+
+	return nil
+}
+
+// ResendConfirmation mails a new confirmation link to a user that is not confirmed yet.
+func (s *PublicService) ResendConfirmation(ctx *gin.Context, userID int64) (*errs.Error) {
+
+	userData, err := s.queries.GetUserData(ctx, userID)
+	if err != nil {
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to retrieve user info : " + err.Error(),
+		}
+	}
+
+	if userData.Confirmed {
+		return &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: "Email is already confirmed.",
+			ToRespondWith: true,
+		}
+	}
+
+	return sendConfirmation(ctx, s.Mailer, userID, userData.Email)
+}
+
 // NewService registers a new service instance for a valid user, 
 // generates an associated API key with selected features (cache, storage),
 // and returns the service metadata along with the generated key.
@@ -168,6 +377,14 @@ func (s *PublicService) NewProject(ctx *gin.Context, userID int64, data *dto.New
 		}
 	}
 
+	if !userData.Confirmed {
+		return nil, &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: "Confirm your email before creating a project. Check your inbox or request a new link.",
+			ToRespondWith: true,
+		}
+	}
+
 	// 2)	check for service name uniqueness
 
 	sameCount, err := s.queries.GetServiceCountForUserID(ctx, sqlc.GetServiceCountForUserIDParams{
@@ -205,35 +422,9 @@ func (s *PublicService) NewProject(ctx *gin.Context, userID int64, data *dto.New
 	}()
 	txQueries := s.queries.WithTx(tx)
 
-	// 3) generate api key and insert into table
-
-	apiCreds, err := apikeys.CreateWithOutSeed()
-	if err != nil {
-		return nil, &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to generate api key : " + err.Error(),
-		}
-	}
-	expiresAt := time.Now().Unix() + config.DefaultAPIKeyTTL
-
-	keyData, err := txQueries.InsertKey(ctx, sqlc.InsertKeyParams{
-		Key: apiCreds.Key,
-		Cache: data.Cache,
-		Storage: data.Storage,
-		ExpiresAt: expiresAt,
-		ID: apiCreds.ID,
-	})
-	if err != nil {
-		return nil, &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to insert a new api key : " + err.Error(),
-		}
-	}
-
-	// 4) create a new appropriate service, select confirms 
+	// 3) create a new appropriate service
 	serviceData, err := txQueries.InsertNewService(ctx, sqlc.InsertNewServiceParams{
 		UserID: userID,
-		KeyID: keyData.KeyID,
 		Name: data.Name,
 	})
 	if err != nil {
@@ -243,6 +434,20 @@ func (s *PublicService) NewProject(ctx *gin.Context, userID int64, data *dto.New
 		}
 	}
 
+	// 4) generate the first api key of the project, select confirms 
+	keyScopes := make([]string, 0)
+	if data.Cache {
+		keyScopes = append(keyScopes, scopes.Cache...)
+	}
+	if data.Storage {
+		keyScopes = append(keyScopes, scopes.Storage...)
+	}
+
+	keyInfo, errf := s.insertKey(ctx, txQueries, serviceData.Sid, config.DefaultAPIKeyLabel, 0, keyScopes)
+	if errf != nil {
+		return nil, errf
+	}
+
 	err = tx.Commit(ctx)
 	if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
 		return nil, &errs.Error{
@@ -251,25 +456,18 @@ func (s *PublicService) NewProject(ctx *gin.Context, userID int64, data *dto.New
 		}
 	}
 
-	// _, _ = apikeys.CreateWithGiven(serviceData.String())
-
 	// 5) respond with api key and confirmation and some other details
 	return &dto.NewProjectResp{
-		ServiceUUID: serviceData.String(),
-		KeyInfo: &dto.APIKeyResponse{
-			ID: apiCreds.ID,
-			Key: apiCreds.Key,
-			CreatedAt: keyData.CreatedAt.Time.Unix(),
-			ExpiresAt: expiresAt,
-			Cache: data.Cache,
-			Storage: data.Storage,
-		},
+		ServiceUUID: serviceData.ServiceUuid.String(),
+		ServiceName: data.Name,
+		ServiceCreatedAt: serviceData.CreatedAt.Time.Unix(),
+		KeyInfo: keyInfo,
 	}, nil
 }
 
 
-// EnableService changes the confirmation services allowed on a certain key/project.
-func (s *PublicService) ToggleService(ctx *gin.Context, userID int64, data *dto.NewProject) (*dto.APIKeyResponse, *errs.Error) {
+// ToggleService changes the scopes allowed on a single key of a project, other keys are left untouched.
+func (s *PublicService) ToggleService(ctx *gin.Context, userID int64, data *dto.ToggleKey) (*dto.APIKeyResponse, *errs.Error) {
 
 	// 1) check if service exists
 	serviceData, errf := s.userIsServiceOwner(ctx, userID, data.Name)
@@ -277,23 +475,235 @@ func (s *PublicService) ToggleService(ctx *gin.Context, userID int64, data *dto.
 		return nil, errf
 	}
 
-	// 3) update requested attribute 
-	updatedKeyData, err := s.queries.UpdateKeyServicesConfirmation(ctx, sqlc.UpdateKeyServicesConfirmationParams{
-		KeyID: serviceData.KeyID,
-		Cache: data.Cache,
-		Storage: data.Storage,
+	keyScopes, errf := s.normalizeScopes(data.Scopes)
+	if errf != nil {
+		return nil, errf
+	}
+
+	// 2) update requested attribute 
+	updatedScopes, err := s.queries.UpdateKeyScopes(ctx, sqlc.UpdateKeyScopesParams{
+		ServiceID: serviceData.Sid,
+		ID: data.ID,
+		Scopes: keyScopes,
 	})
 	if err != nil {
-		return nil, nil
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &errs.Error{
+				Type: errs.NotFound,
+				Message: "No such key found for the project.",
+				ToRespondWith: true,
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to update key scopes : " + err.Error(),
+		}
+	}
+
+	return &dto.APIKeyResponse{
+		ID: data.ID,
+		Scopes: updatedScopes,
+	}, nil
+}
+
+// NewKey creates an additional api key on an existing project, with its own label, expiry and scopes.
+func (s *PublicService) NewKey(ctx *gin.Context, userID int64, data *dto.NewKey) (*dto.APIKeyResponse, *errs.Error) {
+
+	serviceData, errf := s.userIsServiceOwner(ctx, userID, data.Name)
+	if errf != nil {
+		return nil, errf
+	}
+
+	return s.insertKey(ctx, s.queries, serviceData.Sid, data.Label, data.TTL, data.Scopes)
+}
+
+// AllKeys lists every key of a project, including revoked ones. Only the key prefixes are returned.
+func (s *PublicService) AllKeys(ctx *gin.Context, userID int64, servicename string) ([]*dto.APIKeyResponse, *errs.Error) {
+
+	serviceData, errf := s.userIsServiceOwner(ctx, userID, servicename)
+	if errf != nil {
+		return nil, errf
+	}
+
+	keysData, err := s.queries.GetServiceKeys(ctx, serviceData.Sid)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to get service keys : " + err.Error(),
+		}
+	}
+
+	resp := make([]*dto.APIKeyResponse, 0, len(keysData))
+	for _, key := range keysData {
+		resp = append(resp, &dto.APIKeyResponse{
+			ID: key.ID,
+			Prefix: key.KeyPrefix,
+			Label: key.Label,
+			CreatedAt: key.CreatedAt.Time.Unix(),
+			ExpiresAt: key.ExpiresAt,
+			Revoked: key.Revoked,
+			Scopes: key.Scopes,
+		})
+	}
+
+	return resp, nil
+}
+
+// RevokeKey permanently disables a single key of a project.
+func (s *PublicService) RevokeKey(ctx *gin.Context, userID int64, data *dto.RevokeKey) (*errs.Error) {
+
+	serviceData, errf := s.userIsServiceOwner(ctx, userID, data.Name)
+	if errf != nil {
+		return errf
+	}
+
+	_, err := s.queries.RevokeKey(ctx, sqlc.RevokeKeyParams{
+		ServiceID: serviceData.Sid,
+		ID: data.ID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &errs.Error{
+				Type: errs.NotFound,
+				Message: "No such key found for the project.",
+				ToRespondWith: true,
+			}
+		}
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to revoke key : " + err.Error(),
+		}
+	}
+
+	return nil
+}
+
+// RenewKey reissues the given key for as long as it was issued for, or for data.TTL if set. The old key stays valid for
+// config.APIKeyRenewalGracePeriod seconds so that clients can roll over without downtime.
+func (s *PublicService) RenewKey(ctx *gin.Context, userID int64, data *dto.RenewKey) (*dto.APIKeyResponse, *errs.Error) {
+
+	// 1) the old key must be genuine, expired ones are fine
+	oldKey, err := apikeys.DecodeKey(data.Key)
+	if err != nil {
+		if errors.Is(err, apikeys.ErrMalformedKey) || errors.Is(err, apikeys.ErrUnknownVersion) || errors.Is(err, apikeys.ErrInvalidSignature) {
+			return nil, &errs.Error{
+				Type: errs.Unauthorized,
+				Message: "The key to renew is invalid : " + err.Error(),
+				ToRespondWith: true,
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to decode api key : " + err.Error(),
+		}
+	}
+
+	if data.TTL > config.MaxAPIKeyTTL {
+		return nil, &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: fmt.Sprintf("Key ttl exceeds the maximum allowed. Max ttl: %d seconds.", config.MaxAPIKeyTTL),
+			ToRespondWith: true,
+		}
+	}
+
+	// 2) check that the key belongs to the user, a key already replaced by a renewal cannot be renewed again
+	owner, err := s.queries.GetKeyOwner(ctx, apikeys.HashKey(data.Key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &errs.Error{
+				Type: errs.NotFound,
+				Message: "No such key found. It may have already been renewed.",
+				ToRespondWith: true,
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to get key owner : " + err.Error(),
+		}
+	}
+
+	if owner.UserID != userID {
+		return nil, &errs.Error{
+			Type: errs.NotFound,
+			Message: "No such key found. It may have already been renewed.",
+			ToRespondWith: true,
+		}
+	}
+
+	if owner.Revoked {
+		return nil, &errs.Error{
+			Type: errs.InvalidState,
+			Message: "The key has been revoked and cannot be renewed.",
+			ToRespondWith: true,
+		}
+	}
+
+	// a renewal replaces the previous key, it would stop working before the end of its grace window
+	now := time.Now().Unix()
+	if owner.PrevKeyExpiresAt.Valid && owner.PrevKeyExpiresAt.Int64 > now {
+		return nil, &errs.Error{
+			Type: errs.InvalidState,
+			Message: fmt.Sprintf("The key was renewed recently and its previous key is still accepted. Renew it again in %d seconds.", owner.PrevKeyExpiresAt.Int64 - now),
+			ToRespondWith: true,
+		}
+	}
+
+	// 3) the new key lives as long as the old one was issued for, unless another ttl is asked for
+	ttl := data.TTL
+	if ttl <= 0 {
+		ttl = min(owner.ExpiresAt - oldKey.Metadata.CreatedAt, config.MaxAPIKeyTTL)
+	}
+	if ttl <= 0 {
+		ttl = config.DefaultAPIKeyTTL
+	}
+
+	newCreds, err := apikeys.RecreateFromOld(data.Key)
+	if err != nil {
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to recreate api key : " + err.Error(),
+		}
+	}
+
+	// 4) swap in the new key and keep the old one around for the grace window
+	renewed, err := s.queries.RenewKey(ctx, sqlc.RenewKeyParams{
+		KeyID: owner.KeyID,
+		KeyHash: apikeys.HashKey(newCreds.Key),
+		KeyPrefix: apikeys.KeyPrefix(newCreds.Key),
+		PrevKeyExpiresAt: pgtype.Int8{
+			Int64: now + config.APIKeyRenewalGracePeriod,
+			Valid: true,
+		},
+		ExpiresAt: newCreds.CreatedAt + ttl,
+		OldKeyHash: apikeys.HashKey(data.Key),
+	})
+	if err != nil {
+		// renewed by a concurrent request since it was checked
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &errs.Error{
+				Type: errs.NotFound,
+				Message: "No such key found. It may have already been renewed.",
+				ToRespondWith: true,
+			}
+		}
+		return nil, &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to renew api key : " + err.Error(),
+		}
 	}
 
 	return &dto.APIKeyResponse{
-		Cache: updatedKeyData.Cache,
-		Storage: updatedKeyData.Storage,
+		ID: renewed.ID,
+		Key: newCreds.Key,
+		Prefix: apikeys.KeyPrefix(newCreds.Key),
+		Label: renewed.Label,
+		CreatedAt: renewed.CreatedAt.Time.Unix(),
+		ExpiresAt: renewed.ExpiresAt,
+		Scopes: renewed.Scopes,
 	}, nil
 }
 
-// DeleteService deletes a service and also deletes the key associated with it.
+// DeleteService deletes a service, the deletion cascades to all the keys of the service.
 func (s *PublicService) DeleteService(ctx *gin.Context, userID int64, data *dto.NewProject) (*errs.Error) {
 
 	// 1) check if service exists
@@ -309,13 +719,6 @@ func (s *PublicService) DeleteService(ctx *gin.Context, userID int64, data *dto.
 	}
 
 	// 3) Delete the service
-
-	err = s.queries.DeleteKey(ctx, serviceData.KeyID)
-	if err != nil {
-		return nil
-	}
-
-	// this isnt really neccessary as the key deletion cascades to service deletion too
 	err = s.queries.DeleteService(ctx, serviceData.Sid)
 	if err != nil {
 		return nil
@@ -333,20 +736,32 @@ func (s *PublicService) AllProjects(ctx *gin.Context, userID int64) ([]*dto.NewP
 
 	resp := make([]*dto.NewProjectResp, 0)
 
+	// one row per active key, rows of a project are adjacent as they are ordered by the project first
+	var last *dto.NewProjectResp
 	for _, proj := range projsData {
-		resp = append(resp, &dto.NewProjectResp{
-			ServiceUUID: proj.ServiceUuid.String(),
-			ServiceName: proj.Name,
-			ServiceCreatedAt: proj.CreatedAt.Time.Unix(),
-
-			KeyInfo: &dto.APIKeyResponse{
-				ID: proj.ID.String,
-				Key: proj.Key.String,
-				CreatedAt: proj.CreatedAt.Time.Unix(),
-				ExpiresAt: proj.ExpiresAt.Int64,
-				Cache: proj.Cache.Bool,
-				Storage: proj.Storage.Bool,
-			},
+		uuid := proj.ServiceUuid.String()
+		if last == nil || last.ServiceUUID != uuid {
+			last = &dto.NewProjectResp{
+				ServiceUUID: uuid,
+				ServiceName: proj.Name,
+				ServiceCreatedAt: proj.CreatedAt.Time.Unix(),
+				Keys: make([]*dto.APIKeyResponse, 0),
+			}
+			resp = append(resp, last)
+		}
+
+		// project without any active key
+		if !proj.ID.Valid {
+			continue
+		}
+
+		last.Keys = append(last.Keys, &dto.APIKeyResponse{
+			ID: proj.ID.String,
+			Prefix: proj.KeyPrefix.String,
+			Label: proj.Label.String,
+			CreatedAt: proj.CreatedAt_2.Time.Unix(),
+			ExpiresAt: proj.ExpiresAt.Int64,
+			Scopes: proj.Scopes,
 		})
 	}
 
@@ -511,7 +926,7 @@ func (s *PublicService) CacheData(ctx *gin.Context, userID int64, stream string,
 			Get: true,
 			Put: false,
 		})
-	case "upload":
+	case "put":
 		data, err = s.queries.GetAllCacheData(ctx, sqlc.GetAllCacheDataParams{
 			ServiceID: serviceData.Sid,
 			Get: false,
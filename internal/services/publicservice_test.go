@@ -0,0 +1,168 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/clerk/clerk-sdk-go/v2/user"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pashagolub/pgxmock/v4"
+	"main.go/internal/config"
+	"main.go/internal/const/errs"
+	"main.go/internal/const/roles"
+	"main.go/internal/dto"
+	"main.go/internal/utils/apikeys"
+	"main.go/internal/utils/mailer"
+)
+
+// expiresIn matches a unix time about ttl seconds from now.
+type expiresIn int64
+
+func (ttl expiresIn) Match(v any) bool {
+	expiresAt, ok := v.(int64)
+	now := time.Now().Unix()
+	return ok && expiresAt >= now + int64(ttl) - 1 && expiresAt <= now + int64(ttl) + 1
+}
+
+// newTestClerk returns a clerk client of a fake clerk API that knows a single user, as json.
+func newTestClerk(t *testing.T, clerkID string, userJSON string) *user.Client {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/users/" + clerkID {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(userJSON))
+	}))
+	t.Cleanup(server.Close)
+
+	key := "sk_test"
+	return user.NewClient(&clerk.ClientConfig{BackendConfig: clerk.BackendConfig{
+		HTTPClient: server.Client(),
+		URL: &server.URL,
+		Key: &key,
+	}})
+}
+
+func TestNewUserTakesClerkEmail(t *testing.T) {
+
+	t.Setenv("JWTSecretKey", "test-secret")
+	t.Setenv("FrontendBaseURL", "https://app.example.com")
+
+	queries, mock := newMockQueries(t)
+	mails := mailer.NewMemoryMailer()
+	clerkClient := newTestClerk(t, "user_1", `{
+		"id": "user_1",
+		"primary_email_address_id": "email_2",
+		"email_addresses": [
+			{"id": "email_1", "email_address": "old@example.com"},
+			{"id": "email_2", "email_address": "b@example.com", "verification": {"status": "unverified"}}
+		]
+	}`)
+	s := NewPublicService(queries, mock, clerkClient, mails)
+
+	mock.ExpectQuery(named("CheckUserExistence")).WithArgs("user_1").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	mock.ExpectQuery(named("UpsertClerkUser")).WithArgs("b@example.com", roles.User, "user_1", false).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(int64(1)))
+
+	_, errf := s.NewUser(newTestContext(), "user_1")
+	if errf != nil {
+		t.Fatalf("signup failed : %+v", errf)
+	}
+	sent := mails.Sent()
+	if len(sent) != 1 || sent[0].To != "b@example.com" {
+		t.Fatalf("sent = %+v, want one mail to the primary clerk address", sent)
+	}
+
+	// a clerk ID that clerk does not know is not registered
+	mock.ExpectQuery(named("CheckUserExistence")).WithArgs("user_2").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+
+	_, errf = s.NewUser(newTestContext(), "user_2")
+	if errf == nil {
+		t.Fatal("signup of an unknown clerk user succeeded")
+	}
+}
+
+func TestNewUserVerifiedByClerk(t *testing.T) {
+
+	queries, mock := newMockQueries(t)
+	mails := mailer.NewMemoryMailer()
+	clerkClient := newTestClerk(t, "user_1", `{
+		"id": "user_1",
+		"primary_email_address_id": "email_1",
+		"email_addresses": [{"id": "email_1", "email_address": "b@example.com", "verification": {"status": "verified"}}]
+	}`)
+	s := NewPublicService(queries, mock, clerkClient, mails)
+
+	mock.ExpectQuery(named("CheckUserExistence")).WithArgs("user_1").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	mock.ExpectQuery(named("UpsertClerkUser")).WithArgs("b@example.com", roles.User, "user_1", true).
+		WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(int64(1)))
+
+	_, errf := s.NewUser(newTestContext(), "user_1")
+	if errf != nil {
+		t.Fatalf("signup failed : %+v", errf)
+	}
+	if sent := mails.Sent(); len(sent) != 0 {
+		t.Fatalf("sent = %+v, want no mail for an email clerk verified", sent)
+	}
+}
+
+func TestRenewKeyLifetime(t *testing.T) {
+
+	t.Setenv("APIKeyGenerationVersion", "v1")
+	t.Setenv("APIKeySecretPassword", "test-password")
+
+	tests := []struct {
+		name string
+		lifetime int64 // the old key was issued for
+		ttl int64 // asked for the renewal
+		want int64 // lifetime of the new key, 0 if the renewal is refused
+	}{
+		{name: "keeps a short lifetime", lifetime: 3600, want: 3600},
+		{name: "keeps a long lifetime", lifetime: 90 * 86400, want: 90 * 86400},
+		{name: "clamps to the max", lifetime: config.MaxAPIKeyTTL + 86400, want: config.MaxAPIKeyTTL},
+		{name: "asked ttl", lifetime: 3600, ttl: 600, want: 600},
+		{name: "asked ttl over the max", lifetime: 3600, ttl: config.MaxAPIKeyTTL + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			queries, mock := newMockQueries(t)
+			s := NewPublicService(queries, mock, nil, mailer.NewMemoryMailer())
+
+			old, err := apikeys.CreateWithOutSeed()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want > 0 {
+				mock.ExpectQuery(named("GetKeyOwner")).WithArgs(apikeys.HashKey(old.Key)).
+					WillReturnRows(pgxmock.NewRows([]string{"key_id", "revoked", "prev_key_expires_at", "expires_at", "user_id"}).
+						AddRow(int64(3), false, pgtype.Int8{}, old.CreatedAt + tt.lifetime, int64(1)))
+				mock.ExpectQuery(named("RenewKey")).
+					WithArgs(int64(3), pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(), expiresIn(tt.want), apikeys.HashKey(old.Key)).
+					WillReturnRows(pgxmock.NewRows([]string{"key_id", "id", "label", "created_at", "expires_at", "scopes"}).
+						AddRow(int64(3), old.ID, "default", pgtype.Timestamptz{}, int64(0), []string{}))
+			}
+
+			_, errf := s.RenewKey(newTestContext(), 1, &dto.RenewKey{Key: old.Key, TTL: tt.ttl})
+			if tt.want == 0 {
+				if errf == nil || errf.Type != errs.PreconditionFailed {
+					t.Fatalf("renewal : %+v, want a precondition error", errf)
+				}
+				return
+			}
+			if errf != nil {
+				t.Fatalf("renewal failed : %+v", errf)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pashagolub/pgxmock/v4"
+	sqlc "main.go/internal/sqlc/generate"
+)
+
+// newMockQueries returns queries run against a mock of postgres, expectations are set on the mock by query name.
+func newMockQueries(t *testing.T) (*sqlc.Queries, pgxmock.PgxPoolIface) {
+
+	mock, err := pgxmock.NewPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		err := mock.ExpectationsWereMet()
+		if err != nil {
+			t.Error(err)
+		}
+		mock.Close()
+	})
+
+	return sqlc.New(mock), mock
+}
+
+// named matches the sqlc query of the name, each one starts with a '-- name: <name> :<kind>' comment.
+func named(name string) string {
+	return "-- name: " + name + " :"
+}
+
+func newTestContext() *gin.Context {
+
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest("GET", "/", nil)
+	return ctx
+}
@@ -1,152 +1,129 @@
 package services
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"strings"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"main.go/internal/config"
 	"main.go/internal/const/errs"
+	"main.go/internal/const/scopes"
+	"main.go/internal/dto"
 	sqlc "main.go/internal/sqlc/generate"
+	apikeys "main.go/internal/utils/apikeys"
+	"main.go/internal/utils/storage"
 )
 
-type StorageSourceURL struct {
-	UploadURL string
-	DownloadURL string
-}
-
 type StorageService struct {
 	queries *sqlc.Queries
-	httpClient *http.Client
-
-	urls *StorageSourceURL
+	backend storage.StorageBackend
 }
 
-func NewStorageService(queries *sqlc.Queries, client *http.Client, sourceURLs *StorageSourceURL) *StorageService {
+func NewStorageService(queries *sqlc.Queries, backend storage.StorageBackend) *StorageService {
 	return &StorageService{
 		queries: queries,
-		httpClient: client,
-		urls: sourceURLs,
+		backend: backend,
 	}
 }
 
 // >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
 
 
-func (s *StorageService) validateAPIKey(ctx *gin.Context, apiKey string) (*sqlc.GetUserDataFromAPIKeyRow,*errs.Error) {
+// validateAPIKey checks the key and that it was granted the scope of the operation being performed.
+func (s *StorageService) validateAPIKey(ctx *gin.Context, apiKey string, scope string) (*sqlc.GetUserDataFromAPIKeyRow,*errs.Error) {
 
-	// TODO: need better api key validation like ttl and all
-
-	userData, err := s.queries.GetUserDataFromAPIKey(ctx, apiKey)
-	if err != nil {
-		return nil, &errs.Error{
-			Type: errs.NotFound,
-			Message: "API key not found.",
-			ToRespondWith: true,
-		}
+	userData, errf := lookupAPIKey(ctx, s.queries, apiKey)
+	if errf != nil {
+		return nil, errf
 	}
 
-	if !userData.Storage {
+	if !scopes.Has(userData.Scopes, scope) {
 		return nil, &errs.Error{
 			Type: errs.Unauthorized,
-			Message: "API key is found but user is not authorized to use the Storage service.",
+			Message: "API key is found but is not authorized for the '" + scope + "' scope of the Storage service.",
 			ToRespondWith: true,
 		}
 	}
-	
-	return &userData, nil
+
+	return userData, nil
 }
 
-func (s *StorageService) hitSourceURL(ctx *gin.Context, method string, url string, body *bytes.Buffer, reqHeader string) (*http.Response, *errs.Error) {
+// backendError maps the errors of the storage backend to the ones returned by the service.
+func (s *StorageService) backendError(err error) (*errs.Error) {
 
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
-	if err != nil {
-		return nil, &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to create a new request for storage : " + err.Error(),
-		}
-	}
-
-	req.Header.Set("Content-Type", reqHeader)
-	
-	if secretKey, exists := os.LookupEnv("ServiceSecretKey"); exists {
-		req.Header.Set("authorization", secretKey)
-	} else {
-		return nil, &errs.Error{
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return &errs.Error{
 			Type: errs.NotFound,
-			Message: "Service secret key not found in env.",
+			Message: "File not found.",
+			ToRespondWith: true,
 		}
-	}
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		fmt.Println(err)
-		return nil, &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to get response from storage source : " + err.Error(),
+	case errors.Is(err, storage.ErrInvalidKey):
+		return &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: fmt.Sprintf("File key must be 1 to %d bytes of UTF-8 without control characters.", storage.MaxKeyLength),
+			ToRespondWith: true,
 		}
-	}
-
-	if resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode < 600 {
-		return nil, &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to get response from storage source : Source responded with code other than 201-OK : " + resp.Status,
+	case errors.Is(err, storage.ErrSizeMismatch):
+		return &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: "Uploaded file is not as large as declared.",
+			ToRespondWith: true,
 		}
-	}
-
-	return resp, nil
-}
-
-
-func (s *StorageService) hitSourceURL2(ctx *gin.Context, method string, url string, body io.Reader) (*http.Response, *errs.Error) {
-
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
-	if err != nil {
-		return nil, &errs.Error{
+	case errors.Is(err, storage.ErrNotSupported):
+		return &errs.Error{
+			Type: errs.PreconditionFailed,
+			Message: "Operation is not supported by the configured storage backend.",
+			ToRespondWith: true,
+		}
+	default:
+		return &errs.Error{
 			Type: errs.Internal,
-			Message: "Failed to create a new request for storage : " + err.Error(),
+			Message: "Storage backend failed : " + err.Error(),
 		}
 	}
+}
 
-	req.Header.Set("authorization", "vaultbase1234")
+// objectInfo converts the info of an object for the response.
+func (s *StorageService) objectInfo(info *storage.ObjectInfo) *dto.StorageObject {
 
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		fmt.Println(err)
-		return nil, &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to get response from storage source : " + err.Error(),
-		}
+	object := &dto.StorageObject{
+		Key: info.Key,
+		Size: info.Size,
+		ContentType: info.ContentType,
+		ETag: info.ETag,
 	}
-
-	if resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode < 600 {
-		return nil, &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to get response from storage source : Source responded with code other than 201-OK : " + resp.Status,
-		}
+	if !info.ModifiedAt.IsZero() {
+		object.ModifiedAt = info.ModifiedAt.Unix()
 	}
-
-	return resp, nil
+	return object
 }
 
 // >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
 
 
-func (s *StorageService) UploadNewFile(ctx *gin.Context, apiKey string, file *multipart.FileHeader) *errs.Error {
+// UploadNewFile stores the file under fileKey, or under its file name if not set, replacing the previous file of the key.
+func (s *StorageService) UploadNewFile(ctx *gin.Context, apiKey string, file *multipart.FileHeader, fileKey string) (*dto.StorageObject, *errs.Error) {
 
-	userData, errf := s.validateAPIKey(ctx, apiKey)
+	if fileKey == "" {
+		fileKey = file.Filename
+	}
+	if !storage.ValidKey(fileKey) {
+		return nil, s.backendError(storage.ErrInvalidKey)
+	}
+
+	userData, errf := s.validateAPIKey(ctx, apiKey, scopes.StorageUpload)
 	if errf != nil {
-		return errf
+		return nil, errf
 	}	
 
 	sizeLim := config.StorageUploadFileSizeLimit
 	if file.Size > sizeLim {
-		return &errs.Error{
+		return nil, &errs.Error{
 			Type: errs.PreconditionFailed,
 			Message: fmt.Sprintf("File size exceeds upload limit. Current upload limit: %d bytes.", sizeLim),
 			ToRespondWith: true,
@@ -155,76 +132,65 @@ func (s *StorageService) UploadNewFile(ctx *gin.Context, apiKey string, file *mu
 
 	srcFile, err := file.Open()
 	if err != nil {
-		return &errs.Error{
-            Type: errs.Internal,
-            Message: "Failed to open the uploaded file : " + err.Error(),
-			ToRespondWith: true,
-        }
-    }
-	defer srcFile.Close()
-
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	err = writer.WriteField("uid", userData.UserUiid.String())
-	if err != nil {
-		return &errs.Error{
+		return nil, &errs.Error{
 			Type: errs.Internal,
-			Message: "Failed to write the 'uid' field : " + err.Error(),
+			Message: "Failed to open the uploaded file : " + err.Error(),
+			ToRespondWith: true,
 		}
 	}
-	
-	part, err := writer.CreateFormFile("file", file.Filename)
-	if err != nil {
-		return &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to create the form file : " + err.Error(),
-		}
+	defer srcFile.Close()
+
+	contentType := file.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
 	}
 
-	srcFile.Seek(0, io.SeekStart) // Reset file position before copying
-	_, err = io.Copy(part, srcFile)
+	info, err := s.backend.Put(ctx, userData.UserUiid.String(), fileKey, srcFile, file.Size, contentType)
 	if err != nil {
-		return &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to copy source file to form : " + err.Error(),
-		}
+		errf = s.backendError(err)
+		errf.Message = "Failed to upload file : " + errf.Message
+		return nil, errf
 	}
 
-	err = writer.Close()
+	// update the storage analytics data
+	err = s.updateData(ctx, apiKey, true, false)
 	if err != nil {
-		return &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to close writer.",
-		}
-	}
+		fmt.Println(errs.Error{
+			Type: errs.IncompleteAction,
+			Message: "Failed to update the storage data to analytics table : " + err.Error(),
+		})
+	}	
 
-	url := fmt.Sprintf("%s%s", config.SourceBaseDomain, config.StorageUploadURL)
+	return s.objectInfo(info), nil
+}
 
-	resp, errf := s.hitSourceURL(ctx, "POST", url, body, writer.FormDataContentType())
+// DownloadFile streams the file to the response, along with its content type, size, ETag and modification time when known.
+func (s *StorageService) DownloadFile(ctx *gin.Context, apiKey string, fileKey string) *errs.Error {
+
+	userData, errf := s.validateAPIKey(ctx, apiKey, scopes.StorageDownload)
 	if errf != nil {
-		errf.Message = "Failed to upload file : " + errf.Message
 		return errf
 	}
-	defer resp.Body.Close()
 
-	ctx.Status(resp.StatusCode)
-
-	for key, values := range resp.Header {
-		for _, value := range values {
-			ctx.Writer.Header().Add(key, value)
-		}
+	content, info, err := s.backend.Get(ctx, userData.UserUiid.String(), fileKey)
+	if err != nil {
+		return s.backendError(err)
 	}
-	_, err = io.Copy(ctx.Writer, resp.Body)
+	defer content.Close()
+
+	s.setObjectHeaders(ctx, info)
+	ctx.Status(http.StatusOK)
+
+	_, err = io.Copy(ctx.Writer, content)
 	if err != nil {
 		return &errs.Error{
 			Type: errs.Internal,
-			Message: "Failed to copy response into ctx writer : " + err.Error(),
+			Message: "Failed to stream file content to client : " + err.Error(),
 		}
 	}
 
 	// update the storage analytics data
-	err = s.updateData(ctx, apiKey, true, false)
+	err = s.updateData(ctx, apiKey, false, true)
 	if err != nil {
 		fmt.Println(errs.Error{
 			Type: errs.IncompleteAction,
@@ -235,55 +201,98 @@ func (s *StorageService) UploadNewFile(ctx *gin.Context, apiKey string, file *mu
 	return nil
 }
 
-func (s *StorageService) DownloadFile(ctx *gin.Context, apiKey string, fileKey string) *errs.Error {
+// StatFile sets the headers of a download of the file, without its content.
+func (s *StorageService) StatFile(ctx *gin.Context, apiKey string, fileKey string) *errs.Error {
 
-	userData, errf := s.validateAPIKey(ctx, apiKey)
+	userData, errf := s.validateAPIKey(ctx, apiKey, scopes.StorageDownload)
 	if errf != nil {
 		return errf
 	}
 
-	url := fmt.Sprintf("%s%s/?uid=%s&key=%s", config.SourceBaseDomain, config.StorageDownloadURL, userData.UserUiid.String(), fileKey)
-	resp, errf := s.hitSourceURL2(ctx, "GET", url, nil)
+	info, err := s.backend.Stat(ctx, userData.UserUiid.String(), fileKey)
+	if err != nil {
+		return s.backendError(err)
+	}
+
+	s.setObjectHeaders(ctx, info)
+	ctx.Status(http.StatusOK)
+	return nil
+}
+
+// setObjectHeaders describes the object in the response headers.
+func (s *StorageService) setObjectHeaders(ctx *gin.Context, info *storage.ObjectInfo) {
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	ctx.Header("Content-Type", contentType)
+
+	if info.Size >= 0 {
+		ctx.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	if info.ETag != "" {
+		ctx.Header("ETag", `"` + info.ETag + `"`)
+	}
+	if !info.ModifiedAt.IsZero() {
+		ctx.Header("Last-Modified", info.ModifiedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func (s *StorageService) DeleteFile(ctx *gin.Context, apiKey string, fileKey string) *errs.Error {
+
+	userData, errf := s.validateAPIKey(ctx, apiKey, scopes.StorageUpload)
 	if errf != nil {
 		return errf
 	}
-	defer resp.Body.Close()
 
-	ctx.Status(resp.StatusCode)
+	err := s.backend.Delete(ctx, userData.UserUiid.String(), fileKey)
+	if err != nil {
+		return s.backendError(err)
+	}
 
-	for key, values := range resp.Header {
-		for _, value := range values {
+	return nil
+}
 
-			if strings.ToLower(key) == "content-length" || strings.ToLower(key) == "transfer-encoding" {
-				continue
-			}
-			ctx.Writer.Header().Add(key, value)
+// ListFiles returns a page of the files starting with the prefix, in key order, from the cursor of the previous page.
+// A limit of 0 gets config.StorageListDefaultLimit files.
+func (s *StorageService) ListFiles(ctx *gin.Context, apiKey string, prefix string, cursor string, limit int) (*dto.StorageObjectPage, *errs.Error) {
+
+	if limit == 0 {
+		limit = config.StorageListDefaultLimit
+	}
+	if limit < 0 || limit > config.StorageListMaxLimit {
+		return nil, &errs.Error{
+			Type: errs.InvalidFormat,
+			Message: fmt.Sprintf("Limit must be between 1 and %d files.", config.StorageListMaxLimit),
+			ToRespondWith: true,
 		}
 	}
 
-	_, err := io.Copy(ctx.Writer, resp.Body)
-	if err != nil {
-		return &errs.Error{
-			Type: errs.Internal,
-			Message: "Failed to stream file content to client : " + err.Error(),
-		}
+	userData, errf := s.validateAPIKey(ctx, apiKey, scopes.StorageList)
+	if errf != nil {
+		return nil, errf
 	}
 
-	// update the storage analytics data
-	err = s.updateData(ctx, apiKey, false, true)
+	objects, next, err := s.backend.List(ctx, userData.UserUiid.String(), prefix, cursor, limit)
 	if err != nil {
-		fmt.Println(errs.Error{
-			Type: errs.IncompleteAction,
-			Message: "Failed to update the storage data to analytics table : " + err.Error(),
-		})
-	}	
+		return nil, s.backendError(err)
+	}
 
-	return nil
+	page := &dto.StorageObjectPage{
+		Files: make([]*dto.StorageObject, len(objects)),
+		Cursor: next,
+	}
+	for i := range objects {
+		page.Files[i] = s.objectInfo(&objects[i])
+	}
+
+	return page, nil
 }
 
 func (s *StorageService) updateData(ctx *gin.Context, apiKey string, up, down bool) error {
 	
-	serviceID, err := s.queries.GetServiceIDFromAPIKey(ctx, apiKey)
+	serviceID, err := s.queries.GetServiceIDFromAPIKey(ctx, apikeys.HashKey(apiKey))
 	if err != nil {
 		return err // err
 	}
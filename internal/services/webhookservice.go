@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/clerk/clerk-sdk-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"main.go/internal/const/errs"
+	"main.go/internal/const/roles"
+	"main.go/internal/dto"
+	sqlc "main.go/internal/sqlc/generate"
+)
+
+// clerk user events that are synced into the users table
+const (
+	ClerkUserCreated = "user.created"
+	ClerkUserUpdated = "user.updated"
+	ClerkUserDeleted = "user.deleted"
+
+	// status of the verification of an email address that clerk confirmed
+	clerkVerified = "verified"
+)
+
+type WebhookService struct {
+	queries *sqlc.Queries
+	DB TxBeginner
+}
+
+func NewWebhookService(queries *sqlc.Queries, db TxBeginner) *WebhookService {
+	return &WebhookService{
+		queries: queries,
+		DB: db,
+	}
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+
+// primaryEmail returns the primary email of the clerk user, or the first one if none is marked primary.
+// Clerk verifies the addresses itself, a verified one counts as confirmed so its user is never sent a confirmation link.
+func primaryEmail(user *dto.ClerkUser) (string, bool, *errs.Error) {
+
+	for _, email := range user.EmailAddresses {
+		if email.ID == user.PrimaryEmailAddressID {
+			return email.EmailAddress, verified(&email), nil
+		}
+	}
+	if len(user.EmailAddresses) > 0 {
+		return user.EmailAddresses[0].EmailAddress, verified(&user.EmailAddresses[0]), nil
+	}
+
+	return "", false, &errs.Error{
+		Type: errs.MissingRequiredField,
+		Message: "Clerk user has no email address.",
+		ToRespondWith: true,
+	}
+}
+
+func verified(email *dto.ClerkEmailAddress) bool {
+	return email.Verification != nil && email.Verification.Status == clerkVerified
+}
+
+// clerkUserData returns the fields of a user of the clerk API that its webhook events carry.
+func clerkUserData(user *clerk.User) *dto.ClerkUser {
+
+	data := &dto.ClerkUser{ID: user.ID}
+	if user.PrimaryEmailAddressID != nil {
+		data.PrimaryEmailAddressID = *user.PrimaryEmailAddressID
+	}
+	for _, email := range user.EmailAddresses {
+		address := dto.ClerkEmailAddress{ID: email.ID, EmailAddress: email.EmailAddress}
+		if email.Verification != nil {
+			address.Verification = &dto.ClerkVerification{Status: email.Verification.Status}
+		}
+		data.EmailAddresses = append(data.EmailAddresses, address)
+	}
+	return data
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+
+// HandleClerkEvent syncs a verified clerk event into the users table.
+// Every event can be delivered more than once so all of them are idempotent, unknown events are ignored.
+func (s *WebhookService) HandleClerkEvent(ctx *gin.Context, event *dto.ClerkWebhookEvent) (*errs.Error) {
+
+	if event.Data.ID == "" {
+		return &errs.Error{
+			Type: errs.MissingRequiredField,
+			Message: "Clerk event is missing the user id.",
+			ToRespondWith: true,
+		}
+	}
+
+	switch event.Type {
+	case ClerkUserCreated:
+		return s.userCreated(ctx, &event.Data)
+	case ClerkUserUpdated:
+		return s.userUpdated(ctx, &event.Data)
+	case ClerkUserDeleted:
+		return s.userDeleted(ctx, &event.Data)
+	default:
+		return nil
+	}
+}
+
+// userCreated inserts the user, or refreshes its email if the frontend already registered it through /public/newuser.
+// The user is confirmed if clerk verified the email, an address confirmed through a link stays confirmed.
+func (s *WebhookService) userCreated(ctx *gin.Context, user *dto.ClerkUser) (*errs.Error) {
+
+	email, confirmed, errf := primaryEmail(user)
+	if errf != nil {
+		return errf
+	}
+
+	_, err := s.queries.UpsertClerkUser(ctx, sqlc.UpsertClerkUserParams{
+		Email: email,
+		Role: roles.User,
+		ClerkID: user.ID,
+		Confirmed: confirmed,
+	})
+	if err != nil {
+		var pgerr *pgconn.PgError
+		if errors.As(err, &pgerr) && pgerr.Code == errs.UniqueViolation {
+			return &errs.Error{
+				Type: errs.ObjectExists,
+				Message: "Another user with the same Email-Id already exists.",
+				ToRespondWith: true,
+			}
+		}
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to upsert clerk user : " + err.Error(),
+		}
+	}
+
+	return nil
+}
+
+// userUpdated syncs the primary email and whether clerk verified it, users that were never registered are created instead.
+func (s *WebhookService) userUpdated(ctx *gin.Context, user *dto.ClerkUser) (*errs.Error) {
+
+	email, confirmed, errf := primaryEmail(user)
+	if errf != nil {
+		return errf
+	}
+
+	affected, err := s.queries.UpdateClerkUserEmail(ctx, sqlc.UpdateClerkUserEmailParams{
+		ClerkID: user.ID,
+		Email: email,
+		Confirmed: confirmed,
+	})
+	if err != nil {
+		var pgerr *pgconn.PgError
+		if errors.As(err, &pgerr) && pgerr.Code == errs.UniqueViolation {
+			return &errs.Error{
+				Type: errs.ObjectExists,
+				Message: "Another user with the same Email-Id already exists.",
+				ToRespondWith: true,
+			}
+		}
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to update clerk user email : " + err.Error(),
+		}
+	}
+
+	if affected == 0 {
+		return s.userCreated(ctx, user)
+	}
+
+	return nil
+}
+
+// userDeleted soft deletes the user and revokes all of their api keys and refresh tokens in one transaction.
+func (s *WebhookService) userDeleted(ctx *gin.Context, user *dto.ClerkUser) (*errs.Error) {
+
+	tx, err := s.DB.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to acquire a transaction : " + err.Error(),
+		}
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			fmt.Println(err)
+		}
+	}()
+	txQueries := s.queries.WithTx(tx)
+
+	userID, err := txQueries.SoftDeleteClerkUser(ctx, user.ID)
+	if err != nil {
+		// never registered, nothing to delete
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to soft delete clerk user : " + err.Error(),
+		}
+	}
+
+	err = txQueries.RevokeUserKeys(ctx, userID)
+	if err != nil {
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to revoke keys of deleted user : " + err.Error(),
+		}
+	}
+
+	err = txQueries.RevokeUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to revoke refresh tokens of deleted user : " + err.Error(),
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return &errs.Error{
+			Type: errs.Internal,
+			Message: "Failed to commit transaction : " + err.Error(),
+		}
+	}
+
+	return nil
+}
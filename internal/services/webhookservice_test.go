@@ -0,0 +1,66 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v4"
+	"main.go/internal/const/roles"
+	"main.go/internal/dto"
+)
+
+func TestClerkUserConfirmedFromVerification(t *testing.T) {
+
+	tests := []struct {
+		name string
+		data string
+		email string
+		confirmed bool
+	}{
+		{
+			name: "verified primary email",
+			data: `{"id":"user_1","primary_email_address_id":"e2","email_addresses":[
+				{"id":"e1","email_address":"old@example.com","verification":{"status":"unverified"}},
+				{"id":"e2","email_address":"a@example.com","verification":{"status":"verified"}}]}`,
+			email: "a@example.com",
+			confirmed: true,
+		},
+		{
+			name: "unverified primary email",
+			data: `{"id":"user_1","primary_email_address_id":"e1","email_addresses":[
+				{"id":"e1","email_address":"a@example.com","verification":{"status":"unverified"}},
+				{"id":"e2","email_address":"b@example.com","verification":{"status":"verified"}}]}`,
+			email: "a@example.com",
+			confirmed: false,
+		},
+		{
+			name: "verification not started",
+			data: `{"id":"user_1","primary_email_address_id":"e1","email_addresses":[
+				{"id":"e1","email_address":"a@example.com","verification":null}]}`,
+			email: "a@example.com",
+			confirmed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			queries, mock := newMockQueries(t)
+			s := NewWebhookService(queries, mock)
+
+			event := &dto.ClerkWebhookEvent{Type: ClerkUserCreated}
+			err := json.Unmarshal([]byte(tt.data), &event.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mock.ExpectQuery(named("UpsertClerkUser")).WithArgs(tt.email, roles.User, "user_1", tt.confirmed).
+				WillReturnRows(pgxmock.NewRows([]string{"user_id"}).AddRow(int64(1)))
+
+			errf := s.HandleClerkEvent(newTestContext(), event)
+			if errf != nil {
+				t.Fatalf("user.created : %+v", errf)
+			}
+		})
+	}
+}
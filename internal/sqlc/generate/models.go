@@ -14,17 +14,46 @@ type Cache struct {
 	Get       bool
 	Put       bool
 	CreatedAt pgtype.Timestamptz
+	Hit       bool
+	LatencyUs int64
+}
+
+type CacheDatabase struct {
+	CdbID          int64
+	ServiceID      int64
+	Name           string
+	Namespace      pgtype.UUID
+	DefaultTtl     int64
+	MaxKeys        int64
+	EvictionPolicy string
+	CreatedAt      pgtype.Timestamptz
 }
 
 type Key struct {
-	KeyID     int64
-	Key       string
-	CreatedAt pgtype.Timestamptz
-	UpdatedAt pgtype.Timestamptz
-	Cache     bool
-	Storage   bool
+	KeyID            int64
+	ServiceID        int64
+	KeyHash          string
+	KeyPrefix        string
+	Label            string
+	CreatedAt        pgtype.Timestamptz
+	UpdatedAt        pgtype.Timestamptz
+	Scopes           []string
+	Revoked          bool
+	ExpiresAt        int64
+	ID               string
+	PrevKeyHash      pgtype.Text
+	PrevKeyExpiresAt pgtype.Int8
+}
+
+type RefreshToken struct {
+	RtID      int64
+	UserID    int64
+	Jti       string
+	FamilyID  string
+	Used      bool
+	Revoked   bool
 	ExpiresAt int64
-	ID        string
+	CreatedAt pgtype.Timestamptz
 }
 
 type Service struct {
@@ -32,7 +61,6 @@ type Service struct {
 	UserID      int64
 	ServiceUuid pgtype.UUID
 	CreatedAt   pgtype.Timestamptz
-	KeyID       int64
 	Name        string
 }
 
@@ -54,4 +82,5 @@ type User struct {
 	Confirmed bool
 	Deleted   bool
 	ClerkID   string
+	Suspended bool
 }
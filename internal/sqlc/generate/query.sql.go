@@ -25,14 +25,71 @@ func (q *Queries) CheckUserExistence(ctx context.Context, clerkID string) (int64
 	return count, err
 }
 
-const deleteKey = `-- name: DeleteKey :exec
-DELETE FROM keys
-WHERE keys.key_id = $1
+const confirmUser = `-- name: ConfirmUser :execrows
+
+
+UPDATE users
+SET confirmed = true
+WHERE users.user_id = $1
+AND users.email = $2
 `
 
-func (q *Queries) DeleteKey(ctx context.Context, keyID int64) error {
-	_, err := q.db.Exec(ctx, deleteKey, keyID)
-	return err
+type ConfirmUserParams struct {
+	UserID int64
+	Email  string
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// Email Confirmation
+func (q *Queries) ConfirmUser(ctx context.Context, arg ConfirmUserParams) (int64, error) {
+	result, err := q.db.Exec(ctx, confirmUser, arg.UserID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const countCacheDatabases = `-- name: CountCacheDatabases :one
+
+
+SELECT
+    COUNT(cache_databases.cdb_id)
+FROM cache_databases
+WHERE cache_databases.service_id = $1
+`
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// Cache Databases
+func (q *Queries) CountCacheDatabases(ctx context.Context, serviceID int64) (int64, error) {
+	row := q.db.QueryRow(ctx, countCacheDatabases, serviceID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteCacheDatabase = `-- name: DeleteCacheDatabase :one
+DELETE FROM cache_databases
+WHERE cache_databases.service_id = $1
+AND cache_databases.name = $2
+RETURNING namespace, max_keys, eviction_policy
+`
+
+type DeleteCacheDatabaseParams struct {
+	ServiceID int64
+	Name      string
+}
+
+type DeleteCacheDatabaseRow struct {
+	Namespace      pgtype.UUID
+	MaxKeys        int64
+	EvictionPolicy string
+}
+
+func (q *Queries) DeleteCacheDatabase(ctx context.Context, arg DeleteCacheDatabaseParams) (DeleteCacheDatabaseRow, error) {
+	row := q.db.QueryRow(ctx, deleteCacheDatabase, arg.ServiceID, arg.Name)
+	var i DeleteCacheDatabaseRow
+	err := row.Scan(&i.Namespace, &i.MaxKeys, &i.EvictionPolicy)
+	return i, err
 }
 
 const deleteService = `-- name: DeleteService :exec
@@ -94,28 +151,27 @@ SELECT
     services.created_at,
     services.name,
 
-    keys.key,
+    keys.key_prefix,
+    keys.label,
     keys.created_at,
-    keys.updated_at,
-    keys.cache,
-    keys.storage,
+    keys.scopes,
     keys.expires_at,
     keys.id
 FROM services
-LEFT JOIN keys ON services.key_id = keys.key_id
+LEFT JOIN keys ON services.sid = keys.service_id
+AND keys.revoked = false
 WHERE services.user_id = $1
-ORDER BY services.created_at DESC
+ORDER BY services.created_at DESC, keys.created_at DESC
 `
 
 type GetAllProjectsRow struct {
 	ServiceUuid pgtype.UUID
 	CreatedAt   pgtype.Timestamptz
 	Name        string
-	Key         pgtype.Text
+	KeyPrefix   pgtype.Text
+	Label       pgtype.Text
 	CreatedAt_2 pgtype.Timestamptz
-	UpdatedAt   pgtype.Timestamptz
-	Cache       pgtype.Bool
-	Storage     pgtype.Bool
+	Scopes      []string
 	ExpiresAt   pgtype.Int8
 	ID          pgtype.Text
 }
@@ -133,11 +189,10 @@ func (q *Queries) GetAllProjects(ctx context.Context, userID int64) ([]GetAllPro
 			&i.ServiceUuid,
 			&i.CreatedAt,
 			&i.Name,
-			&i.Key,
+			&i.KeyPrefix,
+			&i.Label,
 			&i.CreatedAt_2,
-			&i.UpdatedAt,
-			&i.Cache,
-			&i.Storage,
+			&i.Scopes,
 			&i.ExpiresAt,
 			&i.ID,
 		); err != nil {
@@ -194,6 +249,124 @@ func (q *Queries) GetAllStorageData(ctx context.Context, arg GetAllStorageDataPa
 	return items, nil
 }
 
+const getCacheDatabase = `-- name: GetCacheDatabase :one
+SELECT
+    cache_databases.namespace,
+    cache_databases.default_ttl,
+    cache_databases.max_keys,
+    cache_databases.eviction_policy
+FROM cache_databases
+WHERE cache_databases.service_id = $1
+AND cache_databases.name = $2
+`
+
+type GetCacheDatabaseParams struct {
+	ServiceID int64
+	Name      string
+}
+
+type GetCacheDatabaseRow struct {
+	Namespace      pgtype.UUID
+	DefaultTtl     int64
+	MaxKeys        int64
+	EvictionPolicy string
+}
+
+func (q *Queries) GetCacheDatabase(ctx context.Context, arg GetCacheDatabaseParams) (GetCacheDatabaseRow, error) {
+	row := q.db.QueryRow(ctx, getCacheDatabase, arg.ServiceID, arg.Name)
+	var i GetCacheDatabaseRow
+	err := row.Scan(
+		&i.Namespace,
+		&i.DefaultTtl,
+		&i.MaxKeys,
+		&i.EvictionPolicy,
+	)
+	return i, err
+}
+
+const getKeyOwner = `-- name: GetKeyOwner :one
+SELECT
+    keys.key_id,
+    keys.revoked,
+    keys.prev_key_expires_at,
+    keys.expires_at,
+    services.user_id
+FROM keys
+JOIN services ON keys.service_id = services.sid
+WHERE keys.key_hash = $1
+`
+
+type GetKeyOwnerRow struct {
+	KeyID            int64
+	Revoked          bool
+	PrevKeyExpiresAt pgtype.Int8
+	ExpiresAt        int64
+	UserID           int64
+}
+
+func (q *Queries) GetKeyOwner(ctx context.Context, keyHash string) (GetKeyOwnerRow, error) {
+	row := q.db.QueryRow(ctx, getKeyOwner, keyHash)
+	var i GetKeyOwnerRow
+	err := row.Scan(
+		&i.KeyID,
+		&i.Revoked,
+		&i.PrevKeyExpiresAt,
+		&i.ExpiresAt,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getRefreshTokenFamily = `-- name: GetRefreshTokenFamily :one
+SELECT
+    refresh_tokens.family_id
+FROM refresh_tokens
+WHERE refresh_tokens.jti = $1
+`
+
+func (q *Queries) GetRefreshTokenFamily(ctx context.Context, jti string) (string, error) {
+	row := q.db.QueryRow(ctx, getRefreshTokenFamily, jti)
+	var family_id string
+	err := row.Scan(&family_id)
+	return family_id, err
+}
+
+const getServiceByUUID = `-- name: GetServiceByUUID :one
+SELECT
+    services.sid,
+    services.service_uuid,
+    services.name,
+    services.created_at,
+    users.user_id,
+    users.email
+FROM services
+JOIN users ON users.user_id = services.user_id
+WHERE services.service_uuid = $1
+`
+
+type GetServiceByUUIDRow struct {
+	Sid         int64
+	ServiceUuid pgtype.UUID
+	Name        string
+	CreatedAt   pgtype.Timestamptz
+	UserID      int64
+	Email       string
+}
+
+func (q *Queries) GetServiceByUUID(ctx context.Context, serviceUuid pgtype.UUID) (GetServiceByUUIDRow, error) {
+	row := q.db.QueryRow(ctx, getServiceByUUID, serviceUuid)
+	var i GetServiceByUUIDRow
+	err := row.Scan(
+		&i.Sid,
+		&i.ServiceUuid,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Email,
+	)
+	return i, err
+}
+
 const getServiceCountForUserID = `-- name: GetServiceCountForUserID :one
 SELECT
     COUNT(services.sid)
@@ -217,8 +390,7 @@ func (q *Queries) GetServiceCountForUserID(ctx context.Context, arg GetServiceCo
 const getServiceData = `-- name: GetServiceData :one
 SELECT
     services.sid,
-    services.user_id,
-    services.key_id
+    services.user_id
 FROM services
 WHERE services.name = $1
 `
@@ -226,29 +398,177 @@ WHERE services.name = $1
 type GetServiceDataRow struct {
 	Sid    int64
 	UserID int64
-	KeyID  int64
 }
 
 func (q *Queries) GetServiceData(ctx context.Context, name string) (GetServiceDataRow, error) {
 	row := q.db.QueryRow(ctx, getServiceData, name)
 	var i GetServiceDataRow
-	err := row.Scan(&i.Sid, &i.UserID, &i.KeyID)
+	err := row.Scan(&i.Sid, &i.UserID)
 	return i, err
 }
 
 const getServiceIDFromAPIKey = `-- name: GetServiceIDFromAPIKey :one
 SELECT
-    services.sid
-FROM services
-LEFT JOIN keys ON services.key_id = keys.key_id
-WHERE keys.key = $1
+    keys.service_id
+FROM keys
+WHERE keys.key_hash = $1
+OR keys.prev_key_hash = $1
 `
 
-func (q *Queries) GetServiceIDFromAPIKey(ctx context.Context, key string) (int64, error) {
-	row := q.db.QueryRow(ctx, getServiceIDFromAPIKey, key)
-	var sid int64
-	err := row.Scan(&sid)
-	return sid, err
+func (q *Queries) GetServiceIDFromAPIKey(ctx context.Context, keyHash string) (int64, error) {
+	row := q.db.QueryRow(ctx, getServiceIDFromAPIKey, keyHash)
+	var service_id int64
+	err := row.Scan(&service_id)
+	return service_id, err
+}
+
+const getServiceKeys = `-- name: GetServiceKeys :many
+SELECT
+    keys.id,
+    keys.key_prefix,
+    keys.label,
+    keys.created_at,
+    keys.expires_at,
+    keys.scopes,
+    keys.revoked
+FROM keys
+WHERE keys.service_id = $1
+ORDER BY keys.created_at DESC
+`
+
+type GetServiceKeysRow struct {
+	ID        string
+	KeyPrefix string
+	Label     string
+	CreatedAt pgtype.Timestamptz
+	ExpiresAt int64
+	Scopes    []string
+	Revoked   bool
+}
+
+func (q *Queries) GetServiceKeys(ctx context.Context, serviceID int64) ([]GetServiceKeysRow, error) {
+	rows, err := q.db.Query(ctx, getServiceKeys, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetServiceKeysRow
+	for rows.Next() {
+		var i GetServiceKeysRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.KeyPrefix,
+			&i.Label,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.Scopes,
+			&i.Revoked,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserAccess = `-- name: GetUserAccess :one
+
+
+SELECT
+    users.user_id,
+    users.role,
+    users.deleted,
+    users.suspended
+FROM users
+WHERE users.user_id = $1
+`
+
+type GetUserAccessRow struct {
+	UserID    int64
+	Role      int64
+	Deleted   bool
+	Suspended bool
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// Admin
+func (q *Queries) GetUserAccess(ctx context.Context, userID int64) (GetUserAccessRow, error) {
+	row := q.db.QueryRow(ctx, getUserAccess, userID)
+	var i GetUserAccessRow
+	err := row.Scan(
+		&i.UserID,
+		&i.Role,
+		&i.Deleted,
+		&i.Suspended,
+	)
+	return i, err
+}
+
+const getUserAccessFromClerkID = `-- name: GetUserAccessFromClerkID :one
+SELECT
+    users.user_id,
+    users.role,
+    users.deleted,
+    users.suspended
+FROM users
+WHERE users.clerk_id = $1
+`
+
+type GetUserAccessFromClerkIDRow struct {
+	UserID    int64
+	Role      int64
+	Deleted   bool
+	Suspended bool
+}
+
+func (q *Queries) GetUserAccessFromClerkID(ctx context.Context, clerkID string) (GetUserAccessFromClerkIDRow, error) {
+	row := q.db.QueryRow(ctx, getUserAccessFromClerkID, clerkID)
+	var i GetUserAccessFromClerkIDRow
+	err := row.Scan(
+		&i.UserID,
+		&i.Role,
+		&i.Deleted,
+		&i.Suspended,
+	)
+	return i, err
+}
+
+const getUserAuthByEmail = `-- name: GetUserAuthByEmail :one
+SELECT
+    users.user_id,
+    users.email,
+    users.password,
+    users.role,
+    users.deleted,
+    users.suspended
+FROM users
+WHERE users.email = $1
+`
+
+type GetUserAuthByEmailRow struct {
+	UserID    int64
+	Email     string
+	Password  string
+	Role      int64
+	Deleted   bool
+	Suspended bool
+}
+
+func (q *Queries) GetUserAuthByEmail(ctx context.Context, email string) (GetUserAuthByEmailRow, error) {
+	row := q.db.QueryRow(ctx, getUserAuthByEmail, email)
+	var i GetUserAuthByEmailRow
+	err := row.Scan(
+		&i.UserID,
+		&i.Email,
+		&i.Password,
+		&i.Role,
+		&i.Deleted,
+		&i.Suspended,
+	)
+	return i, err
 }
 
 const getUserData = `-- name: GetUserData :one
@@ -259,7 +579,8 @@ SELECT
     users.user_uiid,
     users.created_at,
     users.confirmed,
-    users.deleted
+    users.deleted,
+    users.suspended
 FROM users
 WHERE users.user_id = $1
 `
@@ -272,6 +593,7 @@ type GetUserDataRow struct {
 	CreatedAt pgtype.Timestamptz
 	Confirmed bool
 	Deleted   bool
+	Suspended bool
 }
 
 func (q *Queries) GetUserData(ctx context.Context, userID int64) (GetUserDataRow, error) {
@@ -285,6 +607,7 @@ func (q *Queries) GetUserData(ctx context.Context, userID int64) (GetUserDataRow
 		&i.CreatedAt,
 		&i.Confirmed,
 		&i.Deleted,
+		&i.Suspended,
 	)
 	return i, err
 }
@@ -294,48 +617,64 @@ const getUserDataFromAPIKey = `-- name: GetUserDataFromAPIKey :one
 
 SELECT
     keys.key_id,
+    keys.service_id,
     keys.created_at,
     keys.updated_at,
-    keys.cache,
-    keys.storage,
+    keys.scopes,
+    keys.revoked,
+    keys.key_hash,
+    keys.expires_at,
+    keys.prev_key_expires_at,
 
     users.user_id,
     users.role,
     users.user_uiid,
-    users.confirmed
+    users.confirmed,
+    users.suspended
 FROM keys
-JOIN services ON keys.key_id = services.key_id
+JOIN services ON keys.service_id = services.sid
 JOIN users ON users.user_id = services.user_id
-WHERE keys.key = $1
+WHERE keys.key_hash = $1
+OR keys.prev_key_hash = $1
 `
 
 type GetUserDataFromAPIKeyRow struct {
-	KeyID     int64
-	CreatedAt pgtype.Timestamptz
-	UpdatedAt pgtype.Timestamptz
-	Cache     bool
-	Storage   bool
-	UserID    int64
-	Role      int64
-	UserUiid  pgtype.UUID
-	Confirmed bool
+	KeyID            int64
+	ServiceID        int64
+	CreatedAt        pgtype.Timestamptz
+	UpdatedAt        pgtype.Timestamptz
+	Scopes           []string
+	Revoked          bool
+	KeyHash          string
+	ExpiresAt        int64
+	PrevKeyExpiresAt pgtype.Int8
+	UserID           int64
+	Role             int64
+	UserUiid         pgtype.UUID
+	Confirmed        bool
+	Suspended        bool
 }
 
 // >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
 // CACHE QUERIES
-func (q *Queries) GetUserDataFromAPIKey(ctx context.Context, key string) (GetUserDataFromAPIKeyRow, error) {
-	row := q.db.QueryRow(ctx, getUserDataFromAPIKey, key)
+func (q *Queries) GetUserDataFromAPIKey(ctx context.Context, keyHash string) (GetUserDataFromAPIKeyRow, error) {
+	row := q.db.QueryRow(ctx, getUserDataFromAPIKey, keyHash)
 	var i GetUserDataFromAPIKeyRow
 	err := row.Scan(
 		&i.KeyID,
+		&i.ServiceID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.Cache,
-		&i.Storage,
+		&i.Scopes,
+		&i.Revoked,
+		&i.KeyHash,
+		&i.ExpiresAt,
+		&i.PrevKeyExpiresAt,
 		&i.UserID,
 		&i.Role,
 		&i.UserUiid,
 		&i.Confirmed,
+		&i.Suspended,
 	)
 	return i, err
 }
@@ -354,16 +693,74 @@ func (q *Queries) GetUserIDFromClerkID(ctx context.Context, clerkID string) (int
 	return user_id, err
 }
 
-const insertKey = `-- name: InsertKey :one
-INSERT INTO keys (key, cache, storage, expires_at, id)
+const insertCacheData = `-- name: InsertCacheData :exec
+INSERT INTO cache (service_id, get, put, hit, latency_us)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type InsertCacheDataParams struct {
+	ServiceID int64
+	Get       bool
+	Put       bool
+	Hit       bool
+	LatencyUs int64
+}
+
+func (q *Queries) InsertCacheData(ctx context.Context, arg InsertCacheDataParams) error {
+	_, err := q.db.Exec(ctx, insertCacheData,
+		arg.ServiceID,
+		arg.Get,
+		arg.Put,
+		arg.Hit,
+		arg.LatencyUs,
+	)
+	return err
+}
+
+const insertCacheDatabase = `-- name: InsertCacheDatabase :one
+INSERT INTO cache_databases (service_id, name, default_ttl, max_keys, eviction_policy)
 VALUES ($1, $2, $3, $4, $5)
+RETURNING namespace, created_at
+`
+
+type InsertCacheDatabaseParams struct {
+	ServiceID      int64
+	Name           string
+	DefaultTtl     int64
+	MaxKeys        int64
+	EvictionPolicy string
+}
+
+type InsertCacheDatabaseRow struct {
+	Namespace pgtype.UUID
+	CreatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) InsertCacheDatabase(ctx context.Context, arg InsertCacheDatabaseParams) (InsertCacheDatabaseRow, error) {
+	row := q.db.QueryRow(ctx, insertCacheDatabase,
+		arg.ServiceID,
+		arg.Name,
+		arg.DefaultTtl,
+		arg.MaxKeys,
+		arg.EvictionPolicy,
+	)
+	var i InsertCacheDatabaseRow
+	err := row.Scan(&i.Namespace, &i.CreatedAt)
+	return i, err
+}
+
+const insertKey = `-- name: InsertKey :one
+INSERT INTO keys (service_id, key_hash, key_prefix, label, scopes, expires_at, id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING key_id, created_at
 `
 
 type InsertKeyParams struct {
-	Key       string
-	Cache     bool
-	Storage   bool
+	ServiceID int64
+	KeyHash   string
+	KeyPrefix string
+	Label     string
+	Scopes    []string
 	ExpiresAt int64
 	ID        string
 }
@@ -375,9 +772,11 @@ type InsertKeyRow struct {
 
 func (q *Queries) InsertKey(ctx context.Context, arg InsertKeyParams) (InsertKeyRow, error) {
 	row := q.db.QueryRow(ctx, insertKey,
-		arg.Key,
-		arg.Cache,
-		arg.Storage,
+		arg.ServiceID,
+		arg.KeyHash,
+		arg.KeyPrefix,
+		arg.Label,
+		arg.Scopes,
 		arg.ExpiresAt,
 		arg.ID,
 	)
@@ -387,22 +786,49 @@ func (q *Queries) InsertKey(ctx context.Context, arg InsertKeyParams) (InsertKey
 }
 
 const insertNewService = `-- name: InsertNewService :one
-INSERT INTO services (user_id, key_id, name)
-VALUES ($1, $2, $3)
-RETURNING service_uuid
+INSERT INTO services (user_id, name)
+VALUES ($1, $2)
+RETURNING sid, service_uuid, created_at
 `
 
 type InsertNewServiceParams struct {
 	UserID int64
-	KeyID  int64
 	Name   string
 }
 
-func (q *Queries) InsertNewService(ctx context.Context, arg InsertNewServiceParams) (pgtype.UUID, error) {
-	row := q.db.QueryRow(ctx, insertNewService, arg.UserID, arg.KeyID, arg.Name)
-	var service_uuid pgtype.UUID
-	err := row.Scan(&service_uuid)
-	return service_uuid, err
+type InsertNewServiceRow struct {
+	Sid         int64
+	ServiceUuid pgtype.UUID
+	CreatedAt   pgtype.Timestamptz
+}
+
+func (q *Queries) InsertNewService(ctx context.Context, arg InsertNewServiceParams) (InsertNewServiceRow, error) {
+	row := q.db.QueryRow(ctx, insertNewService, arg.UserID, arg.Name)
+	var i InsertNewServiceRow
+	err := row.Scan(&i.Sid, &i.ServiceUuid, &i.CreatedAt)
+	return i, err
+}
+
+const insertRefreshToken = `-- name: InsertRefreshToken :exec
+INSERT INTO refresh_tokens (user_id, jti, family_id, expires_at)
+VALUES ($1, $2, $3, $4)
+`
+
+type InsertRefreshTokenParams struct {
+	UserID    int64
+	Jti       string
+	FamilyID  string
+	ExpiresAt int64
+}
+
+func (q *Queries) InsertRefreshToken(ctx context.Context, arg InsertRefreshTokenParams) error {
+	_, err := q.db.Exec(ctx, insertRefreshToken,
+		arg.UserID,
+		arg.Jti,
+		arg.FamilyID,
+		arg.ExpiresAt,
+	)
+	return err
 }
 
 const insertStorageData = `-- name: InsertStorageData :exec
@@ -446,9 +872,268 @@ func (q *Queries) InsertStorageData(ctx context.Context, arg InsertStorageDataPa
 	return err
 }
 
-const signupUser = `-- name: SignupUser :exec
+const listCacheDatabases = `-- name: ListCacheDatabases :many
+SELECT
+    cache_databases.name,
+    cache_databases.default_ttl,
+    cache_databases.max_keys,
+    cache_databases.eviction_policy,
+    cache_databases.created_at
+FROM cache_databases
+WHERE cache_databases.service_id = $1
+ORDER BY cache_databases.created_at
+`
+
+type ListCacheDatabasesRow struct {
+	Name           string
+	DefaultTtl     int64
+	MaxKeys        int64
+	EvictionPolicy string
+	CreatedAt      pgtype.Timestamptz
+}
+
+func (q *Queries) ListCacheDatabases(ctx context.Context, serviceID int64) ([]ListCacheDatabasesRow, error) {
+	rows, err := q.db.Query(ctx, listCacheDatabases, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCacheDatabasesRow
+	for rows.Next() {
+		var i ListCacheDatabasesRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.DefaultTtl,
+			&i.MaxKeys,
+			&i.EvictionPolicy,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT
+    users.user_id,
+    users.email,
+    users.role,
+    users.user_uiid,
+    users.created_at,
+    users.confirmed,
+    users.deleted,
+    users.suspended,
+    COUNT(services.sid) AS project_count
+FROM users
+LEFT JOIN services ON services.user_id = users.user_id
+GROUP BY users.user_id
+ORDER BY users.user_id
+LIMIT $1 OFFSET $2
+`
+
+type ListUsersParams struct {
+	Limit  int32
+	Offset int32
+}
+
+type ListUsersRow struct {
+	UserID       int64
+	Email        string
+	Role         int64
+	UserUiid     pgtype.UUID
+	CreatedAt    pgtype.Timestamptz
+	Confirmed    bool
+	Deleted      bool
+	Suspended    bool
+	ProjectCount int64
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUsersRow, error) {
+	rows, err := q.db.Query(ctx, listUsers, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUsersRow
+	for rows.Next() {
+		var i ListUsersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Email,
+			&i.Role,
+			&i.UserUiid,
+			&i.CreatedAt,
+			&i.Confirmed,
+			&i.Deleted,
+			&i.Suspended,
+			&i.ProjectCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockService = `-- name: LockService :one
+SELECT
+    services.sid
+FROM services
+WHERE services.sid = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockService(ctx context.Context, serviceID int64) (int64, error) {
+	row := q.db.QueryRow(ctx, lockService, serviceID)
+	var sid int64
+	err := row.Scan(&sid)
+	return sid, err
+}
+
+const renewKey = `-- name: RenewKey :one
+UPDATE keys
+SET
+    prev_key_hash = keys.key_hash,
+    prev_key_expires_at = $4,
+    key_hash = $2,
+    key_prefix = $3,
+    expires_at = $5,
+    updated_at = CURRENT_TIMESTAMP
+WHERE keys.key_id = $1 AND keys.key_hash = $6
+RETURNING key_id, id, label, created_at, expires_at, scopes
+`
+
+type RenewKeyParams struct {
+	KeyID            int64
+	KeyHash          string
+	KeyPrefix        string
+	PrevKeyExpiresAt pgtype.Int8
+	ExpiresAt        int64
+	OldKeyHash       string
+}
+
+type RenewKeyRow struct {
+	KeyID     int64
+	ID        string
+	Label     string
+	CreatedAt pgtype.Timestamptz
+	ExpiresAt int64
+	Scopes    []string
+}
+
+func (q *Queries) RenewKey(ctx context.Context, arg RenewKeyParams) (RenewKeyRow, error) {
+	row := q.db.QueryRow(ctx, renewKey,
+		arg.KeyID,
+		arg.KeyHash,
+		arg.KeyPrefix,
+		arg.PrevKeyExpiresAt,
+		arg.ExpiresAt,
+		arg.OldKeyHash,
+	)
+	var i RenewKeyRow
+	err := row.Scan(
+		&i.KeyID,
+		&i.ID,
+		&i.Label,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.Scopes,
+	)
+	return i, err
+}
+
+const revokeKey = `-- name: RevokeKey :one
+UPDATE keys
+SET
+    revoked = true,
+    updated_at = CURRENT_TIMESTAMP
+WHERE keys.service_id = $1
+AND keys.id = $2
+RETURNING key_id
+`
+
+type RevokeKeyParams struct {
+	ServiceID int64
+	ID        string
+}
+
+func (q *Queries) RevokeKey(ctx context.Context, arg RevokeKeyParams) (int64, error) {
+	row := q.db.QueryRow(ctx, revokeKey, arg.ServiceID, arg.ID)
+	var key_id int64
+	err := row.Scan(&key_id)
+	return key_id, err
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked = true
+WHERE refresh_tokens.family_id = $1
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	_, err := q.db.Exec(ctx, revokeRefreshTokenFamily, familyID)
+	return err
+}
+
+const revokeUserKeys = `-- name: RevokeUserKeys :exec
+UPDATE keys
+SET
+    revoked = true,
+    updated_at = CURRENT_TIMESTAMP
+FROM services
+WHERE keys.service_id = services.sid
+AND services.user_id = $1
+AND keys.revoked = false
+`
+
+func (q *Queries) RevokeUserKeys(ctx context.Context, userID int64) error {
+	_, err := q.db.Exec(ctx, revokeUserKeys, userID)
+	return err
+}
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked = true
+WHERE refresh_tokens.user_id = $1
+AND refresh_tokens.revoked = false
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID int64) error {
+	_, err := q.db.Exec(ctx, revokeUserRefreshTokens, userID)
+	return err
+}
+
+const setUserSuspended = `-- name: SetUserSuspended :execrows
+UPDATE users
+SET suspended = $2
+WHERE users.user_id = $1
+`
+
+type SetUserSuspendedParams struct {
+	UserID    int64
+	Suspended bool
+}
+
+func (q *Queries) SetUserSuspended(ctx context.Context, arg SetUserSuspendedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setUserSuspended, arg.UserID, arg.Suspended)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const signupUser = `-- name: SignupUser :one
 INSERT INTO users (email, role, clerk_id)
 VALUES ($1, $2, $3)
+RETURNING user_id
 `
 
 type SignupUserParams struct {
@@ -457,34 +1142,146 @@ type SignupUserParams struct {
 	ClerkID string
 }
 
-func (q *Queries) SignupUser(ctx context.Context, arg SignupUserParams) error {
-	_, err := q.db.Exec(ctx, signupUser, arg.Email, arg.Role, arg.ClerkID)
-	return err
+func (q *Queries) SignupUser(ctx context.Context, arg SignupUserParams) (int64, error) {
+	row := q.db.QueryRow(ctx, signupUser, arg.Email, arg.Role, arg.ClerkID)
+	var user_id int64
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const signupUserWithPassword = `-- name: SignupUserWithPassword :one
+
+
+INSERT INTO users (email, password, role)
+VALUES ($1, $2, $3)
+RETURNING user_id
+`
+
+type SignupUserWithPasswordParams struct {
+	Email    string
+	Password string
+	Role     int64
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// Native Auth
+func (q *Queries) SignupUserWithPassword(ctx context.Context, arg SignupUserWithPasswordParams) (int64, error) {
+	row := q.db.QueryRow(ctx, signupUserWithPassword, arg.Email, arg.Password, arg.Role)
+	var user_id int64
+	err := row.Scan(&user_id)
+	return user_id, err
 }
 
-const updateKeyServicesConfirmation = `-- name: UpdateKeyServicesConfirmation :one
+const softDeleteClerkUser = `-- name: SoftDeleteClerkUser :one
+UPDATE users
+SET deleted = true
+WHERE users.clerk_id = $1
+RETURNING user_id
+`
+
+func (q *Queries) SoftDeleteClerkUser(ctx context.Context, clerkID string) (int64, error) {
+	row := q.db.QueryRow(ctx, softDeleteClerkUser, clerkID)
+	var user_id int64
+	err := row.Scan(&user_id)
+	return user_id, err
+}
+
+const updateClerkUserEmail = `-- name: UpdateClerkUserEmail :execrows
+UPDATE users
+SET
+    email = $2,
+    confirmed = $3::boolean OR (users.confirmed AND users.email = $2)
+WHERE users.clerk_id = $1
+`
+
+type UpdateClerkUserEmailParams struct {
+	ClerkID   string
+	Email     string
+	Confirmed bool
+}
+
+func (q *Queries) UpdateClerkUserEmail(ctx context.Context, arg UpdateClerkUserEmailParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateClerkUserEmail, arg.ClerkID, arg.Email, arg.Confirmed)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateKeyScopes = `-- name: UpdateKeyScopes :one
 UPDATE keys 
 SET 
-    cache = $2,
-    storage = $3
-WHERE key_id = $1
-RETURNING cache, storage
+    scopes = $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE keys.service_id = $1
+AND keys.id = $2
+RETURNING scopes
 `
 
-type UpdateKeyServicesConfirmationParams struct {
-	KeyID   int64
-	Cache   bool
-	Storage bool
+type UpdateKeyScopesParams struct {
+	ServiceID int64
+	ID        string
+	Scopes    []string
+}
+
+func (q *Queries) UpdateKeyScopes(ctx context.Context, arg UpdateKeyScopesParams) ([]string, error) {
+	row := q.db.QueryRow(ctx, updateKeyScopes, arg.ServiceID, arg.ID, arg.Scopes)
+	var scopes []string
+	err := row.Scan(&scopes)
+	return scopes, err
+}
+
+const upsertClerkUser = `-- name: UpsertClerkUser :one
+
+
+INSERT INTO users (email, role, clerk_id, confirmed)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (clerk_id) WHERE clerk_id <> 'id'
+DO UPDATE SET
+    email = EXCLUDED.email,
+    confirmed = EXCLUDED.confirmed OR (users.confirmed AND users.email = EXCLUDED.email)
+RETURNING user_id
+`
+
+type UpsertClerkUserParams struct {
+	Email     string
+	Role      int64
+	ClerkID   string
+	Confirmed bool
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+// Clerk Webhooks
+func (q *Queries) UpsertClerkUser(ctx context.Context, arg UpsertClerkUserParams) (int64, error) {
+	row := q.db.QueryRow(ctx, upsertClerkUser,
+		arg.Email,
+		arg.Role,
+		arg.ClerkID,
+		arg.Confirmed,
+	)
+	var user_id int64
+	err := row.Scan(&user_id)
+	return user_id, err
 }
 
-type UpdateKeyServicesConfirmationRow struct {
-	Cache   bool
-	Storage bool
+const useRefreshToken = `-- name: UseRefreshToken :one
+UPDATE refresh_tokens
+SET used = true
+WHERE refresh_tokens.jti = $1
+AND refresh_tokens.used = false
+AND refresh_tokens.revoked = false
+RETURNING user_id, family_id, expires_at
+`
+
+type UseRefreshTokenRow struct {
+	UserID    int64
+	FamilyID  string
+	ExpiresAt int64
 }
 
-func (q *Queries) UpdateKeyServicesConfirmation(ctx context.Context, arg UpdateKeyServicesConfirmationParams) (UpdateKeyServicesConfirmationRow, error) {
-	row := q.db.QueryRow(ctx, updateKeyServicesConfirmation, arg.KeyID, arg.Cache, arg.Storage)
-	var i UpdateKeyServicesConfirmationRow
-	err := row.Scan(&i.Cache, &i.Storage)
+func (q *Queries) UseRefreshToken(ctx context.Context, jti string) (UseRefreshTokenRow, error) {
+	row := q.db.QueryRow(ctx, useRefreshToken, jti)
+	var i UseRefreshTokenRow
+	err := row.Scan(&i.UserID, &i.FamilyID, &i.ExpiresAt)
 	return i, err
 }
@@ -0,0 +1,6 @@
+-- Adds the previous key columns used by the key renewal grace window.
+-- Safe to run against an existing database, schema.sql already includes these columns.
+
+ALTER TABLE public.keys
+    ADD COLUMN IF NOT EXISTS prev_key text COLLATE pg_catalog."default",
+    ADD COLUMN IF NOT EXISTS prev_key_expires_at bigint;
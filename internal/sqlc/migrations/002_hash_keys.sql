@@ -0,0 +1,26 @@
+-- Moves api keys from plaintext to a sha256 hash plus a short visible prefix.
+-- Existing keys keep working, the hash is computed the same way as apikeys.HashKey
+-- and the prefix length matches apikeys.KeyPrefixLength.
+-- Run inside a single transaction, the plaintext columns are dropped at the end.
+
+BEGIN;
+
+ALTER TABLE public.keys
+    ADD COLUMN IF NOT EXISTS key_hash text COLLATE pg_catalog."default",
+    ADD COLUMN IF NOT EXISTS key_prefix text COLLATE pg_catalog."default",
+    ADD COLUMN IF NOT EXISTS prev_key_hash text COLLATE pg_catalog."default";
+
+UPDATE public.keys
+SET
+    key_hash = encode(sha256(key::bytea), 'hex'),
+    key_prefix = left(key, 16),
+    prev_key_hash = CASE WHEN prev_key IS NULL THEN NULL ELSE encode(sha256(prev_key::bytea), 'hex') END;
+
+ALTER TABLE public.keys
+    ALTER COLUMN key_hash SET NOT NULL,
+    ALTER COLUMN key_prefix SET NOT NULL,
+    ADD CONSTRAINT keys_key_hash_key UNIQUE (key_hash),
+    DROP COLUMN key,
+    DROP COLUMN prev_key;
+
+COMMIT;
@@ -0,0 +1,32 @@
+-- Turns the project -> key relation into one-to-many. Existing keys keep working as the "default"
+-- key of their project, their cache/storage booleans are turned into scopes by 004_key_scopes.
+
+BEGIN;
+
+ALTER TABLE public.keys
+    ADD COLUMN IF NOT EXISTS service_id bigint,
+    ADD COLUMN IF NOT EXISTS label text COLLATE pg_catalog."default" NOT NULL DEFAULT 'default'::text,
+    ADD COLUMN IF NOT EXISTS revoked boolean NOT NULL DEFAULT false;
+
+UPDATE public.keys
+SET service_id = services.sid
+FROM public.services
+WHERE services.key_id = keys.key_id;
+
+-- keys that never got a service attached cannot be used anyway
+DELETE FROM public.keys
+WHERE keys.service_id IS NULL;
+
+ALTER TABLE public.services
+    DROP CONSTRAINT IF EXISTS keys_services_key_id_fkey,
+    DROP COLUMN key_id;
+
+ALTER TABLE public.keys
+    ALTER COLUMN service_id SET NOT NULL,
+    ADD CONSTRAINT services_keys_service_id_fkey FOREIGN KEY (service_id)
+        REFERENCES public.services (sid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+        NOT VALID;
+
+COMMIT;
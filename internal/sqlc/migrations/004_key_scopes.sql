@@ -0,0 +1,23 @@
+-- Replaces the cache/storage booleans on keys with a list of operation scopes (see const/scopes).
+-- Cache access maps to get, put and delete, storage access to upload, download and list.
+
+BEGIN;
+
+ALTER TABLE public.keys
+    ADD COLUMN IF NOT EXISTS scopes text[] COLLATE pg_catalog."default" NOT NULL DEFAULT '{}'::text[];
+
+UPDATE public.keys
+SET scopes = array_remove(ARRAY[
+    CASE WHEN keys.cache THEN 'cache:get' END,
+    CASE WHEN keys.cache THEN 'cache:put' END,
+    CASE WHEN keys.cache THEN 'cache:delete' END,
+    CASE WHEN keys.storage THEN 'storage:upload' END,
+    CASE WHEN keys.storage THEN 'storage:download' END,
+    CASE WHEN keys.storage THEN 'storage:list' END
+], NULL);
+
+ALTER TABLE public.keys
+    DROP COLUMN cache,
+    DROP COLUMN storage;
+
+COMMIT;
@@ -0,0 +1,36 @@
+-- Native email/password auth. Refresh tokens are single use, every refresh rotates the token
+-- within its family and presenting an already used token revokes the whole family.
+-- Emails must be unique now that they are used to log in.
+
+BEGIN;
+
+CREATE SEQUENCE IF NOT EXISTS public.refresh_tokens_rt_id_seq;
+
+CREATE TABLE IF NOT EXISTS public.refresh_tokens
+(
+    rt_id bigint NOT NULL DEFAULT nextval('refresh_tokens_rt_id_seq'::regclass),
+    user_id bigint NOT NULL,
+    jti text COLLATE pg_catalog."default" NOT NULL,
+    family_id text COLLATE pg_catalog."default" NOT NULL,
+    used boolean NOT NULL DEFAULT false,
+    revoked boolean NOT NULL DEFAULT false,
+    expires_at bigint NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT refresh_tokens_pkey PRIMARY KEY (rt_id),
+    CONSTRAINT refresh_tokens_jti_key UNIQUE (jti),
+    CONSTRAINT users_refresh_tokens_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES public.users (user_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+ALTER SEQUENCE public.refresh_tokens_rt_id_seq OWNED BY public.refresh_tokens.rt_id;
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx
+    ON public.refresh_tokens (family_id);
+
+ALTER TABLE public.users
+    ADD CONSTRAINT users_email_key UNIQUE (email);
+
+COMMIT;
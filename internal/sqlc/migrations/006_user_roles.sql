@@ -0,0 +1,12 @@
+-- Roles are now enforced (see const/roles): 1 user, 2 support, 3 admin.
+-- Suspended users can no longer log in or use their api keys.
+
+BEGIN;
+
+ALTER TABLE public.users
+    ADD COLUMN IF NOT EXISTS suspended boolean NOT NULL DEFAULT false;
+
+ALTER TABLE public.users
+    ADD CONSTRAINT users_role_check CHECK (role IN (1, 2, 3));
+
+COMMIT;
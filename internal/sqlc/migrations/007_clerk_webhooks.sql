@@ -0,0 +1,10 @@
+-- Clerk webhooks upsert users by their clerk id. Natively registered users all keep the
+-- default 'id' clerk id, so only real clerk ids have to be unique.
+
+BEGIN;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_clerk_id_key
+    ON public.users (clerk_id)
+    WHERE clerk_id <> 'id'::text;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Unconfirmed users can no longer create projects. Users that signed up before confirmation
+-- emails were sent had no way to confirm, so they are treated as confirmed.
+
+BEGIN;
+
+UPDATE public.users
+SET confirmed = true
+WHERE confirmed = false;
+
+COMMIT;
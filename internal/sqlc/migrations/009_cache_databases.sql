@@ -0,0 +1,31 @@
+-- Named cache databases of a project. Each one stores its keys in its own cache namespace,
+-- a fresh one when a dropped database is created again, with its own key limits.
+-- Keys put without a database keep using the namespace of the user_uiid.
+
+BEGIN;
+
+CREATE SEQUENCE IF NOT EXISTS public.cache_databases_cdb_id_seq;
+
+CREATE TABLE IF NOT EXISTS public.cache_databases
+(
+    cdb_id bigint NOT NULL DEFAULT nextval('cache_databases_cdb_id_seq'::regclass),
+    service_id bigint NOT NULL,
+    name text COLLATE pg_catalog."default" NOT NULL,
+    namespace uuid NOT NULL DEFAULT gen_random_uuid(),
+    default_ttl bigint NOT NULL DEFAULT 0,
+    max_keys bigint NOT NULL DEFAULT 0,
+    eviction_policy text COLLATE pg_catalog."default" NOT NULL DEFAULT 'noeviction'::text,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT cache_databases_pkey PRIMARY KEY (cdb_id),
+    CONSTRAINT cache_databases_service_id_name_key UNIQUE (service_id, name),
+    CONSTRAINT cache_databases_eviction_policy_check CHECK (eviction_policy IN ('noeviction', 'lru')),
+    CONSTRAINT services_cache_databases_service_id_fkey FOREIGN KEY (service_id)
+        REFERENCES public.services (sid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+ALTER SEQUENCE public.cache_databases_cdb_id_seq OWNED BY public.cache_databases.cdb_id;
+
+COMMIT;
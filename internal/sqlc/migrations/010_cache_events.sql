@@ -0,0 +1,10 @@
+-- Cache analytics events are now recorded by the cache service on every put and get.
+-- Gets also record whether the key was found, and both record how long the cache backend took.
+
+BEGIN;
+
+ALTER TABLE public.cache
+    ADD COLUMN IF NOT EXISTS hit boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS latency_us bigint NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -6,9 +6,10 @@ SELECT
 FROM users
 WHERE users.clerk_id = $1;
 
--- name: SignupUser :exec
+-- name: SignupUser :one
 INSERT INTO users (email, role, clerk_id)
-VALUES ($1, $2, $3);
+VALUES ($1, $2, $3)
+RETURNING user_id;
 
 
 -- name: GetUserData :one
@@ -19,7 +20,8 @@ SELECT
     users.user_uiid,
     users.created_at,
     users.confirmed,
-    users.deleted
+    users.deleted,
+    users.suspended
 FROM users
 WHERE users.user_id = $1;
 
@@ -32,24 +34,47 @@ AND services.name = $2;
 
 
 -- name: InsertKey :one
-INSERT INTO keys (key, cache, storage, expires_at, id)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO keys (service_id, key_hash, key_prefix, label, scopes, expires_at, id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING key_id, created_at;
 
--- name: UpdateKeyServicesConfirmation :one
+-- name: UpdateKeyScopes :one
 UPDATE keys 
 SET 
-    cache = $2,
-    storage = $3
-WHERE key_id = $1
-RETURNING cache, storage;
+    scopes = $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE keys.service_id = $1
+AND keys.id = $2
+RETURNING scopes;
+
+-- name: RevokeKey :one
+UPDATE keys
+SET
+    revoked = true,
+    updated_at = CURRENT_TIMESTAMP
+WHERE keys.service_id = $1
+AND keys.id = $2
+RETURNING key_id;
+
+-- name: GetServiceKeys :many
+SELECT
+    keys.id,
+    keys.key_prefix,
+    keys.label,
+    keys.created_at,
+    keys.expires_at,
+    keys.scopes,
+    keys.revoked
+FROM keys
+WHERE keys.service_id = $1
+ORDER BY keys.created_at DESC;
 
 
 
 -- name: InsertNewService :one
-INSERT INTO services (user_id, key_id, name)
-VALUES ($1, $2, $3)
-RETURNING service_uuid;
+INSERT INTO services (user_id, name)
+VALUES ($1, $2)
+RETURNING sid, service_uuid, created_at;
 
 
 -- name: GetUserIDFromClerkID :one
@@ -62,18 +87,12 @@ WHERE users.clerk_id = $1;
 -- name: GetServiceData :one
 SELECT
     services.sid,
-    services.user_id,
-    services.key_id
+    services.user_id
 FROM services
 WHERE services.name = $1;
 
 
 
--- name: DeleteKey :exec
-DELETE FROM keys
-WHERE keys.key_id = $1;
-
-
 -- name: DeleteService :exec
 DELETE FROM services
 WHERE services.sid = $1;
@@ -87,26 +106,51 @@ SELECT
     services.created_at,
     services.name,
 
-    keys.key,
+    keys.key_prefix,
+    keys.label,
     keys.created_at,
-    keys.updated_at,
-    keys.cache,
-    keys.storage,
+    keys.scopes,
     keys.expires_at,
     keys.id
 FROM services
-LEFT JOIN keys ON services.key_id = keys.key_id
+LEFT JOIN keys ON services.sid = keys.service_id
+AND keys.revoked = false
 WHERE services.user_id = $1
-ORDER BY services.created_at DESC;
+ORDER BY services.created_at DESC, keys.created_at DESC;
 
 
 
 -- name: GetServiceIDFromAPIKey :one
 SELECT
-    services.sid
-FROM services
-LEFT JOIN keys ON services.key_id = keys.key_id
-WHERE keys.key = $1;
+    keys.service_id
+FROM keys
+WHERE keys.key_hash = $1
+OR keys.prev_key_hash = $1;
+
+
+-- name: GetKeyOwner :one
+SELECT
+    keys.key_id,
+    keys.revoked,
+    keys.prev_key_expires_at,
+    keys.expires_at,
+    services.user_id
+FROM keys
+JOIN services ON keys.service_id = services.sid
+WHERE keys.key_hash = $1;
+
+
+-- name: RenewKey :one
+UPDATE keys
+SET
+    prev_key_hash = keys.key_hash,
+    prev_key_expires_at = $4,
+    key_hash = $2,
+    key_prefix = $3,
+    expires_at = $5,
+    updated_at = CURRENT_TIMESTAMP
+WHERE keys.key_id = $1 AND keys.key_hash = sqlc.arg(old_key_hash)
+RETURNING key_id, id, label, created_at, expires_at, scopes;
 
 
 
@@ -117,19 +161,25 @@ WHERE keys.key = $1;
 -- name: GetUserDataFromAPIKey :one
 SELECT
     keys.key_id,
+    keys.service_id,
     keys.created_at,
     keys.updated_at,
-    keys.cache,
-    keys.storage,
+    keys.scopes,
+    keys.revoked,
+    keys.key_hash,
+    keys.expires_at,
+    keys.prev_key_expires_at,
 
     users.user_id,
     users.role,
     users.user_uiid,
-    users.confirmed
+    users.confirmed,
+    users.suspended
 FROM keys
-JOIN services ON keys.key_id = services.key_id
+JOIN services ON keys.service_id = services.sid
 JOIN users ON users.user_id = services.user_id
-WHERE keys.key = $1;
+WHERE keys.key_hash = $1
+OR keys.prev_key_hash = $1;
 
 
 
@@ -179,3 +229,235 @@ WHERE cache.service_id = $1
 AND cache.get = $2
 AND cache.put = $3;
 
+
+-- name: InsertCacheData :exec
+INSERT INTO cache (service_id, get, put, hit, latency_us)
+VALUES ($1, $2, $3, $4, $5);
+
+
+
+-- >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+-- Native Auth
+
+
+-- name: SignupUserWithPassword :one
+INSERT INTO users (email, password, role)
+VALUES ($1, $2, $3)
+RETURNING user_id;
+
+
+-- name: GetUserAuthByEmail :one
+SELECT
+    users.user_id,
+    users.email,
+    users.password,
+    users.role,
+    users.deleted,
+    users.suspended
+FROM users
+WHERE users.email = $1;
+
+
+-- name: InsertRefreshToken :exec
+INSERT INTO refresh_tokens (user_id, jti, family_id, expires_at)
+VALUES ($1, $2, $3, $4);
+
+
+-- name: UseRefreshToken :one
+UPDATE refresh_tokens
+SET used = true
+WHERE refresh_tokens.jti = $1
+AND refresh_tokens.used = false
+AND refresh_tokens.revoked = false
+RETURNING user_id, family_id, expires_at;
+
+
+-- name: GetRefreshTokenFamily :one
+SELECT
+    refresh_tokens.family_id
+FROM refresh_tokens
+WHERE refresh_tokens.jti = $1;
+
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked = true
+WHERE refresh_tokens.family_id = $1;
+
+
+
+-- >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+-- Admin
+
+
+-- name: GetUserAccess :one
+SELECT
+    users.user_id,
+    users.role,
+    users.deleted,
+    users.suspended
+FROM users
+WHERE users.user_id = $1;
+
+
+-- name: GetUserAccessFromClerkID :one
+SELECT
+    users.user_id,
+    users.role,
+    users.deleted,
+    users.suspended
+FROM users
+WHERE users.clerk_id = $1;
+
+
+-- name: ListUsers :many
+SELECT
+    users.user_id,
+    users.email,
+    users.role,
+    users.user_uiid,
+    users.created_at,
+    users.confirmed,
+    users.deleted,
+    users.suspended,
+    COUNT(services.sid) AS project_count
+FROM users
+LEFT JOIN services ON services.user_id = users.user_id
+GROUP BY users.user_id
+ORDER BY users.user_id
+LIMIT $1 OFFSET $2;
+
+
+-- name: SetUserSuspended :execrows
+UPDATE users
+SET suspended = $2
+WHERE users.user_id = $1;
+
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked = true
+WHERE refresh_tokens.user_id = $1
+AND refresh_tokens.revoked = false;
+
+
+-- name: GetServiceByUUID :one
+SELECT
+    services.sid,
+    services.service_uuid,
+    services.name,
+    services.created_at,
+    users.user_id,
+    users.email
+FROM services
+JOIN users ON users.user_id = services.user_id
+WHERE services.service_uuid = $1;
+
+
+
+-- >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+-- Clerk Webhooks
+
+
+-- name: UpsertClerkUser :one
+INSERT INTO users (email, role, clerk_id, confirmed)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (clerk_id) WHERE clerk_id <> 'id'
+DO UPDATE SET
+    email = EXCLUDED.email,
+    confirmed = EXCLUDED.confirmed OR (users.confirmed AND users.email = EXCLUDED.email)
+RETURNING user_id;
+
+
+-- name: UpdateClerkUserEmail :execrows
+UPDATE users
+SET
+    email = $2,
+    confirmed = sqlc.arg(confirmed)::boolean OR (users.confirmed AND users.email = $2)
+WHERE users.clerk_id = $1;
+
+
+-- name: SoftDeleteClerkUser :one
+UPDATE users
+SET deleted = true
+WHERE users.clerk_id = $1
+RETURNING user_id;
+
+
+-- name: RevokeUserKeys :exec
+UPDATE keys
+SET
+    revoked = true,
+    updated_at = CURRENT_TIMESTAMP
+FROM services
+WHERE keys.service_id = services.sid
+AND services.user_id = $1
+AND keys.revoked = false;
+
+
+
+-- >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+-- Email Confirmation
+
+
+-- name: ConfirmUser :execrows
+UPDATE users
+SET confirmed = true
+WHERE users.user_id = $1
+AND users.email = $2;
+
+
+
+-- >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+-- Cache Databases
+
+
+-- name: CountCacheDatabases :one
+SELECT
+    COUNT(cache_databases.cdb_id)
+FROM cache_databases
+WHERE cache_databases.service_id = $1;
+
+
+-- name: LockService :one
+SELECT
+    services.sid
+FROM services
+WHERE services.sid = sqlc.arg(service_id)
+FOR UPDATE;
+
+
+-- name: InsertCacheDatabase :one
+INSERT INTO cache_databases (service_id, name, default_ttl, max_keys, eviction_policy)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING namespace, created_at;
+
+
+-- name: GetCacheDatabase :one
+SELECT
+    cache_databases.namespace,
+    cache_databases.default_ttl,
+    cache_databases.max_keys,
+    cache_databases.eviction_policy
+FROM cache_databases
+WHERE cache_databases.service_id = $1
+AND cache_databases.name = $2;
+
+
+-- name: ListCacheDatabases :many
+SELECT
+    cache_databases.name,
+    cache_databases.default_ttl,
+    cache_databases.max_keys,
+    cache_databases.eviction_policy,
+    cache_databases.created_at
+FROM cache_databases
+WHERE cache_databases.service_id = $1
+ORDER BY cache_databases.created_at;
+
+
+-- name: DeleteCacheDatabase :one
+DELETE FROM cache_databases
+WHERE cache_databases.service_id = $1
+AND cache_databases.name = $2
+RETURNING namespace, max_keys, eviction_policy;
@@ -9,7 +9,10 @@ CREATE TABLE IF NOT EXISTS public.users
     confirmed boolean NOT NULL DEFAULT false,
     deleted boolean NOT NULL DEFAULT false,
     clerk_id text NOT NULL DEFAULT 'id'::text,
-    CONSTRAINT users_pkey PRIMARY KEY (user_id)
+    suspended boolean NOT NULL DEFAULT false,
+    CONSTRAINT users_pkey PRIMARY KEY (user_id),
+    CONSTRAINT users_email_key UNIQUE (email),
+    CONSTRAINT users_role_check CHECK (role IN (1, 2, 3))
 );
 
 CREATE TABLE IF NOT EXISTS public.services
@@ -18,14 +21,8 @@ CREATE TABLE IF NOT EXISTS public.services
     user_id bigint NOT NULL,
     service_uuid uuid NOT NULL DEFAULT gen_random_uuid(),
     created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    key_id bigint NOT NULL,
     name text NOT NULL,
     CONSTRAINT services_pkey PRIMARY KEY (sid),
-    CONSTRAINT keys_services_key_id_fkey FOREIGN KEY (key_id)
-        REFERENCES public.keys (key_id) MATCH SIMPLE
-        ON UPDATE CASCADE
-        ON DELETE CASCADE
-        NOT VALID,
     CONSTRAINT users_services_user_id_fkey FOREIGN KEY (user_id)
         REFERENCES public.users (user_id) MATCH SIMPLE
         ON UPDATE CASCADE
@@ -36,14 +33,25 @@ CREATE TABLE IF NOT EXISTS public.services
 CREATE TABLE IF NOT EXISTS public.keys
 (
     key_id bigint NOT NULL DEFAULT nextval('keys_key_id_seq'::regclass),
-    key text COLLATE pg_catalog."default" NOT NULL,
+    service_id bigint NOT NULL,
+    key_hash text COLLATE pg_catalog."default" NOT NULL,
+    key_prefix text COLLATE pg_catalog."default" NOT NULL,
+    label text COLLATE pg_catalog."default" NOT NULL DEFAULT 'default'::text,
     created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    cache boolean NOT NULL DEFAULT false,
-    storage boolean NOT NULL DEFAULT false,
+    scopes text[] COLLATE pg_catalog."default" NOT NULL DEFAULT '{}'::text[],
+    revoked boolean NOT NULL DEFAULT false,
     expires_at bigint NOT NULL,
     id text COLLATE pg_catalog."default" NOT NULL,
-    CONSTRAINT keys_pkey PRIMARY KEY (key_id)
+    prev_key_hash text COLLATE pg_catalog."default",
+    prev_key_expires_at bigint,
+    CONSTRAINT keys_pkey PRIMARY KEY (key_id),
+    CONSTRAINT keys_key_hash_key UNIQUE (key_hash),
+    CONSTRAINT services_keys_service_id_fkey FOREIGN KEY (service_id)
+        REFERENCES public.services (sid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+        NOT VALID
 );
 
 CREATE TABLE IF NOT EXISTS public.storage
@@ -67,6 +75,8 @@ CREATE TABLE IF NOT EXISTS public.cache
     service_id bigint NOT NULL,
     get boolean NOT NULL DEFAULT false,
     put boolean NOT NULL DEFAULT false,
+    hit boolean NOT NULL DEFAULT false,
+    latency_us bigint NOT NULL DEFAULT 0,
     created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
     CONSTRAINT cache_pkey PRIMARY KEY (cch_id),
     CONSTRAINT services_cache_cch_id_fkey FOREIGN KEY (service_id)
@@ -74,4 +84,47 @@ CREATE TABLE IF NOT EXISTS public.cache
         ON UPDATE CASCADE
         ON DELETE CASCADE
         NOT VALID
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS public.cache_databases
+(
+    cdb_id bigint NOT NULL DEFAULT nextval('cache_databases_cdb_id_seq'::regclass),
+    service_id bigint NOT NULL,
+    name text COLLATE pg_catalog."default" NOT NULL,
+    namespace uuid NOT NULL DEFAULT gen_random_uuid(),
+    default_ttl bigint NOT NULL DEFAULT 0,
+    max_keys bigint NOT NULL DEFAULT 0,
+    eviction_policy text COLLATE pg_catalog."default" NOT NULL DEFAULT 'noeviction'::text,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT cache_databases_pkey PRIMARY KEY (cdb_id),
+    CONSTRAINT cache_databases_service_id_name_key UNIQUE (service_id, name),
+    CONSTRAINT cache_databases_eviction_policy_check CHECK (eviction_policy IN ('noeviction', 'lru')),
+    CONSTRAINT services_cache_databases_service_id_fkey FOREIGN KEY (service_id)
+        REFERENCES public.services (sid) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+CREATE TABLE IF NOT EXISTS public.refresh_tokens
+(
+    rt_id bigint NOT NULL DEFAULT nextval('refresh_tokens_rt_id_seq'::regclass),
+    user_id bigint NOT NULL,
+    jti text COLLATE pg_catalog."default" NOT NULL,
+    family_id text COLLATE pg_catalog."default" NOT NULL,
+    used boolean NOT NULL DEFAULT false,
+    revoked boolean NOT NULL DEFAULT false,
+    expires_at bigint NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT refresh_tokens_pkey PRIMARY KEY (rt_id),
+    CONSTRAINT refresh_tokens_jti_key UNIQUE (jti),
+    CONSTRAINT users_refresh_tokens_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES public.users (user_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+        NOT VALID
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_clerk_id_key
+    ON public.users (clerk_id)
+    WHERE clerk_id <> 'id'::text;
@@ -6,18 +6,30 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"time"
 )
 
+var (
+	ErrMalformedKey = errors.New("api key is malformed")
+	ErrInvalidSignature = errors.New("api key signature does not match")
+	ErrUnknownVersion = errors.New("api key version is not supported")
+)
+
 type APICreds struct {
     ID   string `json:"id"`
     Key  string `json:"key"`
+    CreatedAt int64 `json:"-"` // unix time signed into the metadata of the key
 }
 
 type DecodedKey struct {
-
+	Version string
+	ID string
+	Metadata *KeyMetadata
 }
 
 type KeyMetadata struct {
@@ -37,15 +49,9 @@ func generateRandomStr(length int32) (randStr string, err error) {
 
 func CreateWithOutSeed() (*APICreds, error) {
 
-	secretPassStr, exists := os.LookupEnv("APIKeySecretPassword")
-	if !exists {
-		return nil, fmt.Errorf("no api key signing password found in env")
-	}
-	secretPass := []byte(secretPassStr)
-
 	keyVersion, exists := os.LookupEnv("APIKeyGenerationVersion")
 	if !exists {
-		return nil, fmt.Errorf("no api key signing password found in env")
+		return nil, fmt.Errorf("no api key generation version found in env")
 	}
 
 	apiID, err := generateRandomStr(64)
@@ -53,8 +59,33 @@ func CreateWithOutSeed() (*APICreds, error) {
 		return nil, err
 	}
 
+	return createForID(apiID, keyVersion)
+}
+
+// RecreateFromOld issues a fresh key for the same api ID and version as the given old key.
+// The old key must still carry a valid signature, expired keys are allowed so that they can be renewed.
+func RecreateFromOld(oldKey string) (*APICreds, error) {
+
+	decoded, err := DecodeKey(oldKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return createForID(decoded.ID, decoded.Version)
+}
+
+// createForID signs a new key for the given api ID and version with fresh metadata.
+func createForID(apiID string, keyVersion string) (*APICreds, error) {
+
+	secretPassStr, exists := os.LookupEnv("APIKeySecretPassword")
+	if !exists {
+		return nil, fmt.Errorf("no api key signing password found in env")
+	}
+	secretPass := []byte(secretPassStr)
+
+	createdAt := time.Now().Unix()
 	metaBytes, err := json.Marshal(KeyMetadata{
-		CreatedAt: time.Now().Unix(),
+		CreatedAt: createdAt,
 	})
 	if err != nil {
 		return nil, err
@@ -73,6 +104,7 @@ func CreateWithOutSeed() (*APICreds, error) {
 	return &APICreds{
 		ID: apiID,
 		Key: apiKey,
+		CreatedAt: createdAt,
 	}, nil
 }
 
@@ -120,16 +152,66 @@ func CreateWithGiven(serviceID string) (*APICreds, error) {
 	}, nil
 }
 
+// DecodeKey parses a key of the form version.id.meta.signature, verifies its signature
+// and returns the decoded parts. Keys of the current version are accepted, along with the older versions
+// listed comma separated in the APIKeyAcceptedVersions env. The returned error is one of ErrMalformedKey, 
+// ErrUnknownVersion or ErrInvalidSignature when the key itself is at fault.
+func DecodeKey(key string) (*DecodedKey, error) {
 
-// TODO: there should be a recreate from old key function for ttl based renewation
-// TODO: we should be able to validate keys
+	secretPassStr, exists := os.LookupEnv("APIKeySecretPassword")
+	if !exists {
+		return nil, fmt.Errorf("no api key signing password found in env")
+	}
 
+	keyVersion, exists := os.LookupEnv("APIKeyGenerationVersion")
+	if !exists {
+		return nil, fmt.Errorf("no api key generation version found in env")
+	}
 
-func DecodeKey(key string) (*DecodedKey, error) {
+	parts := strings.Split(key, ".")
+	if len(parts) != 4 {
+		return nil, ErrMalformedKey
+	}
+	version, apiID, metaEncoded, sigEncoded := parts[0], parts[1], parts[2], parts[3]
+	if version == "" || apiID == "" || metaEncoded == "" || sigEncoded == "" {
+		return nil, ErrMalformedKey
+	}
 
-	
+	if version != keyVersion && !slices.Contains(strings.Split(os.Getenv("APIKeyAcceptedVersions"), ","), version) {
+		return nil, ErrUnknownVersion
+	}
+
+	sig, err := base64.URLEncoding.DecodeString(sigEncoded)
+	if err != nil {
+		return nil, ErrMalformedKey
+	}
 
+	h := hmac.New(sha256.New, []byte(secretPassStr))
 
+	_, err = h.Write([]byte(apiID + "." + metaEncoded))
+	if err != nil {
+		return nil, err
+	}
+
+	// constant time comparison, a plain compare leaks how much of the signature matched
+	if !hmac.Equal(sig, h.Sum(nil)) {
+		return nil, ErrInvalidSignature
+	}
+
+	metaBytes, err := base64.URLEncoding.DecodeString(metaEncoded)
+	if err != nil {
+		return nil, ErrMalformedKey
+	}
 
-	return nil, nil
+	meta := new(KeyMetadata)
+	err = json.Unmarshal(metaBytes, meta)
+	if err != nil {
+		return nil, ErrMalformedKey
+	}
+
+	return &DecodedKey{
+		Version: version,
+		ID: apiID,
+		Metadata: meta,
+	}, nil
 }
\ No newline at end of file
@@ -0,0 +1,116 @@
+package apikeys
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func setKeyEnv(t *testing.T, version string, accepted string) {
+	t.Setenv("APIKeySecretPassword", "test-password")
+	t.Setenv("APIKeyGenerationVersion", version)
+	t.Setenv("APIKeyAcceptedVersions", accepted)
+}
+
+// replacePart returns the key with its part i, of version.id.meta.signature, replaced.
+func replacePart(key string, i int, part string) string {
+	parts := strings.Split(key, ".")
+	parts[i] = part
+	return strings.Join(parts, ".")
+}
+
+func TestDecodeKey(t *testing.T) {
+
+	setKeyEnv(t, "v1", "")
+	creds, err := CreateWithOutSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigEncoded := strings.Split(creds.Key, ".")[3]
+
+	sig, _ := base64.URLEncoding.DecodeString(sigEncoded)
+	sig[0] ^= 1
+	flipped := base64.URLEncoding.EncodeToString(sig)
+
+	tests := []struct {
+		name string
+		key string
+		want error
+	}{
+		{name: "round trip", key: creds.Key},
+		{name: "empty", key: "", want: ErrMalformedKey},
+		{name: "three parts", key: strings.Join(strings.Split(creds.Key, ".")[:3], "."), want: ErrMalformedKey},
+		{name: "five parts", key: creds.Key + ".extra", want: ErrMalformedKey},
+		{name: "empty part", key: replacePart(creds.Key, 1, ""), want: ErrMalformedKey},
+		{name: "signature not base64", key: replacePart(creds.Key, 3, "!!not base64!!"), want: ErrMalformedKey},
+		{name: "flipped signature byte", key: replacePart(creds.Key, 3, flipped), want: ErrInvalidSignature},
+		{name: "other id", key: replacePart(creds.Key, 1, "other"), want: ErrInvalidSignature},
+		{name: "unaccepted version", key: replacePart(creds.Key, 0, "v0"), want: ErrUnknownVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			decoded, err := DecodeKey(tt.key)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if tt.want != nil {
+				return
+			}
+			if decoded.Version != "v1" || decoded.ID != creds.ID || decoded.Metadata.CreatedAt == 0 {
+				t.Fatalf("decoded = %+v", decoded)
+			}
+		})
+	}
+}
+
+func TestDecodeKeyAcceptedVersions(t *testing.T) {
+
+	setKeyEnv(t, "v1", "")
+	old, err := CreateWithOutSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setKeyEnv(t, "v2", "")
+	_, err = DecodeKey(old.Key)
+	if !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("key of a retired version : %v, want ErrUnknownVersion", err)
+	}
+
+	setKeyEnv(t, "v2", "v0,v1")
+	decoded, err := DecodeKey(old.Key)
+	if err != nil || decoded.Version != "v1" {
+		t.Fatalf("key of an accepted version = %+v, %v", decoded, err)
+	}
+}
+
+func TestRecreateFromOld(t *testing.T) {
+
+	setKeyEnv(t, "v1", "")
+	old, err := CreateWithOutSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// renewed under the version of the old key, not the current one
+	setKeyEnv(t, "v2", "v1")
+	renewed, err := RecreateFromOld(old.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.ID != old.ID || !strings.HasPrefix(renewed.Key, "v1.") {
+		t.Fatalf("renewed = %+v, want the same id under v1", renewed)
+	}
+	_, err = DecodeKey(renewed.Key)
+	if err != nil {
+		t.Fatalf("renewed key : %v", err)
+	}
+
+	_, err = RecreateFromOld(replacePart(old.Key, 1, "other"))
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("renewing a forged key : %v, want ErrInvalidSignature", err)
+	}
+}
@@ -0,0 +1,26 @@
+package apikeys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// KeyPrefixLength is the number of leading characters of a key that are stored in plaintext,
+// enough for a user to recognise their key in the dashboard but useless as a credential.
+const KeyPrefixLength = 16
+
+// HashKey returns the hex encoded sha256 digest of the key, this is what gets stored and looked up in the db.
+// Keys carry 64 random bytes so a plain fast hash is enough, no salt or stretching is needed.
+// Must stay in sync with encode(sha256(key::bytea), 'hex') used by the migrations.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyPrefix returns the visible prefix of the key.
+func KeyPrefix(key string) string {
+	if len(key) <= KeyPrefixLength {
+		return key
+	}
+	return key[:KeyPrefixLength]
+}
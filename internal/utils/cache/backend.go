@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("cache key not found")
+	ErrExists = errors.New("cache key already exists")
+	ErrTooLarge = errors.New("cache entry is larger than the backend capacity")
+	ErrNotSupported = errors.New("operation is not supported by the cache backend")
+	ErrNotInteger = errors.New("cache value is not an integer or would overflow")
+	ErrConflict = errors.New("cache value has changed")
+	ErrFull = errors.New("cache namespace has reached its max key count")
+	ErrBadCursor = errors.New("cache scan cursor is invalid")
+)
+
+// Backend names, selected with the CacheBackend env.
+const (
+	BackendRemote = "remote"
+	BackendMemory = "memory"
+	BackendRedis = "redis"
+)
+
+// NoExpiry is returned by TTL for keys that never expire.
+const NoExpiry time.Duration = -1
+
+// ETag identifies a version of a value, it is the hex sha1 of the value so that
+// backends can compute it next to the data, redis does so with redis.sha1hex.
+func ETag(value []byte) string {
+	sum := sha1.Sum(value)
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyInfo describes a key listed by Scan.
+type KeyInfo struct {
+	Key string
+	TTL time.Duration // NoExpiry for keys that never expire
+	Size int64 // bytes of the value as stored
+}
+
+// SetMode controls whether Set may create a key, replace it, or both.
+type SetMode int
+
+const (
+	SetAlways SetMode = iota // create or replace
+	SetIfNotExists // create only, fails with ErrExists
+	SetIfExists // replace only, fails with ErrNotFound
+)
+
+// EvictionPolicy decides what happens to a put of a new key in a namespace that is full.
+type EvictionPolicy string
+
+const (
+	EvictNone EvictionPolicy = "noeviction" // the put fails with ErrFull
+	EvictLRU EvictionPolicy = "lru" // the least recently used key of the namespace is dropped
+)
+
+// Namespace scopes keys so that the same key of two namespaces never collides.
+// The default cache database of a user is the namespace of its user_uiid, named databases get their own.
+type Namespace struct {
+	ID string
+	MaxKeys int64 // 0 for no limit
+	Eviction EvictionPolicy // only used with MaxKeys
+}
+
+// Limited reports whether the namespace has a max key count, only those namespaces track their keys.
+func (ns Namespace) Limited() bool {
+	return ns.MaxKeys > 0
+}
+
+// CacheBackend stores the cache entries of every user, scoped by namespace.
+// A ttl of 0 keeps the entry until it is deleted or evicted.
+type CacheBackend interface {
+	// Get returns the value, or ErrNotFound if the key does not exist or has expired.
+	Get(ctx context.Context, ns Namespace, key string) ([]byte, error)
+	// Set writes the value as allowed by mode, reporting whether the key was created rather than replaced.
+	// The tags of the key are replaced by tags, nil removes them.
+	// Creating a key in a full namespace evicts or fails with ErrFull, as set by its policy.
+	Set(ctx context.Context, ns Namespace, key string, value []byte, ttl time.Duration, mode SetMode, tags []string) (bool, error)
+	// Delete removes the key, reporting whether it existed.
+	Delete(ctx context.Context, ns Namespace, key string) (bool, error)
+	// Exists reports whether the key exists and has not expired.
+	Exists(ctx context.Context, ns Namespace, key string) (bool, error)
+	// TTL returns the remaining time to live of the key, NoExpiry if it never expires.
+	TTL(ctx context.Context, ns Namespace, key string) (time.Duration, error)
+	// Expire changes the time to live of an existing key, a ttl of 0 removes the expiry.
+	Expire(ctx context.Context, ns Namespace, key string, ttl time.Duration) error
+
+	// GetMany returns the values of the keys in order, with nil for the ones that do not exist.
+	GetMany(ctx context.Context, ns Namespace, keys []string) ([][]byte, error)
+	// DeleteMany removes the keys, reporting in order whether each one existed.
+	DeleteMany(ctx context.Context, ns Namespace, keys []string) ([]bool, error)
+
+	// Incr atomically adds delta to the integer stored at the key and returns the new value.
+	// A missing key is created as delta with the ttl and no tags, an existing key keeps its ttl and tags.
+	// Fails with ErrNotInteger if the value is not an integer or the result would overflow.
+	Incr(ctx context.Context, ns Namespace, key string, delta int64, ttl time.Duration) (int64, error)
+	// CompareAndSwap replaces the value only if the current one still has the etag, failing with ErrConflict otherwise.
+	// A ttl of 0 keeps the current ttl of the key, its tags are kept.
+	CompareAndSwap(ctx context.Context, ns Namespace, key string, etag string, value []byte, ttl time.Duration) error
+
+	// Flush removes every key of the namespace, returning how many were removed.
+	Flush(ctx context.Context, ns Namespace) (int64, error)
+
+	// Invalidate removes every key of the namespace carrying any of the tags, returning how many were removed.
+	Invalidate(ctx context.Context, ns Namespace, tags []string) (int64, error)
+
+	// Scan lists a page of about limit keys of the namespace starting with the prefix, from the cursor, "" for the first page.
+	// Returns the cursor of the next page, "" once all keys were listed. Keys written meanwhile may or may not be listed.
+	// A limit below 1 is taken as 1.
+	Scan(ctx context.Context, ns Namespace, prefix string, cursor string, limit int) ([]KeyInfo, string, error)
+
+	// DeletePrefix removes every key of the namespace starting with the prefix, returning how many were removed.
+	DeletePrefix(ctx context.Context, ns Namespace, prefix string) (int64, error)
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// testBackends are the backends that every backend test runs against.
+func testBackends(t *testing.T) map[string]CacheBackend {
+
+	redisBackend, _ := newTestRedis(t)
+	return map[string]CacheBackend{
+		"memory": NewMemoryBackend(1 << 20),
+		"redis": redisBackend,
+	}
+}
+
+func TestConcurrentIncr(t *testing.T) {
+
+	const workers, increments = 20, 50
+	ctx := context.Background()
+
+	for name, b := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+
+			var wg sync.WaitGroup
+			errc := make(chan error, workers)
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < increments; j++ {
+						_, err := b.Incr(ctx, testNamespace, "counter", 1, 0)
+						if err != nil {
+							errc <- err
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errc)
+			for err := range errc {
+				t.Fatal(err)
+			}
+
+			value, err := b.Get(ctx, testNamespace, "counter")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(value) != strconv.Itoa(workers * increments) {
+				t.Fatalf("counter = %s, want %d", value, workers * increments)
+			}
+		})
+	}
+}
+
+func TestConcurrentCompareAndSwap(t *testing.T) {
+
+	const workers = 20
+	ctx := context.Background()
+
+	for name, b := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+
+			_, err := b.Set(ctx, testNamespace, "key", []byte("initial"), 0, SetAlways, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			etag := ETag([]byte("initial"))
+
+			// every worker read the same value, only the first swap may win
+			var wg sync.WaitGroup
+			results := make(chan error, workers)
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results <- b.CompareAndSwap(ctx, testNamespace, "key", etag, []byte("worker " + strconv.Itoa(i)), 0)
+				}()
+			}
+			wg.Wait()
+			close(results)
+
+			wins := 0
+			for err := range results {
+				switch {
+				case err == nil:
+					wins++
+				case !errors.Is(err, ErrConflict):
+					t.Fatalf("swap : %v, want nil or ErrConflict", err)
+				}
+			}
+			if wins != 1 {
+				t.Fatalf("%d swaps won, want exactly 1", wins)
+			}
+		})
+	}
+}
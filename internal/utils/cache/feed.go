@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Change types of the events of a Feed.
+const (
+	EventSet = "set" // put, incremented or swapped
+	EventDelete = "delete"
+	EventExpire = "expire" // ttl changed
+	EventFlush = "flush" // keys removed in bulk, Key is the prefix they start with, empty when any key may be gone
+)
+
+// Event is a change of a key of a namespace.
+type Event struct {
+	ID uint64
+	Namespace string
+	Type string
+	Key string
+}
+
+// Feed fans the changes of keys out to their watchers and keeps the last events of all namespaces in a backlog
+// to resume from. A watcher that does not keep up is dropped rather than blocking the writers, it can then
+// resume with the ID of the last event it got as long as the backlog still holds the events after it.
+// Only the changes published in this process are seen, watchers of a server do not see writes made through another.
+// Event IDs start from the boot time in microseconds, so the IDs of a previous run are never taken as recent.
+type Feed struct {
+	mu sync.Mutex
+	nextID uint64
+	backlog []Event // ring buffer, oldest at start
+	start int
+	count int
+	watchers map[string]map[*Watcher]struct{} // by namespace
+	bufferSize int
+}
+
+// Watcher receives the events of a namespace that match its filter, until it is closed or dropped.
+type Watcher struct {
+	Events <-chan Event // closed once the watcher is closed or dropped for falling behind
+
+	feed *Feed
+	namespace string
+	match func(key string) bool
+	events chan Event
+	closed bool // guarded by feed.mu
+}
+
+// NewFeed keeps the last backlogSize events, and up to bufferSize events waiting to be sent for each watcher.
+func NewFeed(backlogSize int, bufferSize int) *Feed {
+	return &Feed{
+		nextID: uint64(time.Now().UnixMicro()),
+		backlog: make([]Event, backlogSize),
+		watchers: make(map[string]map[*Watcher]struct{}),
+		bufferSize: bufferSize,
+	}
+}
+
+// Publish records the change and sends it to the watchers of the namespace, it never blocks on them.
+func (f *Feed) Publish(namespace string, eventType string, key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	event := Event{
+		ID: f.nextID,
+		Namespace: namespace,
+		Type: eventType,
+		Key: key,
+	}
+	f.nextID++
+
+	if len(f.backlog) > 0 {
+		if f.count < len(f.backlog) {
+			f.backlog[(f.start + f.count) % len(f.backlog)] = event
+			f.count++
+		} else {
+			f.backlog[f.start] = event
+			f.start = (f.start + 1) % len(f.backlog)
+		}
+	}
+
+	for watcher := range f.watchers[namespace] {
+		if !accepts(watcher.match, event) {
+			continue
+		}
+		select {
+		case watcher.events <- event:
+		default:
+			f.drop(watcher)
+		}
+	}
+}
+
+// Watch registers a watcher of the keys of the namespace accepted by match.
+// To resume, set resume with the ID of the last event received, the events after it still in the backlog are returned
+// and complete reports whether that is all of them, otherwise some were missed.
+func (f *Feed) Watch(namespace string, match func(key string) bool, resume bool, lastID uint64) (*Watcher, []Event, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var missed []Event
+	complete := true
+	if resume {
+		oldest := f.nextID
+		if f.count > 0 {
+			oldest = f.backlog[f.start].ID
+		}
+		complete = lastID + 1 >= oldest && lastID < f.nextID
+
+		for i := 0; i < f.count; i++ {
+			event := f.backlog[(f.start + i) % len(f.backlog)]
+			if event.ID > lastID && event.Namespace == namespace && accepts(match, event) {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	events := make(chan Event, f.bufferSize)
+	watcher := &Watcher{
+		Events: events,
+		feed: f,
+		namespace: namespace,
+		match: match,
+		events: events,
+	}
+	if f.watchers[namespace] == nil {
+		f.watchers[namespace] = make(map[*Watcher]struct{})
+	}
+	f.watchers[namespace][watcher] = struct{}{}
+
+	return watcher, missed, complete
+}
+
+// accepts reports whether the event goes to a watcher with the filter, flushes go to all of them.
+func accepts(match func(key string) bool, event Event) bool {
+	return event.Type == EventFlush || match(event.Key)
+}
+
+// Close stops the watcher, it is safe to call after it was dropped.
+func (w *Watcher) Close() {
+	w.feed.mu.Lock()
+	defer w.feed.mu.Unlock()
+
+	w.feed.drop(w)
+}
+
+// drop unregisters the watcher and closes its channel. Must hold mu.
+func (f *Feed) drop(watcher *Watcher) {
+
+	if watcher.closed {
+		return
+	}
+	watcher.closed = true
+	close(watcher.events)
+
+	delete(f.watchers[watcher.namespace], watcher)
+	if len(f.watchers[watcher.namespace]) == 0 {
+		delete(f.watchers, watcher.namespace)
+	}
+}
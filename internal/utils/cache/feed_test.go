@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func matchAll(key string) bool {
+	return true
+}
+
+// publishKeys publishes a set of each key on the namespace, returning the IDs the feed gave them.
+func publishKeys(t *testing.T, f *Feed, namespace string, keys ...string) []uint64 {
+
+	watcher, _, _ := f.Watch(namespace, matchAll, false, 0)
+	defer watcher.Close()
+
+	ids := make([]uint64, len(keys))
+	for i, key := range keys {
+		f.Publish(namespace, EventSet, key)
+		ids[i] = (<-watcher.Events).ID
+	}
+	return ids
+}
+
+func eventKeys(events []Event) string {
+
+	keys := make([]string, len(events))
+	for i, event := range events {
+		keys[i] = event.Key
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestFeedResumeInsideBacklog(t *testing.T) {
+
+	f := NewFeed(10, 10)
+	ids := publishKeys(t, f, "ns", "a", "b", "skip", "c")
+	publishKeys(t, f, "other", "d")
+
+	match := func(key string) bool {
+		return key != "skip"
+	}
+	watcher, missed, complete := f.Watch("ns", match, true, ids[0])
+	defer watcher.Close()
+
+	if !complete {
+		t.Fatal("resume inside the backlog reported missed events")
+	}
+	if eventKeys(missed) != "b,c" {
+		t.Fatalf("missed = %s, want b,c", eventKeys(missed))
+	}
+
+	// then live events follow, after the resumed ones
+	f.Publish("ns", EventDelete, "e")
+	event := <-watcher.Events
+	if event.Key != "e" || event.Type != EventDelete || event.ID <= missed[len(missed)-1].ID {
+		t.Fatalf("live event = %+v", event)
+	}
+
+	// resuming from the last event misses nothing and gets nothing
+	watcher, missed, complete = f.Watch("ns", matchAll, true, event.ID)
+	defer watcher.Close()
+	if !complete || len(missed) != 0 {
+		t.Fatalf("resume from the last event = %v, %v", missed, complete)
+	}
+}
+
+func TestFeedResumeBeforeBacklog(t *testing.T) {
+
+	f := NewFeed(3, 10)
+	ids := publishKeys(t, f, "ns", "a", "b", "c", "d", "e")
+
+	// b was pushed out of the backlog, so what follows a is incomplete
+	watcher, missed, complete := f.Watch("ns", matchAll, true, ids[0])
+	defer watcher.Close()
+	if complete {
+		t.Fatal("resume from before the backlog reported no missed events")
+	}
+	if eventKeys(missed) != "c,d,e" {
+		t.Fatalf("missed = %s, want the backlog c,d,e", eventKeys(missed))
+	}
+
+	// the oldest event of the backlog follows b, so nothing is missed from there
+	watcher, missed, complete = f.Watch("ns", matchAll, true, ids[1])
+	defer watcher.Close()
+	if !complete || eventKeys(missed) != "c,d,e" {
+		t.Fatalf("resume from the event before the backlog = %s, %v", eventKeys(missed), complete)
+	}
+
+	// an ID the feed never gave, like one of a previous run, is not trusted
+	watcher, _, complete = f.Watch("ns", matchAll, true, ids[4] + 100)
+	defer watcher.Close()
+	if complete {
+		t.Fatal("resume from an unknown ID reported no missed events")
+	}
+}
+
+func TestFeedDropsSlowWatcher(t *testing.T) {
+
+	f := NewFeed(10, 2)
+	slow, _, _ := f.Watch("ns", matchAll, false, 0)
+	fast, _, _ := f.Watch("ns", matchAll, false, 0)
+	defer fast.Close()
+
+	// the writer must not wait on the slow watcher, which never reads, while the fast one keeps up
+	published := make(chan int)
+	go func() {
+		received := 0
+		for i := 0; i < 5; i++ {
+			f.Publish("ns", EventSet, "key")
+			if _, ok := <-fast.Events; ok {
+				received++
+			}
+		}
+		published <- received
+	}()
+
+	select {
+	case received := <-published:
+		if received != 5 {
+			t.Fatalf("fast watcher got %d events, want 5", received)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("publishing blocked on a slow watcher")
+	}
+
+	// the slow watcher keeps what was buffered, then its channel is closed
+	buffered := 0
+	for range slow.Events {
+		buffered++
+	}
+	if buffered != 2 {
+		t.Fatalf("slow watcher got %d events, want the 2 buffered", buffered)
+	}
+	slow.Close()
+}
+
+func TestFeedFlushReachesFilteredWatchers(t *testing.T) {
+
+	f := NewFeed(10, 10)
+	ids := publishKeys(t, f, "ns", "a")
+
+	match := func(key string) bool {
+		return strings.HasPrefix(key, "user:")
+	}
+	watcher, _, _ := f.Watch("ns", match, false, 0)
+	defer watcher.Close()
+
+	// keys removed in bulk may include watched ones whatever the prefix of the flush
+	f.Publish("ns", EventFlush, "session:")
+	event := <-watcher.Events
+	if event.Type != EventFlush || event.Key != "session:" {
+		t.Fatalf("event = %+v, want the flush", event)
+	}
+
+	resumed, missed, _ := f.Watch("ns", match, true, ids[0])
+	defer resumed.Close()
+	if len(missed) != 1 || missed[0].Type != EventFlush {
+		t.Fatalf("missed = %+v, want the flush", missed)
+	}
+}
@@ -0,0 +1,553 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// entryOverhead roughly accounts for the bookkeeping of an entry on top of its key and value.
+const entryOverhead = 96
+
+type entryKey struct {
+	namespace string
+	key string
+}
+
+type tagKey struct {
+	namespace string
+	tag string
+}
+
+type memoryEntry struct {
+	id entryKey
+	value []byte
+	expiresAt time.Time // zero for entries without a ttl
+	size int64
+	tags []string
+
+	nsElem *list.Element // element of the entry in the list of its namespace
+}
+
+// entrySize is the memory accounted for an entry.
+func entrySize(id entryKey, value []byte, tags []string) int64 {
+
+	size := int64(len(id.namespace) + len(id.key) + len(value) + entryOverhead)
+	for _, tag := range tags {
+		size += int64(len(tag))
+	}
+	return size
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// memoryNamespace holds the entries of a namespace in lru order, and their keys in sorted order for Scan.
+type memoryNamespace struct {
+	lru *list.List // same order as the lru of the backend
+	keys []string // sorted
+}
+
+func (n *memoryNamespace) addKey(key string) {
+	i := sort.SearchStrings(n.keys, key)
+	n.keys = slices.Insert(n.keys, i, key)
+}
+
+func (n *memoryNamespace) removeKey(key string) {
+	i := sort.SearchStrings(n.keys, key)
+	if i < len(n.keys) && n.keys[i] == key {
+		n.keys = slices.Delete(n.keys, i, i+1)
+	}
+}
+
+// MemoryBackend keeps entries in process. Expired entries are dropped when they are next touched,
+// and once the entries take more than maxBytes the least recently used ones are evicted.
+// Untouched expired entries sink to the back of the list, so they are the first to go.
+// Each namespace also keeps its entries in their own lru list, to count them and evict within the namespace,
+// and its keys sorted, so that a page of Scan costs a binary search instead of sorting the whole namespace.
+// The keys carrying each tag are indexed as entries are stored and removed.
+type MemoryBackend struct {
+	mu sync.Mutex
+	entries map[entryKey]*list.Element
+	lru *list.List // front is the most recently used
+	namespaces map[string]*memoryNamespace
+	tagged map[tagKey]map[string]struct{} // keys of the namespace carrying the tag
+	usedBytes int64
+	maxBytes int64
+}
+
+func NewMemoryBackend(maxBytes int64) *MemoryBackend {
+	return &MemoryBackend{
+		entries: make(map[entryKey]*list.Element),
+		lru: list.New(),
+		namespaces: make(map[string]*memoryNamespace),
+		tagged: make(map[tagKey]map[string]struct{}),
+		maxBytes: maxBytes,
+	}
+}
+
+// lookup returns the live entry for the key, dropping it if it has expired. Must hold mu.
+func (b *MemoryBackend) lookup(id entryKey, now time.Time) *memoryEntry {
+
+	elem, found := b.entries[id]
+	if !found {
+		return nil
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if entry.expired(now) {
+		b.remove(elem)
+		return nil
+	}
+	return entry
+}
+
+// touch marks the entry as the most recently used one. Must hold mu.
+func (b *MemoryBackend) touch(id entryKey) {
+
+	elem := b.entries[id]
+	b.lru.MoveToFront(elem)
+	b.namespaces[id.namespace].lru.MoveToFront(elem.Value.(*memoryEntry).nsElem)
+}
+
+// remove drops the entry from the map and the lists. Must hold mu.
+func (b *MemoryBackend) remove(elem *list.Element) {
+
+	entry := elem.Value.(*memoryEntry)
+	b.lru.Remove(elem)
+	delete(b.entries, entry.id)
+	b.usedBytes -= entry.size
+
+	namespace := b.namespaces[entry.id.namespace]
+	namespace.lru.Remove(entry.nsElem)
+	namespace.removeKey(entry.id.key)
+	if namespace.lru.Len() == 0 {
+		delete(b.namespaces, entry.id.namespace)
+	}
+
+	for _, tag := range entry.tags {
+		id := tagKey{entry.id.namespace, tag}
+		delete(b.tagged[id], entry.id.key)
+		if len(b.tagged[id]) == 0 {
+			delete(b.tagged, id)
+		}
+	}
+}
+
+// store pushes the entry as the most recently used one, replacing the entry of the same key if any. Must hold mu.
+func (b *MemoryBackend) store(entry *memoryEntry) {
+
+	if elem, found := b.entries[entry.id]; found {
+		b.remove(elem)
+	}
+
+	namespace, found := b.namespaces[entry.id.namespace]
+	if !found {
+		namespace = &memoryNamespace{lru: list.New()}
+		b.namespaces[entry.id.namespace] = namespace
+	}
+	entry.nsElem = namespace.lru.PushFront(entry)
+	namespace.addKey(entry.id.key)
+
+	for _, tag := range entry.tags {
+		id := tagKey{entry.id.namespace, tag}
+		if b.tagged[id] == nil {
+			b.tagged[id] = make(map[string]struct{})
+		}
+		b.tagged[id][entry.id.key] = struct{}{}
+	}
+
+	b.entries[entry.id] = b.lru.PushFront(entry)
+	b.usedBytes += entry.size
+	b.evict()
+}
+
+// evict drops least recently used entries until the used memory fits. Must hold mu.
+func (b *MemoryBackend) evict() {
+
+	for b.usedBytes > b.maxBytes {
+		back := b.lru.Back()
+		if back == nil {
+			return
+		}
+		b.remove(back)
+	}
+}
+
+// admit makes room for a new key in a limited namespace, as allowed by its eviction policy. Must hold mu.
+func (b *MemoryBackend) admit(ns Namespace, now time.Time) error {
+
+	namespace := b.namespaces[ns.ID]
+	if !ns.Limited() || namespace == nil || int64(namespace.lru.Len()) < ns.MaxKeys {
+		return nil
+	}
+
+	// expired entries still count until touched, drop them first
+	for elem := namespace.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		entry := elem.Value.(*memoryEntry)
+		if entry.expired(now) {
+			b.remove(b.entries[entry.id])
+		}
+		elem = prev
+	}
+
+	for b.namespaces[ns.ID] != nil && int64(b.namespaces[ns.ID].lru.Len()) >= ns.MaxKeys {
+		if ns.Eviction != EvictLRU {
+			return ErrFull
+		}
+		oldest := b.namespaces[ns.ID].lru.Back().Value.(*memoryEntry)
+		b.remove(b.entries[oldest.id])
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Get(ctx context.Context, ns Namespace, key string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := entryKey{ns.ID, key}
+	entry := b.lookup(id, time.Now())
+	if entry == nil {
+		return nil, ErrNotFound
+	}
+	b.touch(id)
+
+	value := make([]byte, len(entry.value))
+	copy(value, entry.value)
+	return value, nil
+}
+
+func (b *MemoryBackend) Set(ctx context.Context, ns Namespace, key string, value []byte, ttl time.Duration, mode SetMode, tags []string) (bool, error) {
+
+	id := entryKey{ns.ID, key}
+	size := entrySize(id, value, tags)
+	if size > b.maxBytes {
+		return false, ErrTooLarge
+	}
+
+	entry := &memoryEntry{
+		id: id,
+		value: make([]byte, len(value)),
+		size: size,
+		tags: append([]string(nil), tags...),
+	}
+	copy(entry.value, value)
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	existing := b.lookup(entry.id, now)
+	if existing != nil && mode == SetIfNotExists {
+		return false, ErrExists
+	}
+	if existing == nil && mode == SetIfExists {
+		return false, ErrNotFound
+	}
+
+	if existing == nil {
+		err := b.admit(ns, now)
+		if err != nil {
+			return false, err
+		}
+	}
+	b.store(entry)
+
+	return existing == nil, nil
+}
+
+func (b *MemoryBackend) Delete(ctx context.Context, ns Namespace, key string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := entryKey{ns.ID, key}
+	if b.lookup(id, time.Now()) == nil {
+		return false, nil
+	}
+	b.remove(b.entries[id])
+	return true, nil
+}
+
+func (b *MemoryBackend) Exists(ctx context.Context, ns Namespace, key string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.lookup(entryKey{ns.ID, key}, time.Now()) != nil, nil
+}
+
+func (b *MemoryBackend) TTL(ctx context.Context, ns Namespace, key string) (time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	entry := b.lookup(entryKey{ns.ID, key}, now)
+	if entry == nil {
+		return 0, ErrNotFound
+	}
+	if entry.expiresAt.IsZero() {
+		return NoExpiry, nil
+	}
+	return entry.expiresAt.Sub(now), nil
+}
+
+func (b *MemoryBackend) Expire(ctx context.Context, ns Namespace, key string, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	entry := b.lookup(entryKey{ns.ID, key}, now)
+	if entry == nil {
+		return ErrNotFound
+	}
+
+	entry.expiresAt = time.Time{}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) GetMany(ctx context.Context, ns Namespace, keys []string) ([][]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		id := entryKey{ns.ID, key}
+		entry := b.lookup(id, now)
+		if entry == nil {
+			continue
+		}
+		b.touch(id)
+
+		values[i] = make([]byte, len(entry.value))
+		copy(values[i], entry.value)
+	}
+	return values, nil
+}
+
+func (b *MemoryBackend) DeleteMany(ctx context.Context, ns Namespace, keys []string) ([]bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	deleted := make([]bool, len(keys))
+	for i, key := range keys {
+		id := entryKey{ns.ID, key}
+		if b.lookup(id, now) == nil {
+			continue
+		}
+		b.remove(b.entries[id])
+		deleted[i] = true
+	}
+	return deleted, nil
+}
+
+func (b *MemoryBackend) Incr(ctx context.Context, ns Namespace, key string, delta int64, ttl time.Duration) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	id := entryKey{ns.ID, key}
+
+	value := delta
+	expiresAt := time.Time{}
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+	var tags []string
+
+	existing := b.lookup(id, now)
+	if existing != nil {
+		current, err := strconv.ParseInt(string(existing.value), 10, 64)
+		if err != nil {
+			return 0, ErrNotInteger
+		}
+		if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+			return 0, ErrNotInteger
+		}
+		value = current + delta
+		expiresAt = existing.expiresAt
+		tags = existing.tags
+	} else {
+		err := b.admit(ns, now)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	encoded := []byte(strconv.FormatInt(value, 10))
+	b.store(&memoryEntry{
+		id: id,
+		value: encoded,
+		expiresAt: expiresAt,
+		size: entrySize(id, encoded, tags),
+		tags: tags,
+	})
+
+	return value, nil
+}
+
+func (b *MemoryBackend) CompareAndSwap(ctx context.Context, ns Namespace, key string, etag string, value []byte, ttl time.Duration) error {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	id := entryKey{ns.ID, key}
+	existing := b.lookup(id, now)
+	if existing == nil {
+		return ErrNotFound
+	}
+	if ETag(existing.value) != etag {
+		return ErrConflict
+	}
+	size := entrySize(id, value, existing.tags)
+	if size > b.maxBytes {
+		return ErrTooLarge
+	}
+
+	entry := &memoryEntry{
+		id: id,
+		value: make([]byte, len(value)),
+		expiresAt: existing.expiresAt,
+		size: size,
+		tags: existing.tags,
+	}
+	copy(entry.value, value)
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+
+	b.store(entry)
+	return nil
+}
+
+func (b *MemoryBackend) Flush(ctx context.Context, ns Namespace) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	namespace := b.namespaces[ns.ID]
+	if namespace == nil {
+		return 0, nil
+	}
+
+	now := time.Now()
+	var removed int64
+	for namespace.lru.Len() > 0 {
+		entry := namespace.lru.Front().Value.(*memoryEntry)
+		if !entry.expired(now) {
+			removed++
+		}
+		b.remove(b.entries[entry.id])
+	}
+	return removed, nil
+}
+
+func (b *MemoryBackend) Invalidate(ctx context.Context, ns Namespace, tags []string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	var removed int64
+	for _, tag := range tags {
+		for key := range b.tagged[tagKey{ns.ID, tag}] {
+			elem := b.entries[entryKey{ns.ID, key}]
+			if !elem.Value.(*memoryEntry).expired(now) {
+				removed++
+			}
+			b.remove(elem)
+		}
+	}
+	return removed, nil
+}
+
+// Scan pages through the keys in order, the cursor is the last key of the previous page.
+func (b *MemoryBackend) Scan(ctx context.Context, ns Namespace, prefix string, cursor string, limit int) ([]KeyInfo, string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	limit = max(limit, 1)
+	namespace := b.namespaces[ns.ID]
+	if namespace == nil {
+		return nil, "", nil
+	}
+
+	// the first key after the cursor, and not before the prefix
+	start := sort.SearchStrings(namespace.keys, prefix)
+	if cursor != "" {
+		start = max(start, sort.Search(len(namespace.keys), func(i int) bool {
+			return namespace.keys[i] > cursor
+		}))
+	}
+
+	now := time.Now()
+	keys := make([]KeyInfo, 0, limit)
+	next := ""
+	for _, key := range namespace.keys[start:] {
+		if !strings.HasPrefix(key, prefix) {
+			break
+		}
+		entry := b.entries[entryKey{ns.ID, key}].Value.(*memoryEntry)
+		if entry.expired(now) {
+			continue
+		}
+		// one more live key, so there is a next page
+		if len(keys) == limit {
+			next = keys[limit-1].Key
+			break
+		}
+
+		info := KeyInfo{
+			Key: key,
+			TTL: NoExpiry,
+			Size: int64(len(entry.value)),
+		}
+		if !entry.expiresAt.IsZero() {
+			info.TTL = entry.expiresAt.Sub(now)
+		}
+		keys = append(keys, info)
+	}
+	return keys, next, nil
+}
+
+func (b *MemoryBackend) DeletePrefix(ctx context.Context, ns Namespace, prefix string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	namespace := b.namespaces[ns.ID]
+	if namespace == nil {
+		return 0, nil
+	}
+
+	// the matching keys are one run of the sorted keys, copied as removing shrinks the slice
+	start := sort.SearchStrings(namespace.keys, prefix)
+	end := start
+	for end < len(namespace.keys) && strings.HasPrefix(namespace.keys[end], prefix) {
+		end++
+	}
+	matched := slices.Clone(namespace.keys[start:end])
+
+	now := time.Now()
+	var removed int64
+	for _, key := range matched {
+		elem := b.entries[entryKey{ns.ID, key}]
+		if !elem.Value.(*memoryEntry).expired(now) {
+			removed++
+		}
+		b.remove(elem)
+	}
+	return removed, nil
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testNamespace = Namespace{ID: "ns"}
+
+func TestMemoryTTL(t *testing.T) {
+
+	ctx := context.Background()
+	b := NewMemoryBackend(1 << 20)
+
+	_, err := b.Set(ctx, testNamespace, "short", []byte("v"), 20*time.Millisecond, SetAlways, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.Set(ctx, testNamespace, "forever", []byte("v"), 0, SetAlways, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ttl, err := b.TTL(ctx, testNamespace, "short")
+	if err != nil || ttl <= 0 || ttl > 20*time.Millisecond {
+		t.Fatalf("ttl = %v, %v, want at most 20ms", ttl, err)
+	}
+	ttl, err = b.TTL(ctx, testNamespace, "forever")
+	if err != nil || ttl != NoExpiry {
+		t.Fatalf("ttl = %v, %v, want NoExpiry", ttl, err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	_, err = b.Get(ctx, testNamespace, "short")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired key : %v, want ErrNotFound", err)
+	}
+	exists, _ := b.Exists(ctx, testNamespace, "short")
+	if exists {
+		t.Fatal("expired key still exists")
+	}
+	_, err = b.Get(ctx, testNamespace, "forever")
+	if err != nil {
+		t.Fatalf("key without ttl : %v", err)
+	}
+
+	// an expired key is created again, not replaced
+	created, err := b.Set(ctx, testNamespace, "short", []byte("v"), 0, SetIfNotExists, nil)
+	if err != nil || !created {
+		t.Fatalf("set over an expired key = %v, %v, want created", created, err)
+	}
+}
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+
+	ctx := context.Background()
+	value := []byte("0123456789")
+	size := entrySize(entryKey{testNamespace.ID, "a"}, value, nil)
+	b := NewMemoryBackend(3 * size)
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, err := b.Set(ctx, testNamespace, key, value, 0, SetAlways, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a becomes the most recently used, so b is the one to go
+	_, err := b.Get(ctx, testNamespace, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.Set(ctx, testNamespace, "d", value, 0, SetAlways, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		exists, _ := b.Exists(ctx, testNamespace, key)
+		if exists != want {
+			t.Fatalf("%s exists = %v, want %v", key, exists, want)
+		}
+	}
+	if b.usedBytes > b.maxBytes {
+		t.Fatalf("used %d bytes, over the bound of %d", b.usedBytes, b.maxBytes)
+	}
+
+	_, err = b.Set(ctx, testNamespace, "huge", make([]byte, 3 * size), 0, SetAlways, nil)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("entry above the bound : %v, want ErrTooLarge", err)
+	}
+}
+
+func TestMemoryScan(t *testing.T) {
+
+	ctx := context.Background()
+	b := NewMemoryBackend(1 << 20)
+
+	var want []string
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		want = append(want, key)
+		_, err := b.Set(ctx, testNamespace, key, []byte("v"), 0, SetAlways, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"a", "l", "k"} {
+		_, err := b.Set(ctx, Namespace{ID: "other"}, "k" + key, []byte("v"), 0, SetAlways, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"j", "l", "kexpired"} {
+		_, err := b.Set(ctx, testNamespace, key, []byte("v"), time.Millisecond, SetAlways, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := b.Set(ctx, testNamespace, "j", []byte("v"), 0, SetAlways, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	var got []string
+	cursor := ""
+	for pages := 1; ; pages++ {
+		keys, next, err := b.Scan(ctx, testNamespace, "k", cursor, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) > 10 {
+			t.Fatalf("page of %d keys, over the limit", len(keys))
+		}
+		for _, key := range keys {
+			got = append(got, key.Key)
+		}
+		if next == "" {
+			if pages != 3 {
+				t.Fatalf("listed in %d pages, want 3", pages)
+			}
+			break
+		}
+		cursor = next
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("scanned %v, want %v", got, want)
+	}
+
+	// a limit below 1 lists a single key instead of failing
+	keys, next, err := b.Scan(ctx, testNamespace, "", "", 0)
+	if err != nil || len(keys) != 1 || keys[0].Key != "j" || next != "j" {
+		t.Fatalf("scan with no limit = %v, %q, %v", keys, next, err)
+	}
+}
@@ -0,0 +1,642 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// flushBatchSize is how many keys are scanned and removed at once when flushing a namespace.
+const flushBatchSize = 500
+
+// trackLua is the head of every script that writes entries. Limited namespaces track their keys in two
+// sorted sets, by last use to evict the least recently used one and by expiry to forget expired keys.
+// KEYS[1] is the entry, KEYS[2] and KEYS[3] the sets by last use and by expiry.
+// ARGV[1] is the key, ARGV[2] the max key count, 0 if the namespace is not limited and nothing is tracked,
+// ARGV[3] the eviction policy, ARGV[4] the current time in milliseconds and ARGV[5] the prefix of the entries.
+// ARGV[6] and ARGV[7] are the prefixes of the sets of tags of each key and of keys of each tag.
+const trackLua = `
+local key, max, policy, now, base = ARGV[1], tonumber(ARGV[2]), ARGV[3], tonumber(ARGV[4]), ARGV[5]
+local tagsBase, tagBase = ARGV[6], ARGV[7]
+
+-- untag drops the tags of the member, it is only removed from the sets of its tags,
+-- the members left there by an expired key are checked against its tags when invalidating
+local function untag(member)
+	local tagsKey = tagsBase .. member
+	for _, tag in ipairs(redis.call('SMEMBERS', tagsKey)) do
+		redis.call('SREM', tagBase .. tag, member)
+	end
+	redis.call('DEL', tagsKey)
+end
+
+-- admit makes room for a new key, false if the namespace is full and may not evict
+local function admit()
+	if max <= 0 then
+		return true
+	end
+	for _, member in ipairs(redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', now)) do
+		redis.call('ZREM', KEYS[2], member)
+		redis.call('ZREM', KEYS[3], member)
+	end
+	while redis.call('ZCARD', KEYS[2]) >= max do
+		if policy ~= 'lru' then
+			return false
+		end
+		local oldest = redis.call('ZRANGE', KEYS[2], 0, 0)[1]
+		redis.call('DEL', base .. oldest)
+		untag(oldest)
+		redis.call('ZREM', KEYS[2], oldest)
+		redis.call('ZREM', KEYS[3], oldest)
+	end
+	return true
+end
+
+-- track marks the key as used, a ttl above 0 sets its expiry, 0 clears it and below 0 keeps it
+local function track(ttl)
+	if max <= 0 then
+		return
+	end
+	redis.call('ZADD', KEYS[2], now, key)
+	if ttl > 0 then
+		redis.call('ZADD', KEYS[3], now + ttl, key)
+	elseif ttl == 0 then
+		redis.call('ZREM', KEYS[3], key)
+	end
+end
+`
+
+// setScript writes ARGV[8] with the ttl ARGV[9], in milliseconds, 0 for none, as allowed by the SetMode ARGV[10].
+// The tags of the key are replaced by the rest of ARGV.
+// Returns 1 if created, 0 if replaced, -1 if it exists for SetIfNotExists, -2 if it does not for SetIfExists
+// and -3 if the namespace is full.
+var setScript = redis.NewScript(trackLua + `
+local value, ttl, mode = ARGV[8], tonumber(ARGV[9]), tonumber(ARGV[10])
+local tags = {unpack(ARGV, 11)}
+local exists = redis.call('EXISTS', KEYS[1]) == 1
+if exists and mode == 1 then
+	return -1
+end
+if not exists and mode == 2 then
+	return -2
+end
+if not exists and not admit() then
+	return -3
+end
+if ttl > 0 then
+	redis.call('SET', KEYS[1], value, 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], value)
+end
+track(ttl)
+untag(key)
+if #tags > 0 then
+	redis.call('SADD', tagsBase .. key, unpack(tags))
+	for _, tag in ipairs(tags) do
+		redis.call('SADD', tagBase .. tag, key)
+	end
+	if ttl > 0 then
+		redis.call('PEXPIRE', tagsBase .. key, ttl)
+	end
+end
+if exists then
+	return 0
+end
+return 1
+`)
+
+// getScript gets the entry of a limited namespace and marks it as used.
+var getScript = redis.NewScript(trackLua + `
+local value = redis.call('GET', KEYS[1])
+if value then
+	track(-1)
+end
+return value
+`)
+
+// incrScript adds ARGV[8] to the key and sets the ttl ARGV[9], in milliseconds, only if the key was created.
+// Returns {1, value}, {0, 0} if the value is not an integer or would overflow, or {-1, 0} if the namespace is full.
+var incrScript = redis.NewScript(trackLua + `
+local delta, ttl = ARGV[8], tonumber(ARGV[9])
+local created = redis.call('EXISTS', KEYS[1]) == 0
+if created and not admit() then
+	return {-1, 0}
+end
+local value = redis.pcall('INCRBY', KEYS[1], delta)
+if type(value) ~= 'number' then
+	return {0, 0}
+end
+if created and ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+if created then
+	track(ttl)
+	untag(key)
+else
+	track(-1)
+end
+return {1, value}
+`)
+
+// casScript replaces the key with ARGV[9] only if the sha1 of its value is ARGV[8], see ETag.
+// ARGV[10] is the new ttl in milliseconds, 0 keeps the current one.
+// Returns -1 if the key does not exist, 0 on a mismatch and 1 once replaced.
+var casScript = redis.NewScript(trackLua + `
+local etag, value, ttl = ARGV[8], ARGV[9], tonumber(ARGV[10])
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return -1
+end
+if redis.sha1hex(current) ~= etag then
+	return 0
+end
+if ttl > 0 then
+	redis.call('SET', KEYS[1], value, 'PX', ttl)
+	redis.call('PEXPIRE', tagsBase .. key, ttl)
+	track(ttl)
+else
+	redis.call('SET', KEYS[1], value, 'KEEPTTL')
+	track(-1)
+end
+return 1
+`)
+
+// invalidateScript removes the keys carrying any of the tags in ARGV[8] onwards, along with the sets of the tags.
+// A member of a tag set whose key no longer carries the tag was deleted or put again meanwhile, so it is kept.
+// Returns how many keys were removed.
+var invalidateScript = redis.NewScript(trackLua + `
+local removed = 0
+for i = 8, #ARGV do
+	local tagKey = tagBase .. ARGV[i]
+	for _, member in ipairs(redis.call('SMEMBERS', tagKey)) do
+		if redis.call('SISMEMBER', tagsBase .. member, ARGV[i]) == 1 then
+			removed = removed + redis.call('DEL', base .. member)
+			untag(member)
+			if max > 0 then
+				redis.call('ZREM', KEYS[2], member)
+				redis.call('ZREM', KEYS[3], member)
+			end
+		end
+	end
+	redis.call('DEL', tagKey)
+end
+return removed
+`)
+
+// RedisBackend stores entries in a single redis server, with native redis ttls.
+// Redis Cluster is not supported: the scripts build the keys of tags and of evicted entries from prefixes
+// instead of receiving them in KEYS, and Scan, Flush and DeletePrefix walk the keys of a single node.
+// Keys are stored as <prefix>:{<namespace>}:<key>.
+// The keys of limited namespaces are also tracked in <prefix>:lru:{<namespace>} and <prefix>:exp:{<namespace>}.
+// The tags of a key are in the set <prefix>:tags:{<namespace>}:<key>, expiring along with the key,
+// and the keys carrying a tag in the set <prefix>:tag:{<namespace>}:<tag>.
+type RedisBackend struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisBackend(client *redis.Client, prefix string) *RedisBackend {
+	return &RedisBackend{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// entryPrefix is the part shared by all the entries of the namespace.
+func (b *RedisBackend) entryPrefix(namespace string) string {
+	return b.prefix + ":{" + namespace + "}:"
+}
+
+func (b *RedisBackend) redisKey(namespace string, key string) string {
+	return b.entryPrefix(namespace) + key
+}
+
+// tagsPrefix is the part shared by the sets of tags of the keys of the namespace.
+func (b *RedisBackend) tagsPrefix(namespace string) string {
+	return b.prefix + ":tags:{" + namespace + "}:"
+}
+
+// tagPrefix is the part shared by the sets of keys of the tags of the namespace.
+func (b *RedisBackend) tagPrefix(namespace string) string {
+	return b.prefix + ":tag:{" + namespace + "}:"
+}
+
+// scriptKeys are the KEYS of the scripts, see trackLua.
+func (b *RedisBackend) scriptKeys(ns Namespace, key string) []string {
+	return []string{
+		b.redisKey(ns.ID, key),
+		b.prefix + ":lru:{" + ns.ID + "}",
+		b.prefix + ":exp:{" + ns.ID + "}",
+	}
+}
+
+// scriptArgs are the ARGV of the scripts, the ones shared by all, see trackLua, followed by args.
+func (b *RedisBackend) scriptArgs(ns Namespace, key string, args ...any) []any {
+	shared := []any{key, ns.MaxKeys, string(ns.Eviction), time.Now().UnixMilli(), b.entryPrefix(ns.ID), b.tagsPrefix(ns.ID), b.tagPrefix(ns.ID)}
+	return append(shared, args...)
+}
+
+// untrack drops the tags of the keys and removes them from the sets of a limited namespace, once they have been deleted.
+// The keys are left in the sets of their tags, invalidating checks them against their tags.
+func (b *RedisBackend) untrack(ctx context.Context, ns Namespace, keys ...string) error {
+
+	members := make([]any, len(keys))
+	tagsKeys := make([]string, len(keys))
+	for i, key := range keys {
+		members[i] = key
+		tagsKeys[i] = b.tagsPrefix(ns.ID) + key
+	}
+
+	scriptKeys := b.scriptKeys(ns, "")
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, tagsKeys...)
+		if ns.Limited() {
+			pipe.ZRem(ctx, scriptKeys[1], members...)
+			pipe.ZRem(ctx, scriptKeys[2], members...)
+		}
+		return nil
+	})
+	return err
+}
+
+func (b *RedisBackend) Get(ctx context.Context, ns Namespace, key string) ([]byte, error) {
+
+	var value string
+	var err error
+	if ns.Limited() {
+		value, err = getScript.Run(ctx, b.client, b.scriptKeys(ns, key), b.scriptArgs(ns, key)...).Text()
+	} else {
+		value, err = b.client.Get(ctx, b.redisKey(ns.ID, key)).Result()
+	}
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+func (b *RedisBackend) Set(ctx context.Context, ns Namespace, key string, value []byte, ttl time.Duration, mode SetMode, tags []string) (bool, error) {
+
+	args := b.scriptArgs(ns, key, value, ttl.Milliseconds(), int(mode))
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+
+	result, err := setScript.Run(ctx, b.client, b.scriptKeys(ns, key), args...).Int()
+	if err != nil {
+		return false, err
+	}
+
+	switch result {
+	case -1:
+		return false, ErrExists
+	case -2:
+		return false, ErrNotFound
+	case -3:
+		return false, ErrFull
+	default:
+		return result == 1, nil
+	}
+}
+
+func (b *RedisBackend) Delete(ctx context.Context, ns Namespace, key string) (bool, error) {
+
+	deleted, err := b.client.Del(ctx, b.redisKey(ns.ID, key)).Result()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, b.untrack(ctx, ns, key)
+}
+
+func (b *RedisBackend) Exists(ctx context.Context, ns Namespace, key string) (bool, error) {
+
+	exists, err := b.client.Exists(ctx, b.redisKey(ns.ID, key)).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists > 0, nil
+}
+
+func (b *RedisBackend) TTL(ctx context.Context, ns Namespace, key string) (time.Duration, error) {
+
+	ttl, err := b.client.PTTL(ctx, b.redisKey(ns.ID, key)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// redis answers -2 for a missing key and -1 for a key without expiry
+	switch ttl {
+	case -2:
+		return 0, ErrNotFound
+	case -1:
+		return NoExpiry, nil
+	default:
+		return ttl, nil
+	}
+}
+
+func (b *RedisBackend) Expire(ctx context.Context, ns Namespace, key string, ttl time.Duration) error {
+
+	redisKey := b.redisKey(ns.ID, key)
+	tagsKey := b.tagsPrefix(ns.ID) + key
+	expKey := b.scriptKeys(ns, key)[2]
+
+	if ttl > 0 {
+		updated, err := b.client.PExpire(ctx, redisKey, ttl).Result()
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return ErrNotFound
+		}
+		err = b.client.PExpire(ctx, tagsKey, ttl).Err()
+		if err != nil {
+			return err
+		}
+		if ns.Limited() {
+			return b.client.ZAdd(ctx, expKey, redis.Z{Score: float64(time.Now().Add(ttl).UnixMilli()), Member: key}).Err()
+		}
+		return nil
+	}
+
+	// persist also answers false for an existing key that had no expiry
+	persisted, err := b.client.Persist(ctx, redisKey).Result()
+	if err != nil {
+		return err
+	}
+	if !persisted {
+		exists, err := b.Exists(ctx, ns, key)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+	}
+	err = b.client.Persist(ctx, tagsKey).Err()
+	if err != nil {
+		return err
+	}
+	if ns.Limited() {
+		return b.client.ZRem(ctx, expKey, key).Err()
+	}
+	return nil
+}
+
+func (b *RedisBackend) GetMany(ctx context.Context, ns Namespace, keys []string) ([][]byte, error) {
+
+	redisKeys := make([]string, len(keys))
+	for i, key := range keys {
+		redisKeys[i] = b.redisKey(ns.ID, key)
+	}
+
+	results, err := b.client.MGet(ctx, redisKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, len(keys))
+	used := make([]redis.Z, 0, len(keys))
+	now := float64(time.Now().UnixMilli())
+	for i, result := range results {
+		if value, ok := result.(string); ok {
+			values[i] = []byte(value)
+			used = append(used, redis.Z{Score: now, Member: keys[i]})
+		}
+	}
+
+	// only marks the keys as used, a key deleted meanwhile is not tracked again thanks to XX
+	if ns.Limited() && len(used) > 0 {
+		err = b.client.ZAddXX(ctx, b.scriptKeys(ns, "")[1], used...).Err()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (b *RedisBackend) DeleteMany(ctx context.Context, ns Namespace, keys []string) ([]bool, error) {
+
+	// one DEL per key in a single round trip, a multi key DEL would only give the total count
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Del(ctx, b.redisKey(ns.ID, key))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]bool, len(keys))
+	for i, cmd := range cmds {
+		deleted[i] = cmd.Val() > 0
+	}
+	return deleted, b.untrack(ctx, ns, keys...)
+}
+
+func (b *RedisBackend) Incr(ctx context.Context, ns Namespace, key string, delta int64, ttl time.Duration) (int64, error) {
+
+	result, err := incrScript.Run(ctx, b.client, b.scriptKeys(ns, key), b.scriptArgs(ns, key, delta, ttl.Milliseconds())...).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+
+	switch result[0] {
+	case -1:
+		return 0, ErrFull
+	case 0:
+		return 0, ErrNotInteger
+	default:
+		return result[1], nil
+	}
+}
+
+func (b *RedisBackend) CompareAndSwap(ctx context.Context, ns Namespace, key string, etag string, value []byte, ttl time.Duration) error {
+
+	swapped, err := casScript.Run(ctx, b.client, b.scriptKeys(ns, key), b.scriptArgs(ns, key, etag, value, ttl.Milliseconds())...).Int()
+	if err != nil {
+		return err
+	}
+
+	switch swapped {
+	case -1:
+		return ErrNotFound
+	case 0:
+		return ErrConflict
+	default:
+		return nil
+	}
+}
+
+// Flush scans the namespace and unlinks its keys batch by batch, then the sets of its tags.
+// It is not atomic so keys written while it runs may be left.
+func (b *RedisBackend) Flush(ctx context.Context, ns Namespace) (int64, error) {
+
+	removed, err := b.unlinkPrefix(ctx, b.entryPrefix(ns.ID), nil)
+	if err != nil {
+		return removed, err
+	}
+
+	for _, prefix := range []string{b.tagsPrefix(ns.ID), b.tagPrefix(ns.ID)} {
+		_, err = b.unlinkPrefix(ctx, prefix, nil)
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	scriptKeys := b.scriptKeys(ns, "")
+	return removed, b.client.Del(ctx, scriptKeys[1], scriptKeys[2]).Err()
+}
+
+// unlinkPrefix scans the keys starting with the prefix and unlinks them batch by batch, returning how many were unlinked.
+// Each batch is then passed to unlinked, if set.
+func (b *RedisBackend) unlinkPrefix(ctx context.Context, prefix string, unlinked func(batch []string) error) (int64, error) {
+
+	var removed int64
+	batch := make([]string, 0, flushBatchSize)
+
+	unlink := func() error {
+		count, err := b.client.Unlink(ctx, batch...).Result()
+		if err != nil {
+			return err
+		}
+		removed += count
+		if unlinked != nil {
+			err = unlinked(batch)
+			if err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	iter := b.client.Scan(ctx, 0, globEscape(prefix) + "*", flushBatchSize).Iterator()
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == flushBatchSize {
+			if err := unlink(); err != nil {
+				return removed, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return removed, err
+	}
+	if len(batch) > 0 {
+		if err := unlink(); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func (b *RedisBackend) Invalidate(ctx context.Context, ns Namespace, tags []string) (int64, error) {
+
+	args := b.scriptArgs(ns, "")
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	return invalidateScript.Run(ctx, b.client, b.scriptKeys(ns, ""), args...).Int64()
+}
+
+// Scan uses the redis SCAN cursor, so a page may hold fewer or a few more keys than the limit.
+func (b *RedisBackend) Scan(ctx context.Context, ns Namespace, prefix string, cursor string, limit int) ([]KeyInfo, string, error) {
+
+	limit = max(limit, 1)
+	var position uint64
+	if cursor != "" {
+		var err error
+		position, err = strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return nil, "", ErrBadCursor
+		}
+	}
+
+	entryPrefix := b.entryPrefix(ns.ID)
+	match := globEscape(entryPrefix + prefix) + "*"
+
+	// a SCAN call may return no key at all, keep going until the page has some or the scan is over
+	var redisKeys []string
+	for {
+		batch, next, err := b.client.Scan(ctx, position, match, int64(limit - len(redisKeys))).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		redisKeys = append(redisKeys, batch...)
+		position = next
+		if position == 0 || len(redisKeys) >= limit {
+			break
+		}
+	}
+
+	ttls := make([]*redis.DurationCmd, len(redisKeys))
+	sizes := make([]*redis.IntCmd, len(redisKeys))
+	_, err := b.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, redisKey := range redisKeys {
+			ttls[i] = pipe.PTTL(ctx, redisKey)
+			sizes[i] = pipe.StrLen(ctx, redisKey)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	keys := make([]KeyInfo, 0, len(redisKeys))
+	for i, redisKey := range redisKeys {
+		ttl := ttls[i].Val()
+		// expired or deleted since it was scanned
+		if ttl == -2 {
+			continue
+		}
+		if ttl == -1 {
+			ttl = NoExpiry
+		}
+		keys = append(keys, KeyInfo{
+			Key: strings.TrimPrefix(redisKey, entryPrefix),
+			TTL: ttl,
+			Size: sizes[i].Val(),
+		})
+	}
+
+	next := ""
+	if position != 0 {
+		next = strconv.FormatUint(position, 10)
+	}
+	return keys, next, nil
+}
+
+// DeletePrefix scans and unlinks the keys batch by batch like Flush, it is not atomic either.
+func (b *RedisBackend) DeletePrefix(ctx context.Context, ns Namespace, prefix string) (int64, error) {
+
+	entryPrefix := b.entryPrefix(ns.ID)
+	return b.unlinkPrefix(ctx, entryPrefix + prefix, func(batch []string) error {
+		keys := make([]string, len(batch))
+		for i, redisKey := range batch {
+			keys[i] = strings.TrimPrefix(redisKey, entryPrefix)
+		}
+		return b.untrack(ctx, ns, keys...)
+	})
+}
+
+// globEscape escapes the characters of s that are special in a redis MATCH pattern.
+func globEscape(s string) string {
+
+	var escaped strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
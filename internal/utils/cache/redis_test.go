@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*RedisBackend, *miniredis.Miniredis) {
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return NewRedisBackend(client, "test"), server
+}
+
+func TestRedisTTL(t *testing.T) {
+
+	ctx := context.Background()
+	b, server := newTestRedis(t)
+
+	_, err := b.Set(ctx, testNamespace, "short", []byte("v"), time.Second, SetAlways, []string{"tag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.Set(ctx, testNamespace, "forever", []byte("v"), 0, SetAlways, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ttl, err := b.TTL(ctx, testNamespace, "short")
+	if err != nil || ttl != time.Second {
+		t.Fatalf("ttl = %v, %v, want 1s", ttl, err)
+	}
+	ttl, err = b.TTL(ctx, testNamespace, "forever")
+	if err != nil || ttl != NoExpiry {
+		t.Fatalf("ttl = %v, %v, want NoExpiry", ttl, err)
+	}
+
+	server.FastForward(2 * time.Second)
+
+	_, err = b.Get(ctx, testNamespace, "short")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired key : %v, want ErrNotFound", err)
+	}
+	_, err = b.TTL(ctx, testNamespace, "short")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ttl of an expired key : %v, want ErrNotFound", err)
+	}
+	// the tags of the key expire along with it
+	if server.Exists(b.tagsPrefix(testNamespace.ID) + "short") {
+		t.Fatal("tags of the expired key are left")
+	}
+
+	err = b.Expire(ctx, testNamespace, "forever", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Expire(ctx, testNamespace, "forever", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttl, err = b.TTL(ctx, testNamespace, "forever")
+	if err != nil || ttl != NoExpiry {
+		t.Fatalf("ttl after removing the expiry = %v, %v, want NoExpiry", ttl, err)
+	}
+	err = b.Expire(ctx, testNamespace, "short", time.Minute)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expire of an expired key : %v, want ErrNotFound", err)
+	}
+}
+
+func TestRedisCompareAndSwap(t *testing.T) {
+
+	ctx := context.Background()
+	b, _ := newTestRedis(t)
+
+	_, err := b.Set(ctx, testNamespace, "key", []byte("v1"), time.Minute, SetAlways, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = b.CompareAndSwap(ctx, testNamespace, "key", ETag([]byte("v1")), []byte("v2"), 0)
+	if err != nil {
+		t.Fatalf("swap with the current etag : %v", err)
+	}
+	err = b.CompareAndSwap(ctx, testNamespace, "key", ETag([]byte("v1")), []byte("v3"), 0)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("swap with a stale etag : %v, want ErrConflict", err)
+	}
+	err = b.CompareAndSwap(ctx, testNamespace, "missing", ETag([]byte("v1")), []byte("v3"), 0)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("swap of a missing key : %v, want ErrNotFound", err)
+	}
+
+	value, err := b.Get(ctx, testNamespace, "key")
+	if err != nil || string(value) != "v2" {
+		t.Fatalf("value = %q, %v, want v2", value, err)
+	}
+	// a ttl of 0 keeps the current one
+	ttl, err := b.TTL(ctx, testNamespace, "key")
+	if err != nil || ttl != time.Minute {
+		t.Fatalf("ttl = %v, %v, want 1m", ttl, err)
+	}
+}
+
+func TestRedisInvalidate(t *testing.T) {
+
+	ctx := context.Background()
+	b, _ := newTestRedis(t)
+
+	tagged := map[string][]string{
+		"a": {"x"},
+		"b": {"x", "y"},
+		"c": {"y"},
+		"d": nil,
+	}
+	for key, tags := range tagged {
+		_, err := b.Set(ctx, testNamespace, key, []byte("v"), 0, SetAlways, tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the same tag in another namespace is left alone
+	_, err := b.Set(ctx, Namespace{ID: "other"}, "a", []byte("v"), 0, SetAlways, []string{"x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := b.Invalidate(ctx, testNamespace, []string{"x"})
+	if err != nil || removed != 2 {
+		t.Fatalf("removed = %d, %v, want 2", removed, err)
+	}
+	for key, want := range map[string]bool{"a": false, "b": false, "c": true, "d": true} {
+		exists, _ := b.Exists(ctx, testNamespace, key)
+		if exists != want {
+			t.Fatalf("%s exists = %v, want %v", key, exists, want)
+		}
+	}
+	exists, _ := b.Exists(ctx, Namespace{ID: "other"}, "a")
+	if !exists {
+		t.Fatal("key of another namespace was invalidated")
+	}
+
+	// put again without tags, c no longer carries y
+	_, err = b.Set(ctx, testNamespace, "c", []byte("v"), 0, SetAlways, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err = b.Invalidate(ctx, testNamespace, []string{"y"})
+	if err != nil || removed != 0 {
+		t.Fatalf("removed = %d, %v, want 0", removed, err)
+	}
+}
+
+func TestRedisScanPrefix(t *testing.T) {
+
+	ctx := context.Background()
+	b, _ := newTestRedis(t)
+
+	var want []string
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		want = append(want, key)
+		_, err := b.Set(ctx, testNamespace, key, []byte("v"), 0, SetAlways, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"j", "l", "k*"} {
+		_, err := b.Set(ctx, testNamespace, key, []byte("v"), 0, SetAlways, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := b.Set(ctx, Namespace{ID: "other"}, "k00", []byte("v"), 0, SetAlways, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the redis cursor gives no order, only that every key is listed once
+	var got []string
+	cursor := ""
+	for {
+		keys, next, err := b.Scan(ctx, testNamespace, "k0", cursor, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			got = append(got, key.Key)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want[:10]) {
+		t.Fatalf("scanned %v, want %v", got, want[:10])
+	}
+
+	_, _, err = b.Scan(ctx, testNamespace, "", "not a cursor", 10)
+	if !errors.Is(err, ErrBadCursor) {
+		t.Fatalf("scan from a bad cursor : %v, want ErrBadCursor", err)
+	}
+
+	// the glob characters of the prefix are matched literally
+	removed, err := b.DeletePrefix(ctx, testNamespace, "k*")
+	if err != nil || removed != 1 {
+		t.Fatalf("removed = %d, %v, want 1", removed, err)
+	}
+	removed, err = b.DeletePrefix(ctx, testNamespace, "k")
+	if err != nil || removed != 25 {
+		t.Fatalf("removed = %d, %v, want 25", removed, err)
+	}
+	for _, key := range []string{"j", "l"} {
+		exists, _ := b.Exists(ctx, testNamespace, key)
+		if !exists {
+			t.Fatalf("%s was deleted along with the prefix", key)
+		}
+	}
+	exists, _ := b.Exists(ctx, Namespace{ID: "other"}, "k00")
+	if !exists {
+		t.Fatal("key of another namespace was deleted")
+	}
+}
@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"main.go/internal/dto"
+)
+
+var ErrMissingServiceSecret = errors.New("service secret key not found in env")
+
+// SourceURLs are the paths of the remote cache source, relative to its base domain.
+type SourceURLs struct {
+	PutCacheURL string
+	GetCacheURL string
+}
+
+// RemoteBackend forwards to the remote cache source over http.
+// The source only offers get and set, so deleting a key, replacing only an existing key
+// limiting the key count of a namespace, tagging keys or listing them is not supported.
+// It also does not tell whether an upsert created the key, so that is checked beforehand, which is not atomic.
+// Values are sent as json strings, so only valid utf-8 values can be stored.
+type RemoteBackend struct {
+	httpClient *http.Client
+	baseDomain string
+	urls *SourceURLs
+}
+
+func NewRemoteBackend(client *http.Client, baseDomain string, urls *SourceURLs) *RemoteBackend {
+	return &RemoteBackend{
+		httpClient: client,
+		baseDomain: baseDomain,
+		urls: urls,
+	}
+}
+
+// hitSource sends the request with the service secret and maps the source status codes to backend errors.
+// The caller must close the body of the returned response.
+func (b *RemoteBackend) hitSource(ctx context.Context, method string, url string, body io.Reader) (*http.Response, error) {
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a new request for cache : %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	secretKey, exists := os.LookupEnv("ServiceSecretKey")
+	if !exists {
+		return nil, ErrMissingServiceSecret
+	}
+	req.Header.Set("authorization", secretKey)
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response from cache source : %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, ErrNotFound
+		case http.StatusPreconditionFailed, http.StatusConflict:
+			return nil, ErrExists
+		}
+		return nil, fmt.Errorf("cache source responded with code other than 200-OK : %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (b *RemoteBackend) Get(ctx context.Context, ns Namespace, key string) ([]byte, error) {
+
+	getURL := fmt.Sprintf("%s%s/%s/%s", b.baseDomain, b.urls.GetCacheURL, url.PathEscape(ns.ID), url.PathEscape(key))
+
+	resp, err := b.hitSource(ctx, http.MethodGet, getURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	value, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache source response body : %w", err)
+	}
+
+	return value, nil
+}
+
+func (b *RemoteBackend) Set(ctx context.Context, ns Namespace, key string, value []byte, ttl time.Duration, mode SetMode, tags []string) (bool, error) {
+
+	if mode == SetIfExists || ns.Limited() || len(tags) > 0 || !utf8.Valid(value) {
+		return false, ErrNotSupported
+	}
+
+	created := true
+	if mode == SetAlways {
+		exists, err := b.Exists(ctx, ns, key)
+		if err != nil {
+			return false, err
+		}
+		created = !exists
+	}
+
+	outGoingBytes, err := json.Marshal(dto.SetCacheKeyOutgoing{
+		UID: ns.ID,
+		Key: key,
+		Value: string(value),
+		TTL: ttl.Milliseconds(),
+		UpdateIfExists: mode == SetAlways,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal outgoing put cache json struct : %w", err)
+	}
+
+	resp, err := b.hitSource(ctx, http.MethodPost, b.baseDomain+b.urls.PutCacheURL, bytes.NewReader(outGoingBytes))
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	return created, nil
+}
+
+func (b *RemoteBackend) Delete(ctx context.Context, ns Namespace, key string) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (b *RemoteBackend) Exists(ctx context.Context, ns Namespace, key string) (bool, error) {
+
+	_, err := b.Get(ctx, ns, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *RemoteBackend) TTL(ctx context.Context, ns Namespace, key string) (time.Duration, error) {
+	return 0, ErrNotSupported
+}
+
+func (b *RemoteBackend) Expire(ctx context.Context, ns Namespace, key string, ttl time.Duration) error {
+	return ErrNotSupported
+}
+
+// GetMany gets the keys one by one, the source has no batch get.
+func (b *RemoteBackend) GetMany(ctx context.Context, ns Namespace, keys []string) ([][]byte, error) {
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		value, err := b.Get(ctx, ns, key)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (b *RemoteBackend) DeleteMany(ctx context.Context, ns Namespace, keys []string) ([]bool, error) {
+	return nil, ErrNotSupported
+}
+
+func (b *RemoteBackend) Incr(ctx context.Context, ns Namespace, key string, delta int64, ttl time.Duration) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (b *RemoteBackend) CompareAndSwap(ctx context.Context, ns Namespace, key string, etag string, value []byte, ttl time.Duration) error {
+	return ErrNotSupported
+}
+
+func (b *RemoteBackend) Flush(ctx context.Context, ns Namespace) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (b *RemoteBackend) Invalidate(ctx context.Context, ns Namespace, tags []string) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (b *RemoteBackend) Scan(ctx context.Context, ns Namespace, prefix string, cursor string, limit int) ([]KeyInfo, string, error) {
+	return nil, "", ErrNotSupported
+}
+
+func (b *RemoteBackend) DeletePrefix(ctx context.Context, ns Namespace, prefix string) (int64, error) {
+	return 0, ErrNotSupported
+}
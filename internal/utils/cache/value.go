@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+)
+
+// Value types, declared when a value is put.
+const (
+	TypeString = "string" // utf-8 text, the default
+	TypeJSON = "json" // a json document, validated on put
+	TypeBytes = "bytes" // raw bytes with the content type given on put
+)
+
+const (
+	ContentTypeString = "text/plain; charset=utf-8"
+	ContentTypeJSON = "application/json"
+	ContentTypeBytes = "application/octet-stream"
+)
+
+var ErrMalformedValue = errors.New("cache value has a malformed type header")
+
+// valueHeader starts every json and bytes value. Strings are stored as they are, like the values
+// put before types existed, so that they stay readable and counters can still be incremented.
+// The header is followed by the type byte, the length of the content type in one byte, the content type and the data.
+var valueHeader = []byte("\x00vb1")
+
+var typeBytes = map[string]byte{
+	TypeJSON: 'j',
+	TypeBytes: 'b',
+}
+
+// Value is a cache value along with its declared type.
+type Value struct {
+	Type string
+	ContentType string
+	Data []byte
+}
+
+// HasHeader reports whether data would be mistaken for an encoded value if stored as a string.
+func HasHeader(data []byte) bool {
+	return bytes.HasPrefix(data, valueHeader)
+}
+
+// Encode returns the bytes to store for the value. The content type must be at most 255 bytes long.
+func (v *Value) Encode() []byte {
+
+	typeByte, typed := typeBytes[v.Type]
+	if !typed {
+		return v.Data
+	}
+
+	encoded := make([]byte, 0, len(valueHeader) + 2 + len(v.ContentType) + len(v.Data))
+	encoded = append(encoded, valueHeader...)
+	encoded = append(encoded, typeByte, byte(len(v.ContentType)))
+	encoded = append(encoded, v.ContentType...)
+	encoded = append(encoded, v.Data...)
+	return encoded
+}
+
+// DecodeValue reads a stored value, data without the header is a string.
+// The returned Data shares the memory of raw.
+func DecodeValue(raw []byte) (*Value, error) {
+
+	if !HasHeader(raw) {
+		return &Value{
+			Type: TypeString,
+			ContentType: ContentTypeString,
+			Data: raw,
+		}, nil
+	}
+
+	rest := raw[len(valueHeader):]
+	if len(rest) < 2 || len(rest[2:]) < int(rest[1]) {
+		return nil, ErrMalformedValue
+	}
+
+	value := &Value{
+		ContentType: string(rest[2 : 2 + int(rest[1])]),
+		Data: rest[2 + int(rest[1]):],
+	}
+	for name, typeByte := range typeBytes {
+		if rest[0] == typeByte {
+			value.Type = name
+		}
+	}
+	if value.Type == "" {
+		return nil, ErrMalformedValue
+	}
+
+	return value, nil
+}
@@ -0,0 +1,140 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrMissingConfig = errors.New("smtp config not found in env")
+
+type Message struct {
+	To string
+	Subject string
+	Body string // plain text
+}
+
+// Mailer delivers emails, swap implementations to change how mail goes out.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+
+// SMTPMailer sends mail through an smtp server, using STARTTLS when the server offers it.
+type SMTPMailer struct {
+	host string
+	port string
+	username string
+	password string
+	from string
+}
+
+func NewSMTPMailer(host string, port string, username string, password string, from string) *SMTPMailer {
+	return &SMTPMailer{
+		host: host,
+		port: port,
+		username: username,
+		password: password,
+		from: from,
+	}
+}
+
+// NewSMTPMailerFromEnv reads SMTPHost, SMTPPort, SMTPUsername, SMTPPassword and SMTPFrom from env.
+// Username and password can be left empty for servers that do not need auth.
+func NewSMTPMailerFromEnv() (*SMTPMailer, error) {
+
+	host, hostExists := os.LookupEnv("SMTPHost")
+	port, portExists := os.LookupEnv("SMTPPort")
+	from, fromExists := os.LookupEnv("SMTPFrom")
+	if !hostExists || !portExists || !fromExists {
+		return nil, ErrMissingConfig
+	}
+
+	return NewSMTPMailer(host, port, os.Getenv("SMTPUsername"), os.Getenv("SMTPPassword"), from), nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+
+	// header injection, the recipient and subject end up in the raw message
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid recipient or subject")
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	raw := "From: " + m.from + "\r\n" +
+		"To: " + msg.To + "\r\n" +
+		"Subject: " + msg.Subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + msg.Body
+
+	// net/smtp has no context support, so the send runs aside and is abandoned if the context ends first
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(m.host, m.port), auth, m.from, []string{msg.To}, []byte(raw))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+
+// LogMailer only logs the recipient and subject of each message and keeps nothing.
+// The body is left out, it carries the confirmation links.
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	fmt.Printf("Email not delivered, smtp is not configured : to %s, subject %q\n", msg.To, msg.Subject)
+	return nil
+}
+
+// >>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>
+
+// MemoryMailer keeps sent messages in memory instead of delivering them, for tests.
+type MemoryMailer struct {
+	mu sync.Mutex
+	sent []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent = append(m.sent, *msg)
+	return nil
+}
+
+// Sent returns a copy of all messages sent so far, oldest first.
+func (m *MemoryMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sent := make([]Message, len(m.sent))
+	copy(sent, m.sent)
+	return sent
+}
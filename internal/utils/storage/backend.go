@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrNotFound = errors.New("storage object not found")
+	ErrNotSupported = errors.New("operation is not supported by the storage backend")
+	ErrInvalidKey = errors.New("storage object key or namespace is invalid")
+	ErrSizeMismatch = errors.New("storage object body does not have the declared size")
+)
+
+// Backend names, selected with the StorageBackend env.
+const (
+	BackendRemote = "remote"
+	BackendLocal = "local"
+	BackendS3 = "s3"
+)
+
+// MaxKeyLength is the max length of an object key, in bytes.
+const MaxKeyLength = 1024
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key string
+	Size int64 // -1 if the backend does not know it
+	ContentType string
+	ETag string // changes with the content, the hex sha256 of it for the local backend, empty if the backend does not know it
+	ModifiedAt time.Time // zero if the backend does not know it
+}
+
+// StorageBackend stores the files of the storage service.
+// Each project has its own namespace, its user_uiid, so the same key of two projects never collides.
+type StorageBackend interface {
+	// Put stores size bytes read from body as the object of the key, replacing the previous one if any.
+	Put(ctx context.Context, namespace string, key string, body io.Reader, size int64, contentType string) (*ObjectInfo, error)
+
+	// Get opens the object, the caller must close the returned reader.
+	Get(ctx context.Context, namespace string, key string) (io.ReadCloser, *ObjectInfo, error)
+
+	Stat(ctx context.Context, namespace string, key string) (*ObjectInfo, error)
+
+	// Delete removes the object, failing with ErrNotFound if there is none.
+	Delete(ctx context.Context, namespace string, key string) error
+
+	// List returns a page of up to limit objects whose key starts with the prefix, in key order,
+	// after the cursor, "" for the first page. Returns the cursor of the next page, "" once all objects were listed.
+	// A limit below 1 is taken as 1.
+	List(ctx context.Context, namespace string, prefix string, cursor string, limit int) ([]ObjectInfo, string, error)
+}
+
+// ValidKey reports whether the key can name an object, 1 to MaxKeyLength bytes of utf-8 without control characters.
+func ValidKey(key string) bool {
+
+	if key == "" || len(key) > MaxKeyLength || !utf8.ValidString(key) {
+		return false
+	}
+	return strings.IndexFunc(key, unicode.IsControl) < 0
+}
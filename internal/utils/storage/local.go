@@ -0,0 +1,343 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tempPrefix starts the names of the files being written, they are skipped when listing.
+const tempPrefix = ".tmp-"
+
+// footerSize is the size of the length of the metadata at the end of an object file.
+const footerSize = 8
+
+var namespacePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// objectMeta is the metadata written after the content of an object.
+type objectMeta struct {
+	Key string `json:"key"`
+	Size int64 `json:"size"`
+	ContentType string `json:"contenttype"`
+	ETag string `json:"etag"`
+	ModifiedAt time.Time `json:"modifiedat"`
+}
+
+// LocalBackend stores objects as files under a root directory, root/<namespace>/<h[:2]>/<h>, where h is the hex sha256
+// of the key. Paths are addressed by that hash and never built from the key itself, so no key can escape the root.
+// Unlike a content addressed store, the key is hashed and not the content, and identical contents are not deduplicated.
+// The key is all that Get, Stat and Delete are given: the path follows from it without a key to content index to keep
+// in sync, replacing an object stays a single rename, and deleting one never has to know whether another key shares it.
+// The content hash is kept as the ETag instead.
+// A file holds the content, then the metadata as json, then the length of that json in 8 bytes.
+// It is written to a temp file next to the object and renamed over it, so readers always see one version whole,
+// and the directory is synced after the rename so that the new version survives a crash.
+// Listing reads the metadata of every object of the namespace, it is meant for self hosting and tests rather than large projects.
+type LocalBackend struct {
+	root string
+}
+
+// NewLocalBackend creates the root directory if needed.
+func NewLocalBackend(root string) (*LocalBackend, error) {
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage root : %w", err)
+	}
+
+	err = os.MkdirAll(root, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage root : %w", err)
+	}
+
+	return &LocalBackend{
+		root: root,
+	}, nil
+}
+
+// objectPath returns the directory and the path of the object of the key.
+func (b *LocalBackend) objectPath(namespace string, key string) (string, string, error) {
+
+	if !namespacePattern.MatchString(namespace) || !ValidKey(key) {
+		return "", "", ErrInvalidKey
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	dir := filepath.Join(b.root, namespace, name[:2])
+	return dir, filepath.Join(dir, name), nil
+}
+
+// syncDir flushes the entries of the directory, so that a rename or remove in it is durable.
+func syncDir(dir string) error {
+
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return file.Sync()
+}
+
+// readMeta reads the metadata at the end of an object file.
+func readMeta(file *os.File) (*objectMeta, error) {
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size() < footerSize {
+		return nil, fmt.Errorf("storage object file %s is truncated", file.Name())
+	}
+
+	footer := make([]byte, footerSize)
+	_, err = file.ReadAt(footer, stat.Size() - footerSize)
+	if err != nil {
+		return nil, err
+	}
+
+	metaSize := int64(binary.BigEndian.Uint64(footer))
+	if metaSize > stat.Size() - footerSize {
+		return nil, fmt.Errorf("storage object file %s has a malformed footer", file.Name())
+	}
+
+	metaJSON := make([]byte, metaSize)
+	_, err = file.ReadAt(metaJSON, stat.Size() - footerSize - metaSize)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := new(objectMeta)
+	err = json.Unmarshal(metaJSON, meta)
+	if err != nil {
+		return nil, fmt.Errorf("storage object file %s has malformed metadata : %w", file.Name(), err)
+	}
+	return meta, nil
+}
+
+// open opens the object file of the key and reads its metadata, the caller must close the file.
+func (b *LocalBackend) open(namespace string, key string) (*os.File, *objectMeta, error) {
+
+	_, path, err := b.objectPath(namespace, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	meta, err := readMeta(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	// two keys with the same hash, the other one is stored
+	if meta.Key != key {
+		file.Close()
+		return nil, nil, ErrNotFound
+	}
+	return file, meta, nil
+}
+
+func (m *objectMeta) info() *ObjectInfo {
+	return &ObjectInfo{
+		Key: m.Key,
+		Size: m.Size,
+		ContentType: m.ContentType,
+		ETag: m.ETag,
+		ModifiedAt: m.ModifiedAt,
+	}
+}
+
+// Put reads the whole body into a temp file and renames it over the object once it is synced.
+// A body that fails or does not have the declared size leaves the previous object, if any, untouched.
+func (b *LocalBackend) Put(ctx context.Context, namespace string, key string, body io.Reader, size int64, contentType string) (*ObjectInfo, error) {
+
+	dir, path, err := b.objectPath(namespace, key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, err
+	}
+
+	temp, err := os.CreateTemp(dir, tempPrefix + "*")
+	if err != nil {
+		return nil, err
+	}
+	renamed := false
+	defer func() {
+		if !renamed {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(temp, hash), body)
+	if err != nil {
+		return nil, err
+	}
+	if size >= 0 && written != size {
+		return nil, ErrSizeMismatch
+	}
+
+	meta := &objectMeta{
+		Key: key,
+		Size: written,
+		ContentType: contentType,
+		ETag: hex.EncodeToString(hash.Sum(nil)),
+		ModifiedAt: time.Now().UTC(),
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	footer := binary.BigEndian.AppendUint64(nil, uint64(len(metaJSON)))
+
+	_, err = temp.Write(append(metaJSON, footer...))
+	if err != nil {
+		return nil, err
+	}
+
+	err = temp.Sync()
+	if err != nil {
+		return nil, err
+	}
+	err = temp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Rename(temp.Name(), path)
+	if err != nil {
+		return nil, err
+	}
+	renamed = true
+
+	err = syncDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return meta.info(), nil
+}
+
+func (b *LocalBackend) Get(ctx context.Context, namespace string, key string) (io.ReadCloser, *ObjectInfo, error) {
+
+	file, meta, err := b.open(namespace, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the content without the metadata that follows it
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, 0, meta.Size), file}, meta.info(), nil
+}
+
+func (b *LocalBackend) Stat(ctx context.Context, namespace string, key string) (*ObjectInfo, error) {
+
+	file, meta, err := b.open(namespace, key)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	return meta.info(), nil
+}
+
+// Delete is a single remove of the object file, so it happens wholly before or after a concurrent Put of the key.
+func (b *LocalBackend) Delete(ctx context.Context, namespace string, key string) error {
+
+	dir, path, err := b.objectPath(namespace, key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return syncDir(dir)
+}
+
+// List reads the metadata of all the objects of the namespace, the cursor is the key of the last object of the previous page.
+func (b *LocalBackend) List(ctx context.Context, namespace string, prefix string, cursor string, limit int) ([]ObjectInfo, string, error) {
+
+	if !namespacePattern.MatchString(namespace) {
+		return nil, "", ErrInvalidKey
+	}
+	limit = max(limit, 1)
+
+	var objects []ObjectInfo
+	err := filepath.WalkDir(filepath.Join(b.root, namespace), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return ctx.Err()
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			// removed since the directory was read
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		meta, err := readMeta(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(meta.Key, prefix) && (cursor == "" || meta.Key > cursor) {
+			objects = append(objects, *meta.info())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	next := ""
+	if len(objects) > limit {
+		objects = objects[:limit]
+		next = objects[limit-1].Key
+	}
+	return objects, next, nil
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func newTestLocal(t *testing.T) (*LocalBackend, string) {
+
+	parent := t.TempDir()
+	b, err := NewLocalBackend(filepath.Join(parent, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, parent
+}
+
+func putString(t *testing.T, b StorageBackend, namespace string, key string, content string) {
+
+	_, err := b.Put(context.Background(), namespace, key, strings.NewReader(content), int64(len(content)), "text/plain")
+	if err != nil {
+		t.Fatalf("put %q : %v", key, err)
+	}
+}
+
+func getString(t *testing.T, b StorageBackend, namespace string, key string) string {
+
+	body, _, err := b.Get(context.Background(), namespace, key)
+	if err != nil {
+		t.Fatalf("get %q : %v", key, err)
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// files lists the paths of the files under dir, relative to it.
+func files(t *testing.T, dir string) []string {
+
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestLocalPathTraversal(t *testing.T) {
+
+	ctx := context.Background()
+	b, parent := newTestLocal(t)
+
+	keys := []string{"../x", "../../../../etc/passwd", "/etc/passwd", "a/../../x", "..", "C:\\x"}
+	for _, key := range keys {
+		putString(t, b, "ns", key, key)
+	}
+
+	// every object is a hashed file of the namespace, whatever its key
+	for _, path := range files(t, parent) {
+		parts := strings.Split(path, string(filepath.Separator))
+		if len(parts) != 4 || parts[0] != "root" || parts[1] != "ns" || len(parts[3]) != 64 {
+			t.Fatalf("object stored at %s, outside of root/ns/<h[:2]>/<h>", path)
+		}
+	}
+	for _, key := range keys {
+		if got := getString(t, b, "ns", key); got != key {
+			t.Fatalf("content of %q = %q", key, got)
+		}
+	}
+
+	for _, namespace := range []string{"..", "../ns", "/tmp", "ns/../other", ""} {
+		_, err := b.Put(ctx, namespace, "key", strings.NewReader("v"), 1, "")
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("put in namespace %q : %v, want ErrInvalidKey", namespace, err)
+		}
+		_, _, err = b.List(ctx, namespace, "", "", 10)
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("list of namespace %q : %v, want ErrInvalidKey", namespace, err)
+		}
+	}
+}
+
+func TestLocalFailedPut(t *testing.T) {
+
+	ctx := context.Background()
+	b, parent := newTestLocal(t)
+	putString(t, b, "ns", "existing", "old content")
+
+	tests := []struct {
+		name string
+		key string
+		body io.Reader
+		size int64
+		want error
+	}{
+		{
+			name: "short body",
+			key: "new",
+			body: strings.NewReader("short"),
+			size: 10,
+			want: ErrSizeMismatch,
+		},
+		{
+			name: "long body",
+			key: "new",
+			body: strings.NewReader("longer than declared"),
+			size: 4,
+			want: ErrSizeMismatch,
+		},
+		{
+			name: "failing body",
+			key: "new",
+			body: io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(io.ErrUnexpectedEOF)),
+			size: -1,
+			want: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "failing body over an existing object",
+			key: "existing",
+			body: io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(io.ErrUnexpectedEOF)),
+			size: -1,
+			want: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			_, err := b.Put(ctx, "ns", tt.key, tt.body, tt.size, "")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("put : %v, want %v", err, tt.want)
+			}
+
+			_, err = b.Stat(ctx, "ns", "new")
+			if !errors.Is(err, ErrNotFound) {
+				t.Fatalf("stat after a failed put : %v, want ErrNotFound", err)
+			}
+			if got := getString(t, b, "ns", "existing"); got != "old content" {
+				t.Fatalf("existing object = %q after a failed put", got)
+			}
+			for _, path := range files(t, parent) {
+				if strings.HasPrefix(filepath.Base(path), tempPrefix) {
+					t.Fatalf("temp file %s left behind", path)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalDelete(t *testing.T) {
+
+	ctx := context.Background()
+	b, _ := newTestLocal(t)
+	putString(t, b, "ns", "key", "v")
+
+	err := b.Delete(ctx, "ns", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Delete(ctx, "ns", "key")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second delete : %v, want ErrNotFound", err)
+	}
+	_, err = b.Stat(ctx, "ns", "key")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("stat after delete : %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalListPaging(t *testing.T) {
+
+	ctx := context.Background()
+	b, _ := newTestLocal(t)
+
+	var want []string
+	for i := 0; i < 12; i++ {
+		key := fmt.Sprintf("docs/%02d", i)
+		want = append(want, key)
+		putString(t, b, "ns", key, key)
+	}
+	putString(t, b, "ns", "images/a", "a")
+	putString(t, b, "other", "docs/other", "other")
+
+	var got []string
+	cursor := ""
+	for pages := 1; ; pages++ {
+		objects, next, err := b.List(ctx, "ns", "docs/", cursor, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(objects) > 5 {
+			t.Fatalf("page of %d objects, over the limit", len(objects))
+		}
+		for _, object := range objects {
+			got = append(got, object.Key)
+			if object.Size != int64(len(object.Key)) {
+				t.Fatalf("size of %s = %d", object.Key, object.Size)
+			}
+		}
+		if next == "" {
+			if pages != 3 {
+				t.Fatalf("listed in %d pages, want 3", pages)
+			}
+			break
+		}
+		cursor = next
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("listed %v, want %v", got, want)
+	}
+
+	// a limit below 1 lists a single object instead of failing
+	objects, next, err := b.List(ctx, "ns", "", "", 0)
+	if err != nil || len(objects) != 1 || objects[0].Key != "docs/00" || next != "docs/00" {
+		t.Fatalf("list with no limit = %v, %q, %v", objects, next, err)
+	}
+
+	objects, _, err = b.List(ctx, "empty", "", "", 5)
+	if err != nil || len(objects) != 0 {
+		t.Fatalf("list of an empty namespace = %v, %v", objects, err)
+	}
+}
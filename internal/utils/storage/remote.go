@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+var ErrMissingServiceSecret = errors.New("service secret key not found in env")
+
+// downloadSecret is checked by the download route of the source instead of the ServiceSecretKey env.
+const downloadSecret = "vaultbase1234"
+
+// SourceURLs are the paths of the remote storage source, relative to its base domain.
+type SourceURLs struct {
+	UploadURL string
+	DownloadURL string
+}
+
+// RemoteBackend forwards to the remote storage source over http.
+// The source only offers uploads and downloads, so getting the info of an object, deleting or listing them is not supported.
+type RemoteBackend struct {
+	httpClient *http.Client
+	baseDomain string
+	urls *SourceURLs
+}
+
+func NewRemoteBackend(client *http.Client, baseDomain string, urls *SourceURLs) *RemoteBackend {
+	return &RemoteBackend{
+		httpClient: client,
+		baseDomain: baseDomain,
+		urls: urls,
+	}
+}
+
+// hitSource sends the request and maps the source status codes to backend errors.
+// The caller must close the body of the returned response.
+func (b *RemoteBackend) hitSource(req *http.Request) (*http.Response, error) {
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response from storage source : %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("storage source responded with code other than 2XX : %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// Put streams the body to the source as a multipart form, the key is sent as the file name.
+func (b *RemoteBackend) Put(ctx context.Context, namespace string, key string, body io.Reader, size int64, contentType string) (*ObjectInfo, error) {
+
+	secretKey, exists := os.LookupEnv("ServiceSecretKey")
+	if !exists {
+		return nil, ErrMissingServiceSecret
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	writer := multipart.NewWriter(pipeWriter)
+
+	// the form is written as the request reads it, so the file is never held in memory
+	go func() {
+		err := writer.WriteField("uid", namespace)
+		if err == nil {
+			var part io.Writer
+			part, err = writer.CreateFormFile("file", key)
+			if err == nil {
+				_, err = io.Copy(part, body)
+			}
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseDomain+b.urls.UploadURL, pipeReader)
+	if err != nil {
+		pipeReader.Close()
+		return nil, fmt.Errorf("failed to create a new request for storage : %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("authorization", secretKey)
+
+	resp, err := b.hitSource(req)
+	if err != nil {
+		pipeReader.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return &ObjectInfo{
+		Key: key,
+		Size: size,
+		ContentType: contentType,
+	}, nil
+}
+
+func (b *RemoteBackend) Get(ctx context.Context, namespace string, key string) (io.ReadCloser, *ObjectInfo, error) {
+
+	query := url.Values{}
+	query.Set("uid", namespace)
+	query.Set("key", key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.baseDomain+b.urls.DownloadURL+"/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create a new request for storage : %w", err)
+	}
+	req.Header.Set("authorization", downloadSecret)
+
+	resp, err := b.hitSource(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp.Body, &ObjectInfo{
+		Key: key,
+		Size: resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+func (b *RemoteBackend) Stat(ctx context.Context, namespace string, key string) (*ObjectInfo, error) {
+	return nil, ErrNotSupported
+}
+
+func (b *RemoteBackend) Delete(ctx context.Context, namespace string, key string) error {
+	return ErrNotSupported
+}
+
+func (b *RemoteBackend) List(ctx context.Context, namespace string, prefix string, cursor string, limit int) ([]ObjectInfo, string, error) {
+	return nil, "", ErrNotSupported
+}
@@ -0,0 +1,396 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+var ErrMissingS3Config = errors.New("s3 config not found in env")
+
+// s3MaxKeyLength is the max length of a key of the store, prefixes included, in bytes.
+const s3MaxKeyLength = 1024
+
+// S3Config locates the bucket and holds the credentials to it.
+type S3Config struct {
+	Bucket string
+	Region string // "auto" for R2
+	Endpoint string // empty for AWS, the url of the store otherwise, like http://localhost:9000 for MinIO
+	AccessKeyID string
+	SecretAccessKey string
+	PathStyle bool // bucket in the path of the url rather than its host, needed by MinIO
+	KeyPrefix string // prepended to the keys of all objects, to share the bucket with other data
+	PartSize int64 // bytes // files larger than this are sent in parts of this size, at least 5 MB
+}
+
+// S3ConfigFromEnv reads S3Bucket, S3Region, S3Endpoint, S3AccessKeyID, S3SecretAccessKey, S3PathStyle and S3KeyPrefix from env.
+// Region, endpoint, path style and key prefix can be left empty, the part size is left to the caller.
+func S3ConfigFromEnv() (*S3Config, error) {
+
+	bucket, bucketExists := os.LookupEnv("S3Bucket")
+	accessKeyID, accessKeyExists := os.LookupEnv("S3AccessKeyID")
+	secretAccessKey, secretKeyExists := os.LookupEnv("S3SecretAccessKey")
+	if !bucketExists || !accessKeyExists || !secretKeyExists {
+		return nil, ErrMissingS3Config
+	}
+
+	pathStyle := false
+	if pathStyleStr, exists := os.LookupEnv("S3PathStyle"); exists {
+		parsed, err := strconv.ParseBool(pathStyleStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3PathStyle : %s", pathStyleStr)
+		}
+		pathStyle = parsed
+	}
+
+	return &S3Config{
+		Bucket: bucket,
+		Region: os.Getenv("S3Region"),
+		Endpoint: os.Getenv("S3Endpoint"),
+		AccessKeyID: accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		PathStyle: pathStyle,
+		KeyPrefix: os.Getenv("S3KeyPrefix"),
+	}, nil
+}
+
+// S3Backend stores objects in a bucket of any S3 compatible store, AWS, MinIO or R2,
+// under <KeyPrefix><namespace>/<key> so each project has its own prefix.
+// Files larger than the part size are sent with a multipart upload, one part at a time, so at most one part is held in memory.
+// The ETag is the one of the store, its content hash is not known in advance for multipart uploads.
+type S3Backend struct {
+	client *s3.Client
+	bucket string
+	keyPrefix string
+	partSize int64
+}
+
+func NewS3Backend(httpClient *http.Client, cfg *S3Config) *S3Backend {
+
+	client := s3.New(s3.Options{
+		Region: cfg.Region,
+		Credentials: credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		BaseEndpoint: nilIfEmpty(cfg.Endpoint),
+		UsePathStyle: cfg.PathStyle,
+		HTTPClient: httpClient,
+
+		// the checksums added by default are not supported by every S3 compatible store
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+
+	return &S3Backend{
+		client: client,
+		bucket: cfg.Bucket,
+		keyPrefix: cfg.KeyPrefix,
+		partSize: cfg.PartSize,
+	}
+}
+
+func nilIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// namespacePrefix returns the prefix of the keys of the objects of the namespace.
+func (b *S3Backend) namespacePrefix(namespace string) (string, error) {
+
+	if !namespacePattern.MatchString(namespace) {
+		return "", ErrInvalidKey
+	}
+	return b.keyPrefix + namespace + "/", nil
+}
+
+func (b *S3Backend) objectKey(namespace string, key string) (string, error) {
+
+	prefix, err := b.namespacePrefix(namespace)
+	if err != nil {
+		return "", err
+	}
+	if !ValidKey(key) || len(prefix) + len(key) > s3MaxKeyLength {
+		return "", ErrInvalidKey
+	}
+	return prefix + key, nil
+}
+
+// mapError maps a response of the store with a 404 to ErrNotFound.
+func mapError(err error) error {
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+// trimETag removes the quotes around the ETags returned by the store.
+func trimETag(etag *string) string {
+	return strings.Trim(aws.ToString(etag), `"`)
+}
+
+// readPart fills buf from the body, last reports that the body ended, with n bytes read.
+func readPart(body io.Reader, buf []byte) (int, bool, error) {
+
+	n, err := io.ReadFull(body, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n, true, nil
+	}
+	return n, false, err
+}
+
+// Put sends the body in a single request if it fits in one part, or as a multipart upload otherwise.
+// A body that is not as large as declared is found before the upload completes, leaving the previous object in place.
+func (b *S3Backend) Put(ctx context.Context, namespace string, key string, body io.Reader, size int64, contentType string) (*ObjectInfo, error) {
+
+	objectKey, err := b.objectKey(namespace, key)
+	if err != nil {
+		return nil, err
+	}
+
+	bufSize := b.partSize
+	if size >= 0 && size <= bufSize {
+		// one more byte to find a body larger than declared, so a body of exactly one part is still sent at once
+		bufSize = size + 1
+	}
+	part := make([]byte, bufSize)
+
+	n, last, err := readPart(body, part)
+	if err != nil {
+		return nil, err
+	}
+	if size >= 0 && (int64(n) > size || last && int64(n) != size) {
+		return nil, ErrSizeMismatch
+	}
+	if !last {
+		return b.putMultipart(ctx, objectKey, key, body, size, contentType, part)
+	}
+
+	out, err := b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &b.bucket,
+		Key: &objectKey,
+		Body: bytes.NewReader(part[:n]),
+		ContentLength: aws.Int64(int64(n)),
+		ContentType: &contentType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to put object to s3 : %w", err)
+	}
+
+	return &ObjectInfo{
+		Key: key,
+		Size: int64(n),
+		ContentType: contentType,
+		ETag: trimETag(out.ETag),
+	}, nil
+}
+
+// putMultipart uploads the full part already read then the rest of the body, the upload is aborted if anything fails.
+func (b *S3Backend) putMultipart(ctx context.Context, objectKey string, key string, body io.Reader, size int64, contentType string, part []byte) (*ObjectInfo, error) {
+
+	created, err := b.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: &b.bucket,
+		Key: &objectKey,
+		ContentType: &contentType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload to s3 : %w", err)
+	}
+
+	completed := false
+	defer func() {
+		if !completed {
+			// the parts are billed until the upload is aborted, so it must happen even if the request was cancelled
+			_, err := b.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+				Bucket: &b.bucket,
+				Key: &objectKey,
+				UploadId: created.UploadId,
+			})
+			if err != nil {
+				fmt.Println("failed to abort multipart upload " + aws.ToString(created.UploadId) + " to s3 : " + err.Error())
+			}
+		}
+	}()
+
+	var parts []types.CompletedPart
+	total := int64(0)
+	n := len(part)
+	last := false
+	for number := int32(1); ; number++ {
+
+		total += int64(n)
+		if size >= 0 && total > size {
+			return nil, ErrSizeMismatch
+		}
+
+		// the body can end right at the end of a part, leaving nothing for the last one
+		if n > 0 {
+			uploaded, err := b.client.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket: &b.bucket,
+				Key: &objectKey,
+				UploadId: created.UploadId,
+				PartNumber: aws.Int32(number),
+				Body: bytes.NewReader(part[:n]),
+				ContentLength: aws.Int64(int64(n)),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to upload part %d to s3 : %w", number, err)
+			}
+			parts = append(parts, types.CompletedPart{
+				ETag: uploaded.ETag,
+				PartNumber: aws.Int32(number),
+			})
+		}
+
+		if last {
+			break
+		}
+		n, last, err = readPart(body, part)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if size >= 0 && total != size {
+		return nil, ErrSizeMismatch
+	}
+
+	out, err := b.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket: &b.bucket,
+		Key: &objectKey,
+		UploadId: created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: parts,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete multipart upload to s3 : %w", err)
+	}
+	completed = true
+
+	return &ObjectInfo{
+		Key: key,
+		Size: total,
+		ContentType: contentType,
+		ETag: trimETag(out.ETag),
+	}, nil
+}
+
+func (b *S3Backend) Get(ctx context.Context, namespace string, key string) (io.ReadCloser, *ObjectInfo, error) {
+
+	objectKey, err := b.objectKey(namespace, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &b.bucket,
+		Key: &objectKey,
+	})
+	if err != nil {
+		return nil, nil, mapError(err)
+	}
+
+	return out.Body, &ObjectInfo{
+		Key: key,
+		Size: aws.ToInt64(out.ContentLength),
+		ContentType: aws.ToString(out.ContentType),
+		ETag: trimETag(out.ETag),
+		ModifiedAt: aws.ToTime(out.LastModified),
+	}, nil
+}
+
+func (b *S3Backend) Stat(ctx context.Context, namespace string, key string) (*ObjectInfo, error) {
+
+	objectKey, err := b.objectKey(namespace, key)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &b.bucket,
+		Key: &objectKey,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	return &ObjectInfo{
+		Key: key,
+		Size: aws.ToInt64(out.ContentLength),
+		ContentType: aws.ToString(out.ContentType),
+		ETag: trimETag(out.ETag),
+		ModifiedAt: aws.ToTime(out.LastModified),
+	}, nil
+}
+
+// Delete checks that the object exists first, the store does not report deleting a missing object.
+func (b *S3Backend) Delete(ctx context.Context, namespace string, key string) error {
+
+	_, err := b.Stat(ctx, namespace, key)
+	if err != nil {
+		return err
+	}
+
+	objectKey, _ := b.objectKey(namespace, key)
+	_, err = b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &b.bucket,
+		Key: &objectKey,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object from s3 : %w", err)
+	}
+	return nil
+}
+
+// List starts after the key of the cursor, the store lists keys in the same byte order, and does not return content types.
+func (b *S3Backend) List(ctx context.Context, namespace string, prefix string, cursor string, limit int) ([]ObjectInfo, string, error) {
+
+	base, err := b.namespacePrefix(namespace)
+	if err != nil {
+		return nil, "", err
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: &b.bucket,
+		Prefix: aws.String(base + prefix),
+		MaxKeys: aws.Int32(int32(max(limit, 1))),
+	}
+	if cursor != "" {
+		input.StartAfter = aws.String(base + cursor)
+	}
+
+	out, err := b.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list objects from s3 : %w", err)
+	}
+
+	objects := make([]ObjectInfo, len(out.Contents))
+	for i, object := range out.Contents {
+		objects[i] = ObjectInfo{
+			Key: strings.TrimPrefix(aws.ToString(object.Key), base),
+			Size: aws.ToInt64(object.Size),
+			ETag: trimETag(object.ETag),
+			ModifiedAt: aws.ToTime(object.LastModified),
+		}
+	}
+
+	next := ""
+	if aws.ToBool(out.IsTruncated) && len(objects) > 0 {
+		next = objects[len(objects)-1].Key
+	}
+	return objects, next, nil
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+// testPartSize is far below the 5 MB minimum of S3, the fake store does not enforce it.
+const testPartSize = 1024
+
+// fakeS3 records the requests made to an in memory S3 fake, and can fail the upload of a part.
+type fakeS3 struct {
+	mu sync.Mutex
+	requests []string // method and query, like "PUT partNumber=2&uploadId=..."
+	failPart string // fails the upload of this part number, with a status the client does not retry
+}
+
+func (f *fakeS3) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r.Method + " " + r.URL.RawQuery)
+		failPart := f.failPart
+		f.mu.Unlock()
+
+		if failPart != "" && r.Method == http.MethodPut && r.URL.Query().Get("partNumber") == failPart {
+			http.Error(w, "part upload failed", http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// count returns how many requests had the method and a query with the parameter.
+func (f *fakeS3) count(method string, param string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for _, request := range f.requests {
+		requestMethod, query, _ := strings.Cut(request, " ")
+		if requestMethod == method && strings.Contains("&" + query, "&" + param) {
+			count++
+		}
+	}
+	return count
+}
+
+func newTestS3(t *testing.T) (*S3Backend, *fakeS3) {
+
+	fake := &fakeS3{}
+	server := httptest.NewServer(fake.wrap(gofakes3.New(s3mem.New()).Server()))
+	t.Cleanup(server.Close)
+
+	b := NewS3Backend(server.Client(), &S3Config{
+		Bucket: "bucket",
+		Region: "us-east-1",
+		Endpoint: server.URL,
+		AccessKeyID: "key",
+		SecretAccessKey: "secret",
+		PathStyle: true,
+		KeyPrefix: "files/",
+		PartSize: testPartSize,
+	})
+	_, err := b.client.CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: aws.String("bucket")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, fake
+}
+
+func TestS3Put(t *testing.T) {
+
+	tests := []struct {
+		name string
+		size int
+		parts int // 0 for a single request
+	}{
+		{name: "empty", size: 0},
+		{name: "single part", size: testPartSize - 1},
+		{name: "exactly one part", size: testPartSize},
+		{name: "multipart", size: 2 * testPartSize + testPartSize / 2, parts: 3},
+		{name: "multipart ending with a part", size: 3 * testPartSize, parts: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			ctx := context.Background()
+			b, fake := newTestS3(t)
+			content := bytes.Repeat([]byte("0123456789"), tt.size / 10 + 1)[:tt.size]
+
+			info, err := b.Put(ctx, "ns", "key", bytes.NewReader(content), int64(tt.size), "text/plain")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size != int64(tt.size) {
+				t.Fatalf("size = %d, want %d", info.Size, tt.size)
+			}
+
+			if got := fake.count(http.MethodPut, "partNumber="); got != tt.parts {
+				t.Fatalf("uploaded %d parts, want %d", got, tt.parts)
+			}
+			if got := fake.count(http.MethodPost, "uploads"); got != min(tt.parts, 1) {
+				t.Fatalf("created %d multipart uploads", got)
+			}
+
+			if got := getString(t, b, "ns", "key"); got != string(content) {
+				t.Fatalf("content of %d bytes, want %d", len(got), len(content))
+			}
+		})
+	}
+}
+
+func TestS3PutSizeMismatch(t *testing.T) {
+
+	ctx := context.Background()
+	b, fake := newTestS3(t)
+
+	for _, size := range []int64{testPartSize / 2, 3 * testPartSize} {
+		_, err := b.Put(ctx, "ns", "key", strings.NewReader(strings.Repeat("x", 2 * testPartSize)), size, "")
+		if !errors.Is(err, ErrSizeMismatch) {
+			t.Fatalf("body of another size than %d : %v, want ErrSizeMismatch", size, err)
+		}
+	}
+
+	_, err := b.Stat(ctx, "ns", "key")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("stat after a failed put : %v, want ErrNotFound", err)
+	}
+	if fake.count(http.MethodPost, "uploads") != fake.count(http.MethodDelete, "uploadId=") {
+		t.Fatal("a multipart upload was left without being aborted")
+	}
+}
+
+func TestS3PutAbortsOnFailedPart(t *testing.T) {
+
+	ctx := context.Background()
+	b, fake := newTestS3(t)
+	putString(t, b, "ns", "key", "old content")
+	fake.failPart = "2"
+
+	content := strings.Repeat("x", 3 * testPartSize)
+	_, err := b.Put(ctx, "ns", "key", strings.NewReader(content), int64(len(content)), "")
+	if err == nil {
+		t.Fatal("put succeeded with a failed part")
+	}
+
+	if got := fake.count(http.MethodDelete, "uploadId="); got != 1 {
+		t.Fatalf("aborted %d uploads, want 1", got)
+	}
+	if got := fake.count(http.MethodPost, "uploadId="); got != 0 {
+		t.Fatal("the failed upload was completed")
+	}
+	if got := getString(t, b, "ns", "key"); got != "old content" {
+		t.Fatalf("object = %q after a failed put, want the previous one", got)
+	}
+}
+
+func TestS3ListStaysInNamespace(t *testing.T) {
+
+	ctx := context.Background()
+	b, _ := newTestS3(t)
+
+	var want []string
+	for i := 0; i < 7; i++ {
+		key := fmt.Sprintf("docs/%02d", i)
+		want = append(want, key)
+		putString(t, b, "ns", key, key)
+	}
+	// namespaces that share a prefix with ns, and objects of the bucket outside of the key prefix
+	putString(t, b, "ns2", "docs/00", "other")
+	putString(t, b, "n", "s/docs/00", "other")
+	for _, objectKey := range []string{"ns/docs/99", "files/ns", "files/ns2/docs/01"} {
+		_, err := b.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String("bucket"),
+			Key: aws.String(objectKey),
+			Body: strings.NewReader("other"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	cursor := ""
+	for {
+		objects, next, err := b.List(ctx, "ns", "", cursor, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, object := range objects {
+			got = append(got, object.Key)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("listed %v, want %v", got, want)
+	}
+
+	_, _, err := b.List(ctx, "../ns2", "", "", 10)
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("list of another namespace through the path : %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestS3GetMissing(t *testing.T) {
+
+	ctx := context.Background()
+	b, _ := newTestS3(t)
+
+	_, _, err := b.Get(ctx, "ns", "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get : %v, want ErrNotFound", err)
+	}
+	err = b.Delete(ctx, "ns", "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete : %v, want ErrNotFound", err)
+	}
+
+	putString(t, b, "ns", "key", "v")
+	err = b.Delete(ctx, "ns", "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = b.Stat(ctx, "ns", "key")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("stat after delete : %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,187 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"main.go/internal/config"
+	"main.go/internal/dto"
+)
+
+const (
+	Issuer = "vaultbase"
+	Version = "v1"
+
+	// token types, carried in the 'typ' claim so that one can never be used in place of the other
+	Access = "access"
+	Refresh = "refresh"
+	Confirm = "confirm"
+)
+
+var (
+	ErrMissingSecret = errors.New("jwt secret key not found in env")
+	ErrInvalidToken = errors.New("token is invalid")
+	ErrExpiredToken = errors.New("token has expired")
+	ErrWrongTokenType = errors.New("token is not of the expected type")
+)
+
+type claims struct {
+	Role int64 `json:"role"`
+	Email string `json:"email,omitempty"`
+	Type string `json:"typ"`
+	FamilyID string `json:"fam,omitempty"`
+	Version string `json:"ver"`
+	jwt.RegisteredClaims
+}
+
+func secretKey() ([]byte, error) {
+	secret, exists := os.LookupEnv("JWTSecretKey")
+	if !exists || secret == "" {
+		return nil, ErrMissingSecret
+	}
+	return []byte(secret), nil
+}
+
+// NewID returns a random hex string, used for token IDs and refresh token families.
+func NewID() (string, error) {
+	bytes := make([]byte, 16)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+func sign(c *claims) (string, error) {
+
+	secret, err := secretKey()
+	if err != nil {
+		return "", err
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(secret)
+}
+
+// NewAccessToken returns a short lived HS256 signed token for the user.
+func NewAccessToken(userID int64, role int64, email string) (string, error) {
+
+	now := time.Now()
+	return sign(&claims{
+		Role: role,
+		Email: email,
+		Type: Access,
+		Version: Version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer: Issuer,
+			Subject: strconv.FormatInt(userID, 10),
+			IssuedAt: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.JWTAccessExpiration * time.Second)),
+		},
+	})
+}
+
+// NewRefreshToken returns a refresh token belonging to the family, along with its ID and expiry.
+// The ID is what gets stored, the token itself is never persisted.
+func NewRefreshToken(userID int64, role int64, familyID string) (string, string, int64, error) {
+
+	jti, err := NewID()
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(config.JWTRefreshExpiration * time.Second)
+
+	token, err := sign(&claims{
+		Role: role,
+		Type: Refresh,
+		FamilyID: familyID,
+		Version: Version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer: Issuer,
+			Subject: strconv.FormatInt(userID, 10),
+			IssuedAt: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID: jti,
+		},
+	})
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	return token, jti, expiresAt.Unix(), nil
+}
+
+// NewConfirmToken returns a token that confirms the email of the user, it is only valid as long as the email stays the same.
+func NewConfirmToken(userID int64, email string) (string, error) {
+
+	now := time.Now()
+	return sign(&claims{
+		Email: email,
+		Type: Confirm,
+		Version: Version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer: Issuer,
+			Subject: strconv.FormatInt(userID, 10),
+			IssuedAt: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.ConfirmTokenExpiration * time.Second)),
+		},
+	})
+}
+
+// Parse verifies the signature, issuer, expiry and issue time of the token and that it is of the given type.
+func Parse(token string, tokenType string) (*dto.Token, error) {
+
+	secret, err := secretKey()
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(claims)
+	_, err = jwt.ParseWithClaims(token, c, func(t *jwt.Token) (any, error) {
+		return secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	if c.Type != tokenType {
+		return nil, ErrWrongTokenType
+	}
+	// all the tokens issued here carry it, the parser only checks it when present
+	if c.Version != Version || c.IssuedAt == nil {
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(c.Subject, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &dto.Token{
+		Issuer: c.Issuer,
+		Subject: c.Subject,
+		ExpiresAt: c.ExpiresAt.Unix(),
+		IssuedAt: c.IssuedAt.Unix(),
+		Role: c.Role,
+		ID: userID,
+		Email: c.Email,
+		Type: c.Type,
+		JTI: c.RegisteredClaims.ID,
+		FamilyID: c.FamilyID,
+		Version: c.Version,
+	}, nil
+}
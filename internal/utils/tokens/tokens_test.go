@@ -0,0 +1,161 @@
+package tokens
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParse(t *testing.T) {
+
+	t.Setenv("JWTSecretKey", "test-secret")
+
+	access, err := NewAccessToken(1, 2, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := Parse(access, Access)
+	if err != nil {
+		t.Fatalf("valid access token : %v", err)
+	}
+	if token.ID != 1 || token.Role != 2 || token.Email != "a@example.com" || token.IssuedAt == 0 {
+		t.Fatalf("parsed token = %+v", token)
+	}
+
+	_, err = Parse(access, Refresh)
+	if !errors.Is(err, ErrWrongTokenType) {
+		t.Fatalf("access token parsed as refresh : %v", err)
+	}
+
+	t.Setenv("JWTSecretKey", "other-secret")
+	_, err = Parse(access, Access)
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("token of another secret : %v", err)
+	}
+}
+
+func TestParseClaims(t *testing.T) {
+
+	t.Setenv("JWTSecretKey", "test-secret")
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		claims *claims
+		want error
+	}{
+		{
+			name: "missing issue time",
+			claims: &claims{
+				Type: Access,
+				Version: Version,
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer: Issuer,
+					Subject: "1",
+					ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+				},
+			},
+			want: ErrInvalidToken,
+		},
+		{
+			name: "issued in the future",
+			claims: &claims{
+				Type: Access,
+				Version: Version,
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer: Issuer,
+					Subject: "1",
+					IssuedAt: jwt.NewNumericDate(now.Add(time.Hour)),
+					ExpiresAt: jwt.NewNumericDate(now.Add(2 * time.Hour)),
+				},
+			},
+			want: ErrInvalidToken,
+		},
+		{
+			name: "missing expiry",
+			claims: &claims{
+				Type: Access,
+				Version: Version,
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer: Issuer,
+					Subject: "1",
+					IssuedAt: jwt.NewNumericDate(now),
+				},
+			},
+			want: ErrInvalidToken,
+		},
+		{
+			name: "expired",
+			claims: &claims{
+				Type: Access,
+				Version: Version,
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer: Issuer,
+					Subject: "1",
+					IssuedAt: jwt.NewNumericDate(now.Add(-2 * time.Hour)),
+					ExpiresAt: jwt.NewNumericDate(now.Add(-time.Hour)),
+				},
+			},
+			want: ErrExpiredToken,
+		},
+		{
+			name: "other issuer",
+			claims: &claims{
+				Type: Access,
+				Version: Version,
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer: "someone-else",
+					Subject: "1",
+					IssuedAt: jwt.NewNumericDate(now),
+					ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+				},
+			},
+			want: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			token, err := sign(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Parse(token, Access)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+
+	t.Setenv("JWTSecretKey", "test-secret")
+
+	first, firstID, _, err := NewRefreshToken(1, 0, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, secondID, _, err := NewRefreshToken(1, 0, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstID == secondID {
+		t.Fatal("two refresh tokens got the same ID")
+	}
+
+	for _, refresh := range []string{first, second} {
+		token, err := Parse(refresh, Refresh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.FamilyID != "family" {
+			t.Fatalf("family = %q, want family", token.FamilyID)
+		}
+	}
+}
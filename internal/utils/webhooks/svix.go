@@ -0,0 +1,92 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tolerance is how far the signed timestamp may be from now, older deliveries are treated as replays.
+const Tolerance = 5 * time.Minute
+
+const (
+	secretPrefix = "whsec_"
+	signatureVersion = "v1"
+)
+
+var (
+	ErrMissingHeaders = errors.New("webhook signature headers are missing")
+	ErrInvalidSecret = errors.New("webhook secret is malformed")
+	ErrInvalidTimestamp = errors.New("webhook timestamp is invalid or outside the tolerance")
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+)
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, secretPrefix))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
+
+func sign(key []byte, msgID string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(msgID + "." + timestamp + "."))
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the svix-signature header value for the message, as the sender computes it.
+func Sign(secret string, msgID string, timestamp time.Time, body []byte) (string, error) {
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return signatureVersion + "," + sign(key, msgID, strconv.FormatInt(timestamp.Unix(), 10), body), nil
+}
+
+// Verify checks the svix-id, svix-timestamp and svix-signature headers against the raw body.
+// The signature header can carry several space separated signatures during secret rotation, one match is enough.
+func Verify(secret string, headers http.Header, body []byte) error {
+
+	msgID := headers.Get("svix-id")
+	timestamp := headers.Get("svix-timestamp")
+	signatures := headers.Get("svix-signature")
+	if msgID == "" || timestamp == "" || signatures == "" {
+		return ErrMissingHeaders
+	}
+
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	drift := time.Since(time.Unix(sentAt, 0))
+	if drift > Tolerance || drift < -Tolerance {
+		return fmt.Errorf("%w : sent %s ago", ErrInvalidTimestamp, drift.Round(time.Second))
+	}
+
+	expected := sign(key, msgID, timestamp, body)
+	for _, versioned := range strings.Fields(signatures) {
+		version, signature, found := strings.Cut(versioned, ",")
+		if !found || version != signatureVersion {
+			continue
+		}
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
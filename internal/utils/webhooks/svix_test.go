@@ -0,0 +1,126 @@
+package webhooks
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var (
+	testSecret = "whsec_" + base64.StdEncoding.EncodeToString([]byte("current secret of the endpoint"))
+	oldSecret = "whsec_" + base64.StdEncoding.EncodeToString([]byte("secret before the rotation"))
+)
+
+func headers(t *testing.T, msgID string, sentAt time.Time, secrets []string, body []byte) http.Header {
+
+	signatures := ""
+	for _, secret := range secrets {
+		signature, err := Sign(secret, msgID, sentAt, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signatures != "" {
+			signatures += " "
+		}
+		signatures += signature
+	}
+
+	header := http.Header{}
+	header.Set("svix-id", msgID)
+	header.Set("svix-timestamp", strconv.FormatInt(sentAt.Unix(), 10))
+	header.Set("svix-signature", signatures)
+	return header
+}
+
+func TestVerify(t *testing.T) {
+
+	body := []byte(`{"type":"user.created","data":{"id":"user_1"}}`)
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		secret string // configured on the server
+		header http.Header
+		body []byte
+		want error
+	}{
+		{
+			name: "valid signature",
+			secret: testSecret,
+			header: headers(t, "msg_1", now, []string{testSecret}, body),
+			body: body,
+		},
+		{
+			name: "tampered body",
+			secret: testSecret,
+			header: headers(t, "msg_1", now, []string{testSecret}, body),
+			body: []byte(`{"type":"user.created","data":{"id":"user_2"}}`),
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "other message id",
+			secret: testSecret,
+			header: func() http.Header {
+				header := headers(t, "msg_1", now, []string{testSecret}, body)
+				header.Set("svix-id", "msg_2")
+				return header
+			}(),
+			body: body,
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "stale timestamp",
+			secret: testSecret,
+			header: headers(t, "msg_1", now.Add(-Tolerance - time.Minute), []string{testSecret}, body),
+			body: body,
+			want: ErrInvalidTimestamp,
+		},
+		{
+			name: "timestamp in the future",
+			secret: testSecret,
+			header: headers(t, "msg_1", now.Add(Tolerance + time.Minute), []string{testSecret}, body),
+			body: body,
+			want: ErrInvalidTimestamp,
+		},
+		{
+			name: "rotated secret, signed with both",
+			secret: testSecret,
+			header: headers(t, "msg_1", now, []string{oldSecret, testSecret}, body),
+			body: body,
+		},
+		{
+			name: "rotated secret, signed with the old one only",
+			secret: testSecret,
+			header: headers(t, "msg_1", now, []string{oldSecret}, body),
+			body: body,
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "missing headers",
+			secret: testSecret,
+			header: http.Header{},
+			body: body,
+			want: ErrMissingHeaders,
+		},
+		{
+			name: "malformed secret",
+			secret: "whsec_not base64",
+			header: headers(t, "msg_1", now, []string{testSecret}, body),
+			body: body,
+			want: ErrInvalidSecret,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			err := Verify(tt.secret, tt.header, tt.body)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}